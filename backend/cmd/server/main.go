@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -13,10 +14,12 @@ import (
 
 	"github.com/uploadparty/app/config"
 	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/emails"
 	"github.com/uploadparty/app/internal/integrations/licenses"
 	"github.com/uploadparty/app/internal/middlewares"
 	"github.com/uploadparty/app/internal/models"
 	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
 	"github.com/uploadparty/app/pkg/db"
 )
 
@@ -34,13 +37,18 @@ func main() {
 
 	gin.SetMode(cfg.GinMode)
 	r := gin.New()
+	// Only proxies we run may set X-Forwarded-For; otherwise ClientIP is the peer address, which
+	// the rate limiter and play deduplication rely on
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 	r.Use(gin.Logger(), gin.Recovery())
 
 	corsCfg := cors.Config{
 		AllowOrigins:     []string{cfg.FrontendURL},
-		AllowMethods:     []string{"GET", "POST", "PATCH", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "Upload-Offset", "Range", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "Location", "Upload-Offset", "Upload-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -75,7 +83,7 @@ func main() {
 		} else {
 			log.Printf("[SUCCESS] Database connection established")
 			// Run migrations - required in development, optional in production
-			if err := database.AutoMigrate(&models.RSVP{}, &models.User{}, &models.Project{}, &models.Plugin{}); err != nil {
+			if err := database.AutoMigrate(&models.RSVP{}, &models.User{}, &models.Project{}, &models.Plugin{}, &models.AudioFile{}, &models.UploadSession{}, &models.Challenge{}, &models.ChallengeEntry{}, &models.ChallengeVote{}, &models.Session{}, &models.RevokedToken{}, &models.APIKey{}, &models.DeviceAuthorization{}, &models.ProjectSession{}, &models.ProjectHeartbeat{}, &models.PluginEvent{}, &models.PluginCatalog{}, &models.PluginAlias{}, &models.IngestReceipt{}, &models.IdempotencyKey{}, &models.HandleRedirect{}, &models.Follow{}, &models.Activity{}, &models.Comment{}, &models.ProjectLike{}, &models.EngagementHit{}, &models.CounterDelta{}); err != nil {
 				log.Printf("[ERROR] Database migration failed: %v", err)
 				if cfg.IsDevelopment() {
 					log.Fatalf("[DEV] Migration failure in development - exiting")
@@ -93,8 +101,41 @@ func main() {
 		log.Println("[licenses] init failed; external license lookups disabled")
 	}
 
+	// Object storage for uploaded audio. Uploads are disabled if it cannot be initialized.
+	blobStore, err := storage.New(context.Background(), cfg)
+	if err != nil {
+		log.Printf("[storage] init failed: %v; audio uploads disabled", err)
+		blobStore = nil
+	}
+
+	signer := storage.NewSigner(blobStore, cfg.PublicAPIURL, []byte(cfg.MediaSigningKey))
+	playback := services.NewPlaybackService(signer, time.Duration(cfg.SignedURLTTLMinutes)*time.Minute)
+
+	sessions := services.NewSessionService(database, cfg.JWTSecret,
+		time.Duration(cfg.AccessTokenTTLMinutes)*time.Minute, time.Duration(cfg.RefreshTokenTTLDays)*24*time.Hour)
 	jwt := middlewares.NewJWT(cfg.JWTSecret)
+	if database != nil {
+		jwt.Revocations = sessions
+		go sessions.Cleanup(time.Hour)
+	}
 	auth0 := middlewares.NewAuth0(cfg.Auth0Domain, cfg.Auth0Audience)
+	authn := middlewares.NewAuthenticator(database, jwt, auth0)
+	roles := middlewares.NewRoles(database, cfg.AdminEmails)
+
+	// Idempotency-Key support for mutating routes. Responses that carry secrets are never stored.
+	var idemStore middlewares.IdempotencyStore
+	if database != nil && cfg.IdempotencyStore != "memory" {
+		dbStore := middlewares.NewDBIdempotencyStore(database)
+		go dbStore.Cleanup(time.Hour)
+		idemStore = dbStore
+	} else {
+		memStore := middlewares.NewMemoryIdempotencyStore()
+		go memStore.Cleanup(10 * time.Minute)
+		idemStore = memStore
+	}
+	idem := middlewares.NewIdempotency(idemStore, time.Duration(cfg.IdempotencyTTLHours)*time.Hour,
+		"/api/v1/app/api-keys", // returns the plaintext key
+	)
 
 	// Initialize email service
 	emailService, err := services.NewEmailService(cfg)
@@ -104,29 +145,94 @@ func main() {
 	}
 
 	healthCtl := controllers.NewHealthController(database)
-	authCtl := controllers.NewAuthController(database, cfg.JWTSecret)
-	projCtl := controllers.NewProjectController(database)
+	authCtl := controllers.NewAuthController(database, cfg.JWTSecret, sessions)
+	projCtl := controllers.NewProjectController(database, playback)
+	projCtl.Svc.Sessions.IdleGap = time.Duration(cfg.SessionIdleGapMinutes) * time.Minute
 	pluginCtl := controllers.NewPluginController(database)
-	profCtl := controllers.NewProfileController(database, cfg.JWTSecret)
+	ingestCtl := controllers.NewIngestController(database)
+	ingestCtl.Svc.Projects = projCtl.Svc // same session idle gap as single heartbeats
+	if database != nil {
+		go ingestCtl.Svc.Cleanup(time.Hour)
+	}
+	profCtl := controllers.NewProfileController(database, cfg.JWTSecret, playback)
+	profCtl.Me.Store = blobStore // nil disables avatar uploads
+	profCtl.Me.AvatarBaseURL = strings.TrimRight(cfg.PublicAPIURL, "/") + "/avatars"
+	feedCtl := controllers.NewFeedController(database)
+	// Likes, plays and profile views; counters are folded in by a background aggregator
+	engageCtl := controllers.NewEngagementController(database)
+	engageCtl.Svc.Salt = cfg.EngagementSalt
+	profCtl.Engagement = engageCtl.Svc
+	if database != nil {
+		go engageCtl.Svc.RunAggregator(30 * time.Second)
+	}
 	rsvpCtl := controllers.NewRSVPController(database, emailService)
+	// Comment notifications and welcome emails go out by email when it is configured
+	var commentMailer services.CommentMailer
+	if emailService != nil {
+		commentMailer = emailService
+		authCtl.Users.Mailer = emailService
+	}
+	// Email templates are embedded; EMAIL_TEMPLATES_DIR overrides them file by file
+	emailTemplates := emails.New(cfg.EmailTemplatesDir)
+	if err := emailTemplates.Check(); err != nil {
+		log.Printf("[EMAIL] Template check failed: %v", err)
+	}
+	emailCtl := controllers.NewEmailController(emailTemplates)
+	commentCtl := controllers.NewCommentController(database, commentMailer, strings.TrimRight(cfg.FrontendURL, "/"))
+	// Background audio analysis (duration, loudness, waveform) for newly uploaded files
+	var analysis *services.AnalysisService
+	if database != nil && blobStore != nil {
+		analysis = services.NewAnalysisService(database, blobStore)
+		go analysis.Run(2, time.Minute)
+	}
+	audioCtl := controllers.NewAudioController(database, blobStore, cfg.MaxAudioUploadBytes, playback, analysis)
+	mediaCtl := controllers.NewMediaController(blobStore, signer)
+	uploadCtl := controllers.NewUploadController(database, blobStore, cfg.MaxResumableUploadBytes, cfg.UploadChunkMaxBytes, analysis)
+	if database != nil && blobStore != nil {
+		go uploadCtl.Svc.Cleanup(time.Hour)
+	}
+	challengeCtl := controllers.NewChallengeController(database, playback)
+	adminCtl := controllers.NewAdminController(database)
+	catalogCtl := controllers.NewPluginCatalogController(database)
+	searchCtl := controllers.NewSearchController(database)
+	if database != nil {
+		// Links plugins recorded before the catalog existed; new ones are linked on ingest
+		go catalogCtl.Svc.LinkUnlinked()
+	}
+	apiKeyCtl := controllers.NewAPIKeyController(database)
+	deviceCtl := controllers.NewDeviceController(database, cfg.FrontendURL)
+	if database != nil {
+		go deviceCtl.Svc.Cleanup(time.Hour)
+	}
+	if database != nil {
+		// Locks entries once a challenge deadline passes
+		go challengeCtl.Svc.RunLocker(time.Minute)
+	}
 
 	// Health
 	r.GET("/health", healthCtl.Health)
 	// Public alias for health under /api to work behind proxies
 	r.GET("/api/health", healthCtl.Health)
 
-	// Auth group (public endpoints)
+	// Auth group (public endpoints; logout needs the access token being ended)
 	auth := r.Group("/auth")
 	{
-		auth.POST("/register", authCtl.Register)
+		auth.POST("/register", idem.Middleware(), authCtl.Register)
 		auth.POST("/login", authCtl.Login)
+		auth.POST("/refresh", authCtl.Refresh)                           // rotate refresh token
+		auth.POST("/logout", authn.RequireUser(), authCtl.Logout)        // this session
+		auth.POST("/logout-all", authn.RequireUser(), authCtl.LogoutAll) // every session of the user
+
+		// Device login for the VST plugin: start, then poll until the user approves in the app
+		auth.POST("/device/code", deviceCtl.Start)
+		auth.POST("/device/token", deviceCtl.Token)
 	}
 
 	// RSVP (public endpoints)
-	r.POST("/rsvp", rsvpCtl.Create)
+	r.POST("/rsvp", idem.Middleware(), rsvpCtl.Create)
 	r.GET("/rsvp/count", rsvpCtl.Count)
 	r.GET("/rsvp/:id/referrals", rsvpCtl.GetReferrals)
-	r.PATCH("/rsvp/:id/referral-code", rsvpCtl.UpdateReferralCode)
+	r.PATCH("/rsvp/:id/referral-code", idem.Middleware(), rsvpCtl.UpdateReferralCode)
 
 	// Auth0 sync endpoint (protected by Auth0 JWT)
 	// This endpoint is called by the frontend after Auth0 login to sync user info to our DB
@@ -135,33 +241,157 @@ func main() {
 		authSync.Use(auth0.RequireAuth0())
 	}
 	{
-		authSync.POST("/sync", authCtl.SyncUser)
+		authSync.POST("/sync", idem.Middleware(), authCtl.SyncUser)
 	}
 
-	// API v1 protected (JWT). We now split routes by client type: /app (frontend) vs /ingest (VST/plugin)
+	// API v1 protected. Accepts a legacy JWT or an Auth0 access token for a synced user;
+	// the ingest group also accepts API keys.
+	// We now split routes by client type: /app (frontend) vs /ingest (VST/plugin)
 	api := r.Group("/api/v1")
-	api.Use(jwt.RequireAuth())
 	{
 		// --- Separated groups ---
 		// VST/plugin ingestion endpoints: heartbeat/metadata and plugin upserts.
+		// Also accepts scoped API keys so plugins don't need a user session.
 		ingest := api.Group("/ingest")
+		ingest.Use(authn.RequireUserOrAPIKey(), idem.Middleware())
 		{
-			ingest.POST("/projects", projCtl.Upsert) // upsert by title; used by VST heartbeat/metadata capture
-			ingest.POST("/projects/:id/plugins", pluginCtl.UpsertForProject)
-			ingest.PATCH("/projects/:id/complete", projCtl.MarkComplete)
+			ingest.POST("/projects", middlewares.RequireScope(models.ScopeProjectsWrite), projCtl.Upsert) // upsert by uuid (or title for older plugins); used by VST heartbeat/metadata capture
+			ingest.POST("/projects/:id/plugins", middlewares.RequireScope(models.ScopePluginsWrite), pluginCtl.UpsertForProject)
+			ingest.PUT("/projects/:id/plugins", middlewares.RequireScope(models.ScopePluginsWrite), pluginCtl.SyncForProject) // full list; missing plugins are marked removed
+			ingest.PATCH("/projects/:id/complete", middlewares.RequireScope(models.ScopeProjectsWrite), projCtl.MarkComplete)
+			ingest.POST("/batch", ingestCtl.Batch) // offline queue flush; scopes are checked per item type
 		}
 
 		// Frontend application endpoints: listing, reading, user-triggered updates.
 		app := api.Group("/app")
+		app.Use(authn.RequireUser(), idem.Middleware())
 		{
-			app.GET("/projects", projCtl.ListMine)
+			// The caller's own profile: display name, bio, handle, social links, avatar
+			app.GET("/me", profCtl.GetMe)
+			app.PATCH("/me", profCtl.UpdateMe)
+			app.POST("/me/avatar", profCtl.UploadAvatar) // multipart "file"; JPEG, PNG or GIF up to 5 MiB
+			app.DELETE("/me/avatar", profCtl.DeleteAvatar)
+			// Following producers and the feed of what they do
+			app.GET("/profiles/:handle/follow", profCtl.FollowState)
+			app.POST("/profiles/:handle/follow", profCtl.Follow)
+			app.DELETE("/profiles/:handle/follow", profCtl.Unfollow)
+			app.GET("/feed", feedCtl.Feed) // ?limit=&cursor=
+			// API keys for the ingest routes; the plaintext key is only returned on create.
+			app.POST("/api-keys", apiKeyCtl.Create)
+			app.GET("/api-keys", apiKeyCtl.List)
+			app.DELETE("/api-keys/:id", apiKeyCtl.Revoke)
+			// Device login approval: the user enters the code shown by the plugin
+			app.GET("/device", deviceCtl.Lookup) // ?code=XXXX-XXXX
+			app.POST("/device/approve", deviceCtl.Approve)
+			app.POST("/device/deny", deviceCtl.Deny)
+
+			app.GET("/projects", projCtl.ListMine) // cursor-paged and filterable; ?deleted=true lists the trash
+			app.GET("/projects/:id", projCtl.Get)
+			app.PATCH("/projects/:id", projCtl.Update) // rename, public, reopen
+			app.DELETE("/projects/:id", projCtl.Delete)
+			app.POST("/projects/:id/restore", projCtl.Restore)
+			// Comments on public projects, optionally pinned to a moment in the audio
+			app.POST("/projects/:id/comments", commentCtl.Create) // {body, parentId?, timestampSeconds?, audioFileId?}
+			// Likes on public projects, one per user
+			app.GET("/projects/:id/like", engageCtl.LikeState)
+			app.POST("/projects/:id/like", engageCtl.Like)
+			app.DELETE("/projects/:id/like", engageCtl.Unlike)
+			app.PATCH("/comments/:id", commentCtl.Update)
+			app.DELETE("/comments/:id", commentCtl.Delete)
 			app.GET("/projects/:id/plugins", pluginCtl.ListByProject)
+			app.GET("/projects/:id/plugins/history", pluginCtl.History)
+			app.GET("/projects/:id/sessions", projCtl.Sessions) // work timeline inferred from heartbeats
+			app.GET("/projects/:id/sessions/:sessionId/heartbeats", projCtl.Heartbeats)
 			app.PATCH("/projects/:id/complete", projCtl.MarkComplete)
+			app.POST("/projects/:id/audio", audioCtl.Upload) // multipart upload, field "file"
+			app.GET("/projects/:id/audio", audioCtl.ListByProject)
+			app.GET("/projects/:id/audio/:audioId/url", audioCtl.PlaybackURL)
+			app.DELETE("/projects/:id/audio/:audioId", audioCtl.Delete)
+
+			// Resumable chunked uploads for large stems; finalize creates a project audio file.
+			app.POST("/uploads", uploadCtl.Create)
+			app.HEAD("/uploads/:id", uploadCtl.Status)
+			app.GET("/uploads/:id", uploadCtl.Status)
+			app.PATCH("/uploads/:id", uploadCtl.AppendChunk)
+			app.POST("/uploads/:id/finalize", uploadCtl.Finalize)
+			app.DELETE("/uploads/:id", uploadCtl.Abort)
+
+			// Challenge entries: one per user, replaceable until the deadline.
+			app.POST("/challenges/:id/entries", challengeCtl.Submit)
+			app.DELETE("/challenges/:id/entries", challengeCtl.Withdraw)
+			// Community voting: one ballot per user, replaceable while voting is open.
+			app.GET("/challenges/:id/ballot", challengeCtl.Ballot)
+			app.PUT("/challenges/:id/ballot", challengeCtl.CastBallot)
+			app.DELETE("/challenges/:id/ballot", challengeCtl.RetractBallot)
+		}
+
+		// Staff endpoints. Moderators review and hide content; admins manage accounts and challenges.
+		admin := api.Group("/admin")
+		admin.Use(authn.RequireUser(), roles.RequireRole(models.RoleModerator), idem.Middleware())
+		{
+			adminOnly := roles.RequireRole(models.RoleAdmin)
+
+			admin.GET("/users", adminCtl.ListUsers)
+			admin.GET("/users/:id", adminCtl.GetUser)
+			admin.PATCH("/users/:id", adminCtl.UpdateUser) // role changes require admin
+			admin.DELETE("/users/:id", adminOnly, adminCtl.DeleteUser)
+
+			admin.GET("/rsvps", adminOnly, adminCtl.ListRSVPs)
+			admin.DELETE("/rsvps/:id", adminOnly, adminCtl.DeleteRSVP)
+
+			admin.GET("/emails", adminOnly, emailCtl.List)
+			admin.GET("/emails/:name/preview", adminOnly, emailCtl.Preview) // ?locale=&format=html|text
+
+			admin.GET("/projects", adminCtl.ListProjects)
+			admin.PATCH("/projects/:id", adminCtl.UpdateProject)
+			// Permanent; moderators hide projects with PATCH instead
+			admin.DELETE("/projects/:id", adminOnly, adminCtl.DeleteProject)
+			admin.DELETE("/comments/:id", commentCtl.Remove) // {reason?}
+
+			admin.POST("/challenges", adminOnly, challengeCtl.Create)
+			admin.PATCH("/challenges/:id", adminOnly, challengeCtl.Update)
+			admin.DELETE("/challenges/:id", adminOnly, challengeCtl.Delete)
+			admin.POST("/challenges/:id/winners", adminOnly, challengeCtl.AnnounceWinners)
+			admin.GET("/challenges/:id/results", challengeCtl.LiveResults)
+
+			// Plugin catalog curation: fix names, map spellings, merge duplicates.
+			admin.GET("/plugin-catalog", catalogCtl.List)
+			admin.PATCH("/plugin-catalog/:id", catalogCtl.Update)
+			admin.POST("/plugin-catalog/:id/aliases", catalogCtl.AddAlias)
+			admin.POST("/plugin-catalog/:id/merge", catalogCtl.Merge)
 		}
 	}
 
-	// Public profiles
-	r.GET("/profiles/:handle", profCtl.GetPublicProfile)
+	// Public profiles; a changed handle redirects to the new one until someone else claims it.
+	// Viewing a profile counts a view, once per signed-in user or IP per window.
+	r.GET("/profiles/:handle", authn.OptionalUser(), profCtl.GetPublicProfile)
+	r.GET("/profiles/:handle/projects", profCtl.GetPublicProjects) // paged; same filters as /api/v1/app/projects
+	r.GET("/profiles/:handle/plugins", profCtl.GetPublicPlugins)
+	r.GET("/profiles/:handle/followers", profCtl.GetFollowers) // paged
+	r.GET("/profiles/:handle/following", profCtl.GetFollowing)
+	r.GET("/avatars/:name", profCtl.Avatar) // uploaded avatars, immutable by name
+
+	// Public comment threads on a project
+	r.GET("/projects/:id/comments", commentCtl.List) // ?sort=newest|oldest&limit=&cursor=
+	// Playback started; counted once per listener (user or IP) per 30 minutes, so retries need
+	// no Idempotency-Key
+	r.POST("/projects/:id/plays", authn.OptionalUser(), engageCtl.RecordPlay)
+
+	// Public plugin stats from the canonical catalog
+	r.GET("/plugins/top", catalogCtl.Top) // ?period=month|year|all&limit=
+	r.GET("/plugins/:slug", catalogCtl.Get)
+
+	// Public search over projects, producers and plugins
+	r.GET("/search", searchCtl.Search) // ?q=&type=projects,producers,plugins&limit=
+
+	// Public challenges
+	r.GET("/challenges", challengeCtl.List)
+	r.GET("/challenges/:id", challengeCtl.Get)             // id or slug
+	r.GET("/challenges/:id/results", challengeCtl.Results) // ?mode=count|bayesian, after voting closes
+
+	// Signed media streaming for the local store (HMAC-verified, supports Range)
+	r.GET("/media/*key", mediaCtl.Stream)
+	r.HEAD("/media/*key", mediaCtl.Stream)
 
 	// Serve static frontend files (for Cloud Run single-service deployment)
 	r.Static("/static", "./app/.next/static")
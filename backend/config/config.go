@@ -1,9 +1,12 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +25,19 @@ type Config struct {
 	GinMode     string
 	FrontendURL string
 	JWTSecret   string
+	AdminEmails []string // ADMIN_EMAILS, comma separated
+	// TRUSTED_PROXIES, comma separated IPs or CIDRs whose X-Forwarded-For is believed; none by default
+	TrustedProxies []string
+
+	AccessTokenTTLMinutes int // lifetime of legacy access JWTs
+	RefreshTokenTTLDays   int // lifetime of refresh tokens (sliding: each rotation restarts it)
+
+	SessionIdleGapMinutes int // heartbeats further apart than this start a new project session
+
+	EngagementSalt string // mixed into hashed viewer IPs for play and view deduplication
+
+	IdempotencyStore    string // "db" (shared across instances) or "memory"
+	IdempotencyTTLHours int    // how long a stored response answers retries with the same key
 
 	// Auth0
 	Auth0Domain   string // e.g., "https://your-tenant.us.auth0.com"
@@ -31,6 +47,14 @@ type Config struct {
 	GCPProjectID               string
 	GCSBucket                  string
 	GoogleApplicationCredsPath string // GOOGLE_APPLICATION_CREDENTIALS
+	StorageProvider            string // "local" or "gcs"
+	LocalStorageDir            string // root directory for the local store
+	MaxAudioUploadBytes        int64
+	MaxResumableUploadBytes    int64 // total size cap for chunked uploads
+	UploadChunkMaxBytes        int64
+	PublicAPIURL               string // externally reachable base URL of this API, used in signed media links
+	MediaSigningKey            string // HMAC key for local-store media URLs
+	SignedURLTTLMinutes        int
 
 	// External license directory (generic, provider may be hidden)
 	LicensesProvider string // e.g., "airtable" or "none"
@@ -44,6 +68,8 @@ type Config struct {
 	SMTPPassword string
 	FromEmail    string
 	FromName     string
+	// Template files here replace the embedded email templates of the same name
+	EmailTemplatesDir string
 }
 
 // IsProduction returns true if running in production
@@ -77,6 +103,12 @@ func Load() *Config {
 		env = "development"
 	}
 
+	// Object storage defaults to the local filesystem outside production
+	storageDefault := "local"
+	if env == "production" {
+		storageDefault = "gcs"
+	}
+
 	cfg := &Config{
 		Environment:            env,
 		DBHost:                 getEnv("DB_HOST", "localhost"),
@@ -90,6 +122,14 @@ func Load() *Config {
 		GinMode:                getEnv("GIN_MODE", "debug"),
 		FrontendURL:            getEnv("FRONTEND_URL", "http://localhost:3000"),
 		JWTSecret:              getEnv("JWT_SECRET", "change_me"),
+		AdminEmails:            getEnvList("ADMIN_EMAILS"),
+		TrustedProxies:         getEnvList("TRUSTED_PROXIES"),
+		AccessTokenTTLMinutes:  getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 60),
+		RefreshTokenTTLDays:    getEnvInt("REFRESH_TOKEN_TTL_DAYS", 30),
+		SessionIdleGapMinutes:  getEnvInt("SESSION_IDLE_GAP_MINUTES", 15),
+		EngagementSalt:         getEnv("ENGAGEMENT_SALT", ""),
+		IdempotencyStore:       getEnv("IDEMPOTENCY_STORE", "db"),
+		IdempotencyTTLHours:    getEnvInt("IDEMPOTENCY_TTL_HOURS", 24),
 		// Auth0
 		Auth0Domain:   getEnv("AUTH0_ISSUER_BASE_URL", ""),
 		Auth0Audience: getEnv("AUTH0_AUDIENCE", ""),
@@ -97,21 +137,44 @@ func Load() *Config {
 		GCPProjectID:               getEnv("GCP_PROJECT_ID", ""),
 		GCSBucket:                  getEnv("GCS_BUCKET", "uploadparty-beats"),
 		GoogleApplicationCredsPath: getEnv("GOOGLE_APPLICATION_CREDENTIALS", ""),
+		StorageProvider:            getEnv("STORAGE_PROVIDER", storageDefault),
+		LocalStorageDir:            getEnv("LOCAL_STORAGE_DIR", "uploads"),
+		MaxAudioUploadBytes:        int64(getEnvInt("MAX_AUDIO_UPLOAD_MB", 100)) << 20,
+		MaxResumableUploadBytes:    int64(getEnvInt("MAX_RESUMABLE_UPLOAD_MB", 2048)) << 20,
+		UploadChunkMaxBytes:        int64(getEnvInt("UPLOAD_CHUNK_MAX_MB", 32)) << 20,
+		PublicAPIURL:               getEnv("PUBLIC_API_URL", "http://localhost:8080"),
+		MediaSigningKey:            getEnv("MEDIA_SIGNING_KEY", ""),
+		SignedURLTTLMinutes:        getEnvInt("SIGNED_URL_TTL_MINUTES", 60),
 		// Licenses (generic)
 		LicensesProvider: getEnv("LICENSES_PROVIDER", "none"),
 		LicensesToken:    getEnv("LICENSES_TOKEN", ""),
 		LicensesDSN:      getEnv("LICENSES_DSN", ""),
 		// Email (SMTP)
-		SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:     getEnvInt("SMTP_PORT", 587),
-		SMTPUsername: getEnv("SMTP_USERNAME", ""),
-		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
-		FromEmail:    getEnv("FROM_EMAIL", ""),
-		FromName:     getEnv("FROM_NAME", "UploadParty"),
+		SMTPHost:          getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:          getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		FromEmail:         getEnv("FROM_EMAIL", ""),
+		FromName:          getEnv("FROM_NAME", "UploadParty"),
+		EmailTemplatesDir: getEnv("EMAIL_TEMPLATES_DIR", ""),
 	}
 	if cfg.JWTSecret == "change_me" {
 		log.Println("[WARN] Using default JWT secret; set JWT_SECRET in env for non-dev")
 	}
+	if cfg.MediaSigningKey == "" {
+		// Derive from the JWT secret so signed links work out of the box in dev
+		cfg.MediaSigningKey = cfg.JWTSecret
+		if cfg.StorageProvider == "local" && cfg.IsProduction() {
+			log.Println("[WARN] MEDIA_SIGNING_KEY not set; falling back to JWT_SECRET for media URL signing")
+		}
+	}
+	if cfg.EngagementSalt == "" {
+		// A per-process salt still hides addresses; instances just stop agreeing on repeat viewers
+		cfg.EngagementSalt = randomHex(32)
+		if cfg.IsProduction() {
+			log.Println("[WARN] ENGAGEMENT_SALT not set; using a random salt, so plays are deduplicated per instance only")
+		}
+	}
 	if cfg.DBPassword == "postgres" || cfg.DBPassword == "" {
 		log.Println("[WARN] Using default or empty DB password; set DB_PASSWORD in env for non-dev and production")
 	}
@@ -127,6 +190,14 @@ func Load() *Config {
 	return cfg
 }
 
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("reading random bytes: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
 func tryLoadEnv(paths ...string) string {
 	for _, p := range paths {
 		if err := godotenv.Load(p); err == nil {
@@ -143,6 +214,16 @@ func getEnv(key, def string) string {
 	return def
 }
 
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func getEnvInt(key string, def int) int {
 	if v := os.Getenv(key); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
@@ -0,0 +1,140 @@
+// Package audio decodes uploaded audio in pure Go and derives the metadata the
+// frontend needs to draw and label a track without downloading it.
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// DefaultPeakCount is the number of points in a waveform overview.
+const DefaultPeakCount = 1000
+
+var ErrUnsupportedFormat = errors.New("audio analysis is not supported for this format")
+
+// Analysis is the result of decoding a complete file.
+type Analysis struct {
+	DurationSeconds float64
+	SampleRate      int
+	Channels        int
+	// LoudnessLUFS is the ITU-R BS.1770 integrated loudness; nil for silence or clips under 400ms.
+	LoudnessLUFS *float64
+	// Peaks holds the maximum absolute amplitude (0..1) for evenly sized slices of the file.
+	Peaks []float64
+}
+
+// decoder yields interleaved samples scaled to [-1, 1].
+type decoder interface {
+	sampleRate() int
+	channels() int
+	read(buf []float64) (int, error)
+}
+
+// newDecoder picks a decoder for a content type as reported by services.SniffAudioType.
+func newDecoder(r io.Reader, contentType string) (decoder, error) {
+	switch contentType {
+	case "audio/wav":
+		return newWAVDecoder(r)
+	case "audio/flac":
+		return newFLACDecoder(r)
+	case "audio/mpeg":
+		return newMP3Decoder(r)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// Analyze decodes r in a single streaming pass.
+func Analyze(r io.Reader, contentType string) (*Analysis, error) {
+	dec, err := newDecoder(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+	rate, chans := dec.sampleRate(), dec.channels()
+	if rate <= 0 || chans <= 0 {
+		return nil, errors.New("invalid stream parameters")
+	}
+
+	meter := newLoudnessMeter(rate, chans)
+	peakBlock := rate / 1000 // 1ms resolution before downsampling
+	if peakBlock < 1 {
+		peakBlock = 1
+	}
+	var (
+		blockPeaks []float64
+		blockMax   float64
+		blockLen   int
+		frames     int64
+		// mp3 is always decoded as stereo; identical channels mean the source was mono
+		identical = chans == 2
+	)
+
+	buf := make([]float64, 4096*chans)
+	for {
+		n, err := dec.read(buf)
+		for i := 0; i+chans <= n; i += chans {
+			frame := buf[i : i+chans]
+			meter.add(frame)
+			for _, v := range frame {
+				if a := math.Abs(v); a > blockMax {
+					blockMax = a
+				}
+			}
+			if identical && frame[0] != frame[1] {
+				identical = false
+			}
+			frames++
+			if blockLen++; blockLen == peakBlock {
+				blockPeaks = append(blockPeaks, blockMax)
+				blockMax, blockLen = 0, 0
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if blockLen > 0 {
+		blockPeaks = append(blockPeaks, blockMax)
+	}
+	if frames == 0 {
+		return nil, errors.New("no audio frames decoded")
+	}
+
+	outChans := chans
+	if identical {
+		if _, ok := dec.(*mp3Decoder); ok {
+			outChans = 1
+			meter.useChannels(1)
+		}
+	}
+	return &Analysis{
+		DurationSeconds: float64(frames) / float64(rate),
+		SampleRate:      rate,
+		Channels:        outChans,
+		LoudnessLUFS:    meter.integrated(),
+		Peaks:           downsamplePeaks(blockPeaks, DefaultPeakCount),
+	}, nil
+}
+
+// downsamplePeaks reduces peaks to at most n points, keeping the max of each slice
+// and rounding to 3 decimals to keep the stored JSON compact.
+func downsamplePeaks(peaks []float64, n int) []float64 {
+	if len(peaks) < n {
+		n = len(peaks)
+	}
+	out := make([]float64, n)
+	for i := range out {
+		start, end := i*len(peaks)/n, (i+1)*len(peaks)/n
+		var m float64
+		for _, p := range peaks[start:end] {
+			if p > m {
+				m = p
+			}
+		}
+		out[i] = math.Round(math.Min(m, 1)*1000) / 1000
+	}
+	return out
+}
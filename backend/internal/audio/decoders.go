@@ -0,0 +1,254 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+	// wavFmtMaxBytes is as much of a fmt chunk as is read; the extensible layout needs 40.
+	wavFmtMaxBytes = 64
+	// maxChannels bounds per-read buffers; uploads are mixes and stems, not multitrack.
+	maxChannels = 8
+)
+
+// wavDecoder streams PCM (8/16/24/32 bit) and IEEE float (32/64 bit) WAV data.
+type wavDecoder struct {
+	r          io.Reader
+	format     uint16
+	chans      int
+	rate       int
+	bits       int
+	blockAlign int
+	remaining  int64 // bytes left in the data chunk; <0 when the size is unknown
+	raw        []byte
+}
+
+func newWAVDecoder(r io.Reader) (*wavDecoder, error) {
+	br := bufio.NewReader(r)
+	var hdr [12]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, err
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+	d := &wavDecoder{r: br}
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(br, ch[:]); err != nil {
+			return nil, errors.New("wav data chunk not found")
+		}
+		id, size := string(ch[0:4]), int64(binary.LittleEndian.Uint32(ch[4:8]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav fmt chunk too short")
+			}
+			// The size comes from the file: read what the format needs and skip the rest.
+			fmtBuf := make([]byte, min(size, wavFmtMaxBytes))
+			if _, err := io.ReadFull(br, fmtBuf); err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, br, size-int64(len(fmtBuf))+size%2); err != nil {
+				return nil, err
+			}
+			d.format = binary.LittleEndian.Uint16(fmtBuf[0:2])
+			d.chans = int(binary.LittleEndian.Uint16(fmtBuf[2:4]))
+			d.rate = int(binary.LittleEndian.Uint32(fmtBuf[4:8]))
+			d.blockAlign = int(binary.LittleEndian.Uint16(fmtBuf[12:14]))
+			d.bits = int(binary.LittleEndian.Uint16(fmtBuf[14:16]))
+			if d.format == wavFormatExtensible && len(fmtBuf) >= 26 {
+				// first two bytes of the sub-format GUID carry the real format tag
+				d.format = binary.LittleEndian.Uint16(fmtBuf[24:26])
+			}
+		case "data":
+			if d.chans == 0 {
+				return nil, errors.New("wav data chunk before fmt chunk")
+			}
+			if err := d.validate(); err != nil {
+				return nil, err
+			}
+			d.remaining = size
+			if size == 0 || size == math.MaxUint32 {
+				d.remaining = -1 // streamed writers leave the size unset
+			}
+			return d, nil
+		default:
+			if _, err := io.CopyN(io.Discard, br, size+size%2); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *wavDecoder) validate() error {
+	if d.chans > maxChannels {
+		return fmt.Errorf("wav has %d channels; at most %d are supported", d.chans, maxChannels)
+	}
+	switch {
+	case d.format == wavFormatPCM && (d.bits == 8 || d.bits == 16 || d.bits == 24 || d.bits == 32):
+	case d.format == wavFormatFloat && (d.bits == 32 || d.bits == 64):
+	default:
+		return fmt.Errorf("unsupported wav encoding (format %d, %d bits)", d.format, d.bits)
+	}
+	if d.blockAlign != d.chans*d.bits/8 {
+		return errors.New("inconsistent wav block alignment")
+	}
+	return nil
+}
+
+func (d *wavDecoder) sampleRate() int { return d.rate }
+func (d *wavDecoder) channels() int   { return d.chans }
+
+func (d *wavDecoder) read(buf []float64) (int, error) {
+	frames := len(buf) / d.chans
+	want := int64(frames * d.blockAlign)
+	if d.remaining >= 0 && want > d.remaining {
+		want = d.remaining
+	}
+	if want == 0 {
+		return 0, io.EOF
+	}
+	if int64(cap(d.raw)) < want {
+		d.raw = make([]byte, want)
+	}
+	raw := d.raw[:want]
+	n, err := io.ReadFull(d.r, raw)
+	n -= n % d.blockAlign
+	if d.remaining >= 0 {
+		d.remaining -= int64(n)
+	}
+	width := d.bits / 8
+	samples := n / width
+	for i := 0; i < samples; i++ {
+		b := raw[i*width : (i+1)*width]
+		switch {
+		case d.format == wavFormatFloat && width == 4:
+			buf[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case d.format == wavFormatFloat:
+			buf[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case width == 1:
+			buf[i] = (float64(b[0]) - 128) / 128
+		case width == 2:
+			buf[i] = float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		case width == 3:
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			buf[i] = float64(v) / (1 << 23)
+		default:
+			buf[i] = float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		}
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = io.EOF
+	}
+	return samples, err
+}
+
+// flacDecoder walks FLAC frames and interleaves their subframes.
+type flacDecoder struct {
+	stream *flac.Stream
+	frame  *frame.Frame
+	pos    int
+	scale  float64
+}
+
+func newFLACDecoder(r io.Reader) (*flacDecoder, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, err
+	}
+	if n := stream.Info.NChannels; n == 0 || n > maxChannels {
+		return nil, fmt.Errorf("flac has %d channels; 1 to %d are supported", n, maxChannels)
+	}
+	if bps := stream.Info.BitsPerSample; bps < 4 || bps > 32 {
+		return nil, fmt.Errorf("unsupported flac sample size (%d bits)", bps)
+	}
+	return &flacDecoder{stream: stream, scale: 1 / float64(int64(1)<<(stream.Info.BitsPerSample-1))}, nil
+}
+
+func (d *flacDecoder) sampleRate() int { return int(d.stream.Info.SampleRate) }
+func (d *flacDecoder) channels() int   { return int(d.stream.Info.NChannels) }
+
+func (d *flacDecoder) read(buf []float64) (int, error) {
+	chans := d.channels()
+	n := 0
+	for n+chans <= len(buf) {
+		if d.frame == nil || d.pos >= len(d.frame.Subframes[0].Samples) {
+			f, err := d.stream.ParseNext()
+			if err != nil {
+				return n, err
+			}
+			if err := checkFLACFrame(f, chans); err != nil {
+				return n, err
+			}
+			d.frame, d.pos = f, 0
+		}
+		for c := 0; c < chans; c++ {
+			buf[n+c] = float64(d.frame.Subframes[c].Samples[d.pos]) * d.scale
+		}
+		d.pos++
+		n += chans
+	}
+	return n, nil
+}
+
+// checkFLACFrame rejects frames that disagree with the stream header, which the decoder
+// does not check and read would index past.
+func checkFLACFrame(f *frame.Frame, chans int) error {
+	if len(f.Subframes) != chans {
+		return fmt.Errorf("flac frame has %d channels, stream has %d", len(f.Subframes), chans)
+	}
+	for _, sub := range f.Subframes[1:] {
+		if len(sub.Samples) != len(f.Subframes[0].Samples) {
+			return errors.New("flac frame channels differ in length")
+		}
+	}
+	return nil
+}
+
+// mp3Decoder wraps go-mp3, which always produces 16-bit little-endian stereo.
+type mp3Decoder struct {
+	dec *mp3.Decoder
+	raw []byte
+}
+
+func newMP3Decoder(r io.Reader) (*mp3Decoder, error) {
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return &mp3Decoder{dec: dec}, nil
+}
+
+func (d *mp3Decoder) sampleRate() int { return d.dec.SampleRate() }
+func (d *mp3Decoder) channels() int   { return 2 }
+
+func (d *mp3Decoder) read(buf []float64) (int, error) {
+	want := len(buf) / 2 * 4
+	if cap(d.raw) < want {
+		d.raw = make([]byte, want)
+	}
+	raw := d.raw[:want]
+	n, err := io.ReadFull(d.dec, raw)
+	n -= n % 4
+	samples := n / 2
+	for i := 0; i < samples; i++ {
+		buf[i] = float64(int16(binary.LittleEndian.Uint16(raw[i*2:]))) / (1 << 15)
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return samples, err
+}
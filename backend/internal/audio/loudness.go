@@ -0,0 +1,155 @@
+package audio
+
+import "math"
+
+// biquad is a direct form I second order IIR filter section.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the BS.1770 pre-filter (high shelf) and RLB high-pass for a
+// sample rate, using the analog prototypes from libebur128 so any rate is supported.
+func kWeighting(rate int) (biquad, biquad) {
+	fs := float64(rate)
+
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highpass := biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highpass
+}
+
+// loudnessMeter accumulates K-weighted energy in 100ms steps per channel; the
+// overlapping 400ms gating blocks are assembled from those steps at the end.
+type loudnessMeter struct {
+	stepLen int
+	inStep  int
+	shelf   []biquad
+	hp      []biquad
+	acc     []float64
+	steps   [][]float64
+	weights []float64
+}
+
+func newLoudnessMeter(rate, chans int) *loudnessMeter {
+	m := &loudnessMeter{
+		stepLen: int(math.Round(float64(rate) / 10)),
+		shelf:   make([]biquad, chans),
+		hp:      make([]biquad, chans),
+		acc:     make([]float64, chans),
+		steps:   make([][]float64, chans),
+		weights: make([]float64, chans),
+	}
+	for c := 0; c < chans; c++ {
+		m.shelf[c], m.hp[c] = kWeighting(rate)
+		m.weights[c] = channelWeight(c, chans)
+	}
+	return m
+}
+
+// channelWeight follows BS.1770: surrounds count 1.41, LFE is excluded (5.1 layout).
+func channelWeight(ch, chans int) float64 {
+	if chans == 6 {
+		switch ch {
+		case 3:
+			return 0
+		case 4, 5:
+			return 1.41
+		}
+	}
+	return 1
+}
+
+func (m *loudnessMeter) add(frame []float64) {
+	for c, v := range frame {
+		y := m.hp[c].process(m.shelf[c].process(v))
+		m.acc[c] += y * y
+	}
+	if m.inStep++; m.inStep == m.stepLen {
+		for c := range m.acc {
+			m.steps[c] = append(m.steps[c], m.acc[c])
+			m.acc[c] = 0
+		}
+		m.inStep = 0
+	}
+}
+
+// useChannels restricts measurement to the first n channels (used for upmixed mono).
+func (m *loudnessMeter) useChannels(n int) {
+	m.steps = m.steps[:n]
+	m.weights = m.weights[:n]
+}
+
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// integrated applies the absolute (-70 LUFS) and relative (-10 LU) gates.
+func (m *loudnessMeter) integrated() *float64 {
+	nsteps := len(m.steps[0])
+	if nsteps < 4 {
+		return nil
+	}
+	blocks := make([]float64, 0, nsteps-3)
+	norm := float64(4 * m.stepLen)
+	for j := 0; j+4 <= nsteps; j++ {
+		var power float64
+		for c := range m.steps {
+			s := m.steps[c]
+			power += m.weights[c] * (s[j] + s[j+1] + s[j+2] + s[j+3]) / norm
+		}
+		blocks = append(blocks, power)
+	}
+
+	gatedMean := func(threshold float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, p := range blocks {
+			if p > 0 && blockLoudness(p) > threshold {
+				sum += p
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return sum / float64(n), n
+	}
+
+	mean, n := gatedMean(-70)
+	if n == 0 {
+		return nil
+	}
+	relative := blockLoudness(mean) - 10
+	mean, n = gatedMean(math.Max(relative, -70))
+	if n == 0 {
+		return nil
+	}
+	lufs := math.Round(blockLoudness(mean)*100) / 100
+	return &lufs
+}
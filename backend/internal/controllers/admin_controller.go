@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+// AdminController serves /api/v1/admin. Routes are gated by RequireRole; moderators can
+// review and hide content, admins can also change roles and delete accounts.
+type AdminController struct {
+	Svc *services.AdminService
+}
+
+func NewAdminController(db *gorm.DB) *AdminController {
+	return &AdminController{Svc: services.NewAdminService(db)}
+}
+
+func writeAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrSelfDemotion):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+func (a *AdminController) ListUsers(c *gin.Context) {
+	var f services.AdminUserFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	users, total, err := a.Svc.ListUsers(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": users, "total": total})
+}
+
+func (a *AdminController) GetUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	u, err := a.Svc.GetUser(id)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// UpdateUser edits profile visibility and fields; role changes need the admin role.
+func (a *AdminController) UpdateUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req services.AdminUserUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role != nil && !models.Role(c.GetString("user_role")).AtLeast(models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required to change roles"})
+		return
+	}
+	u, err := a.Svc.UpdateUser(c.GetUint("user_id"), id, req)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (a *AdminController) DeleteUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := a.Svc.DeleteUser(c.GetUint("user_id"), id); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *AdminController) ListRSVPs(c *gin.Context) {
+	var page services.AdminPage
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	items, total, err := a.Svc.ListRSVPs(c.Query("q"), page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+func (a *AdminController) DeleteRSVP(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := a.Svc.DeleteRSVP(id); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *AdminController) ListProjects(c *gin.Context) {
+	var f services.AdminProjectFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	items, total, err := a.Svc.ListProjects(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+func (a *AdminController) UpdateProject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req services.AdminProjectUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := a.Svc.UpdateProject(id, req)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (a *AdminController) DeleteProject(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := a.Svc.DeleteProject(id); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+type APIKeyController struct {
+	Svc *services.APIKeyService
+}
+
+func NewAPIKeyController(db *gorm.DB) *APIKeyController {
+	return &APIKeyController{Svc: services.NewAPIKeyService(db)}
+}
+
+// Create returns the plaintext key once, alongside its stored record.
+func (a *APIKeyController) Create(c *gin.Context) {
+	var req services.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, plain, err := a.Svc.Create(c.GetUint("user_id"), req)
+	if errors.Is(err, services.ErrTooManyAPIKeys) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": plain, "apiKey": key})
+}
+
+func (a *APIKeyController) List(c *gin.Context) {
+	keys, err := a.Svc.ListByUser(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (a *APIKeyController) Revoke(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := a.Svc.Revoke(c.GetUint("user_id"), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// multipartOverhead is the allowance on top of the file limit for multipart boundaries and headers.
+const multipartOverhead = 1 << 20
+
+type AudioController struct {
+	Svc      *services.AudioService
+	Playback *services.PlaybackService
+}
+
+func NewAudioController(db *gorm.DB, store storage.BlobStore, maxBytes int64, playback *services.PlaybackService, analysis *services.AnalysisService) *AudioController {
+	svc := services.NewAudioService(db, store, maxBytes)
+	svc.Analysis = analysis
+	return &AudioController{Svc: svc, Playback: playback}
+}
+
+// parseIDParam reads a numeric path parameter, writing a 400 response when it is malformed.
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id64, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// Upload accepts a multipart/form-data body with the audio in the "file" field.
+// Parts are streamed straight to the blob store without being spooled to disk.
+func (a *AudioController) Upload(c *gin.Context) {
+	if a.Svc.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return
+	}
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, a.Svc.MaxBytes+multipartOverhead)
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected multipart/form-data body"})
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field required"})
+			return
+		}
+		if err != nil {
+			writeUploadError(c, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		af, err := a.Svc.Upload(c.Request.Context(), uid, id, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			writeUploadError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, af)
+		return
+	}
+}
+
+func writeUploadError(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrAudioTooLarge), errors.As(err, &maxErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrAudioTooLarge.Error()})
+	case errors.Is(err, services.ErrUnsupportedAudio):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+func (a *AudioController) ListByProject(c *gin.Context) {
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	items, err := a.Svc.ListByProject(uid, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.Playback.Sign(c.Request.Context(), items)
+	c.JSON(http.StatusOK, items)
+}
+
+// PlaybackURL returns a fresh signed URL for one file, e.g. when a cached link has expired.
+func (a *AudioController) PlaybackURL(c *gin.Context) {
+	if a.Playback == nil || a.Playback.Signer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return
+	}
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	audioID, ok := parseIDParam(c, "audioId")
+	if !ok {
+		return
+	}
+	af, err := a.Svc.Get(uid, id, audioID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audio not found"})
+		return
+	}
+	files := []models.AudioFile{*af}
+	a.Playback.Sign(c.Request.Context(), files)
+	if files[0].URL == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign url"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": files[0].URL, "expiresAt": files[0].URLExpiresAt})
+}
+
+func (a *AudioController) Delete(c *gin.Context) {
+	if a.Svc.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return
+	}
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	audioID, ok := parseIDParam(c, "audioId")
+	if !ok {
+		return
+	}
+	if err := a.Svc.Delete(c.Request.Context(), uid, id, audioID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
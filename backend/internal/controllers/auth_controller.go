@@ -1,26 +1,35 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/uploadparty/app/internal/emails"
+	"github.com/uploadparty/app/internal/middlewares"
 	"github.com/uploadparty/app/internal/services"
 )
 
 type AuthController struct {
-	Users *services.UserService
+	Users    *services.UserService
+	Sessions *services.SessionService
 }
 
-func NewAuthController(db *gorm.DB, secret string) *AuthController {
-	return &AuthController{Users: services.NewUserService(db, secret)}
+func NewAuthController(db *gorm.DB, secret string, sessions *services.SessionService) *AuthController {
+	return &AuthController{Users: services.NewUserService(db, secret), Sessions: sessions}
 }
 
 type registerReq struct {
 	Email    string `json:"email" binding:"required,email"`
 	Username string `json:"username" binding:"required,alphanum,min=3,max=20"`
 	Password string `json:"password" binding:"required,min=6"`
+	Locale   string `json:"locale"` // optional; defaults to Accept-Language
+}
+
+type refreshReq struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
 type loginReq struct {
@@ -34,7 +43,11 @@ func (a *AuthController) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	u, err := a.Users.Register(req.Email, req.Username, req.Password)
+	u, err := a.Users.Register(req.Email, req.Username, req.Password, requestLocale(c, req.Locale))
+	if errors.Is(err, services.ErrHandleTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -48,12 +61,78 @@ func (a *AuthController) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	token, user, err := a.Users.Authenticate(req.Identifier, req.Password)
+	user, err := a.Users.Authenticate(req.Identifier, req.Password)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": gin.H{"id": user.ID, "email": user.Email, "username": user.Username}})
+	pair, err := a.Sessions.Issue(user.ID, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":            pair.AccessToken,
+		"expiresAt":        pair.AccessExpiresAt,
+		"refreshToken":     pair.RefreshToken,
+		"refreshExpiresAt": pair.RefreshExpiresAt,
+		"user":             gin.H{"id": user.ID, "email": user.Email, "username": user.Username},
+	})
+}
+
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// requestLocale is the locale a client asked for, else the one its Accept-Language prefers.
+func requestLocale(c *gin.Context, explicit string) string {
+	if l, ok := emails.NormalizeLocale(explicit); ok {
+		return l
+	}
+	return emails.PreferredLocale(c.GetHeader("Accept-Language"))
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh token stops working.
+func (a *AuthController) Refresh(c *gin.Context) {
+	var req refreshReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pair, err := a.Sessions.Refresh(req.RefreshToken, clientInfo(c))
+	if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh session"})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout ends the caller's current session and revokes the access token used for the call.
+func (a *AuthController) Logout(c *gin.Context) {
+	p, _ := middlewares.CurrentPrincipal(c)
+	if err := a.Sessions.Logout(p.UserID, p.SessionID, p.TokenID, p.TokenExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll ends every session of the caller on every device.
+func (a *AuthController) LogoutAll(c *gin.Context) {
+	p, _ := middlewares.CurrentPrincipal(c)
+	if err := a.Sessions.LogoutAll(p.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	if err := a.Sessions.Logout(p.UserID, "", p.TokenID, p.TokenExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
 type syncUserReq struct {
@@ -62,6 +141,7 @@ type syncUserReq struct {
 	Username    string `json:"username" binding:"required"`
 	DisplayName string `json:"display_name"`
 	Picture     string `json:"picture"`
+	Locale      string `json:"locale"` // optional; defaults to Accept-Language
 }
 
 // SyncUser creates or updates a user from Auth0 authentication
@@ -72,8 +152,21 @@ func (a *AuthController) SyncUser(c *gin.Context) {
 		return
 	}
 
-	user, err := a.Users.SyncAuth0User(req.Auth0ID, req.Email, req.Username, req.DisplayName, req.Picture)
-	if err != nil {
+	// When the route is behind RequireAuth0, only the token's own subject may be synced.
+	if sub := c.GetString("auth0_id"); sub != "" && sub != req.Auth0ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "auth0_id does not match token"})
+		return
+	}
+
+	user, err := a.Users.SyncAuth0User(req.Auth0ID, req.Email, req.Username, req.DisplayName, req.Picture, requestLocale(c, req.Locale))
+	switch {
+	case errors.Is(err, services.ErrHandleTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidHandle), errors.Is(err, services.ErrHandleReserved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sync user"})
 		return
 	}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+type ChallengeController struct {
+	Svc      *services.ChallengeService
+	Votes    *services.VotingService
+	Playback *services.PlaybackService
+}
+
+func NewChallengeController(db *gorm.DB, playback *services.PlaybackService) *ChallengeController {
+	return &ChallengeController{Svc: services.NewChallengeService(db), Votes: services.NewVotingService(db), Playback: playback}
+}
+
+type announceWinnersReq struct {
+	Winners []services.Placement `json:"winners" binding:"required,min=1"`
+}
+
+func writeChallengeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrChallengeNotOpen), errors.Is(err, services.ErrChallengeNotClosed), errors.Is(err, services.ErrEntryLocked),
+		errors.Is(err, services.ErrVotingClosed), errors.Is(err, services.ErrVotingNotClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSelfVote):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// entryView exposes only public entrant fields; models.User carries email and auth ids.
+func (ch *ChallengeController) entryView(c *gin.Context, e *models.ChallengeEntry) gin.H {
+	view := gin.H{
+		"id":           e.ID,
+		"projectId":    e.ProjectID,
+		"projectTitle": e.Project.Title,
+		"user":         gin.H{"id": e.User.ID, "username": e.User.Username, "displayName": e.User.DisplayName},
+		"locked":       e.Locked,
+		"placement":    e.Placement,
+		"createdAt":    e.CreatedAt,
+	}
+	if e.AudioFile != nil {
+		files := []models.AudioFile{*e.AudioFile}
+		ch.Playback.Sign(c.Request.Context(), files)
+		view["audio"] = files[0]
+	}
+	return view
+}
+
+func (ch *ChallengeController) List(c *gin.Context) {
+	items, err := ch.Svc.List(c.Query("phase"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// Get accepts a numeric id or a slug.
+func (ch *ChallengeController) Get(c *gin.Context) {
+	challenge, err := ch.Svc.Get(c.Param("id"))
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	entries := make([]gin.H, 0, len(challenge.Entries))
+	for i := range challenge.Entries {
+		entries = append(entries, ch.entryView(c, &challenge.Entries[i]))
+	}
+	challenge.Entries = nil
+	c.JSON(http.StatusOK, gin.H{"challenge": challenge, "entries": entries})
+}
+
+func (ch *ChallengeController) Create(c *gin.Context) {
+	var req services.ChallengeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, err := ch.Svc.Create(c.GetUint("user_id"), req)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, challenge)
+}
+
+func (ch *ChallengeController) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req services.ChallengeInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, err := ch.Svc.Update(id, req)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, challenge)
+}
+
+func (ch *ChallengeController) Submit(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req services.SubmitEntryInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := ch.Svc.Submit(c.GetUint("user_id"), id, req)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+func (ch *ChallengeController) Withdraw(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := ch.Svc.Withdraw(c.GetUint("user_id"), id); err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ch *ChallengeController) AnnounceWinners(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req announceWinnersReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := ch.Svc.AnnounceWinners(id, req.Winners); err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	ch.Get(c)
+}
+
+// CastBallot replaces the caller's ballot; entryIds are in order of preference.
+func (ch *ChallengeController) CastBallot(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req services.BallotInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	votes, err := ch.Votes.CastBallot(c.GetUint("user_id"), id, req)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, votes)
+}
+
+func (ch *ChallengeController) Ballot(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	votes, err := ch.Votes.Ballot(c.GetUint("user_id"), id)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, votes)
+}
+
+func (ch *ChallengeController) RetractBallot(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := ch.Votes.Retract(c.GetUint("user_id"), id); err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Results serves the leaderboard once voting has closed. ?mode=count|bayesian
+func (ch *ChallengeController) Results(c *gin.Context) {
+	ch.results(c, false)
+}
+
+// LiveResults lets admins watch the tally while voting is still open.
+func (ch *ChallengeController) LiveResults(c *gin.Context) {
+	ch.results(c, true)
+}
+
+func (ch *ChallengeController) results(c *gin.Context, live bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	res, err := ch.Votes.Results(id, c.Query("mode"), live)
+	if err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (ch *ChallengeController) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := ch.Svc.Delete(id); err != nil {
+		writeChallengeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+type CommentController struct {
+	Svc *services.CommentService
+}
+
+func NewCommentController(db *gorm.DB, mailer services.CommentMailer, linkBase string) *CommentController {
+	svc := services.NewCommentService(db)
+	svc.Mailer = mailer
+	svc.LinkBase = linkBase
+	return &CommentController{Svc: svc}
+}
+
+func writeCommentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrNotCommentAuthor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCommentGone):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// List pages through the comment threads of a public project (?sort=newest|oldest&limit=&cursor=).
+func (cc *CommentController) List(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var f services.CommentFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := cc.Svc.List(id, f)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (cc *CommentController) Create(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var in services.CommentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := cc.Svc.Create(c.GetUint("user_id"), id, in)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, v)
+}
+
+// Update edits the caller's own comment.
+func (cc *CommentController) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var in services.CommentEdit
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := cc.Svc.Update(c.GetUint("user_id"), id, in)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// Delete takes back the caller's own comment.
+func (cc *CommentController) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := cc.Svc.Delete(c.GetUint("user_id"), id); err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Remove hides a comment as a moderator; the body may carry {reason}.
+func (cc *CommentController) Remove(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := cc.Svc.Remove(c.GetUint("user_id"), id, in.Reason); err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+// DeviceController runs the device login used by the VST plugin: the plugin starts a
+// request and polls, the user approves the shown code in the web app.
+type DeviceController struct {
+	Svc *services.DeviceAuthService
+}
+
+// NewDeviceController points users at <frontendURL>/device to enter codes.
+func NewDeviceController(db *gorm.DB, frontendURL string) *DeviceController {
+	return &DeviceController{Svc: services.NewDeviceAuthService(db, strings.TrimRight(frontendURL, "/")+"/device")}
+}
+
+type deviceStartReq struct {
+	ClientName string `json:"clientName"`
+}
+
+type deviceTokenReq struct {
+	DeviceCode string `json:"deviceCode" binding:"required"`
+}
+
+type deviceDecisionReq struct {
+	UserCode string `json:"userCode" binding:"required"`
+}
+
+// Start is called by the plugin; it shows userCode and verificationUri to the user.
+func (d *DeviceController) Start(c *gin.Context) {
+	var req deviceStartReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	resp, err := d.Svc.Start(req.ClientName, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start device login"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Token is polled by the plugin every interval seconds until it receives an API key.
+// Pending and failure states use the RFC 8628 error codes with status 400.
+func (d *DeviceController) Token(c *gin.Context) {
+	var req deviceTokenReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cred, err := d.Svc.Poll(req.DeviceCode)
+	switch {
+	case errors.Is(err, services.ErrAuthorizationPending), errors.Is(err, services.ErrSlowDown),
+		errors.Is(err, services.ErrAccessDenied), errors.Is(err, services.ErrExpiredToken),
+		errors.Is(err, services.ErrInvalidDeviceCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTooManyAPIKeys):
+		// Keys were added since approval. The code stays approved, so polling succeeds once
+		// the user revokes one.
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too_many_api_keys",
+			"error_description": err.Error() + "; revoke one in the app to finish connecting"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to complete device login"})
+	default:
+		c.JSON(http.StatusOK, cred)
+	}
+}
+
+// Lookup lets the web app show which client is asking before the user approves.
+func (d *DeviceController) Lookup(c *gin.Context) {
+	da, err := d.Svc.Lookup(c.Query("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "code not found or expired"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"userCode": da.UserCode, "clientName": da.ClientName, "createdAt": da.CreatedAt, "expiresAt": da.ExpiresAt})
+}
+
+func (d *DeviceController) Approve(c *gin.Context) { d.decide(c, true) }
+
+func (d *DeviceController) Deny(c *gin.Context) { d.decide(c, false) }
+
+func (d *DeviceController) decide(c *gin.Context, approve bool) {
+	var req deviceDecisionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	da, err := d.Svc.Decide(c.GetUint("user_id"), req.UserCode, approve)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "code not found or expired"})
+		return
+	}
+	if errors.Is(err, services.ErrTooManyAPIKeys) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "; revoke one to connect this device"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"userCode": da.UserCode, "clientName": da.ClientName, "status": da.Status})
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/uploadparty/app/internal/emails"
+)
+
+type EmailController struct {
+	Templates *emails.Renderer
+}
+
+func NewEmailController(templates *emails.Renderer) *EmailController {
+	return &EmailController{Templates: templates}
+}
+
+// List returns every email template with the locales it has a variant in.
+func (e *EmailController) List(c *gin.Context) {
+	infos, err := e.Templates.Templates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": infos, "defaultLocale": emails.DefaultLocale})
+}
+
+// Preview renders a template with sample data (?locale=&format=html|text). Without a format
+// it returns {subject, html, text, locale}; html and text return the body alone, so it can be
+// opened in a browser.
+func (e *EmailController) Preview(c *gin.Context) {
+	locale := c.Query("locale")
+	if locale != "" {
+		var ok bool
+		if locale, ok = emails.NormalizeLocale(locale); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid locale"})
+			return
+		}
+	}
+	msg, err := e.Templates.Preview(c.Param("name"), locale)
+	if errors.Is(err, emails.ErrUnknownTemplate) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err != nil {
+		// A broken override; show why so it can be fixed.
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("X-Email-Subject", msg.Subject)
+	c.Header("Content-Language", msg.Locale)
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.String(http.StatusOK, msg.Text)
+	case "":
+		c.JSON(http.StatusOK, msg)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or text"})
+	}
+}
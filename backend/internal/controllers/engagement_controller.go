@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+// EngagementController serves likes and play counting on public projects.
+type EngagementController struct {
+	Svc *services.EngagementService
+}
+
+func NewEngagementController(db *gorm.DB) *EngagementController {
+	return &EngagementController{Svc: services.NewEngagementService(db)}
+}
+
+// viewerOf identifies the caller for deduplication: the signed-in user, if any, or the IP.
+// ClientIP only honours X-Forwarded-For from the engine's trusted proxies (TRUSTED_PROXIES).
+func viewerOf(c *gin.Context) services.Viewer {
+	return services.Viewer{UserID: c.GetUint("user_id"), IP: c.ClientIP()}
+}
+
+func writeEngagementError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func (e *EngagementController) LikeState(c *gin.Context) {
+	e.like(c, e.Svc.LikeState)
+}
+
+func (e *EngagementController) Like(c *gin.Context) {
+	e.like(c, e.Svc.Like)
+}
+
+func (e *EngagementController) Unlike(c *gin.Context) {
+	e.like(c, e.Svc.Unlike)
+}
+
+func (e *EngagementController) like(c *gin.Context, do func(userID, projectID uint) (*services.LikeState, error)) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	st, err := do(c.GetUint("user_id"), id)
+	if err != nil {
+		writeEngagementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// RecordPlay counts a play when playback of a public project starts. Repeats by the same
+// listener within the dedup window are accepted but not counted.
+func (e *EngagementController) RecordPlay(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	counted, err := e.Svc.RecordPlay(id, viewerOf(c), time.Now())
+	if err != nil {
+		writeEngagementError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"counted": counted})
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+type FeedController struct {
+	Svc *services.FeedService
+}
+
+func NewFeedController(db *gorm.DB) *FeedController {
+	return &FeedController{Svc: services.NewFeedService(db)}
+}
+
+// Feed pages through recent activity of the people the caller follows (?limit=&cursor=).
+func (f *FeedController) Feed(c *gin.Context) {
+	var page services.CursorPage
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := f.Svc.Feed(c.GetUint("user_id"), page)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
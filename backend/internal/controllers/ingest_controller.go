@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/services"
+)
+
+// IngestController serves batch ingest for plugins flushing their offline queue.
+type IngestController struct {
+	Svc *services.IngestBatchService
+}
+
+func NewIngestController(db *gorm.DB) *IngestController {
+	return &IngestController{Svc: services.NewIngestBatchService(db)}
+}
+
+type batchReq struct {
+	Items []services.BatchItem `json:"items" binding:"required"`
+}
+
+// Batch applies queued project upserts, plugin upserts and completions in order and reports
+// each item's outcome. API keys need the scope of every item type they send.
+func (i *IngestController) Batch(c *gin.Context) {
+	var req batchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if p, ok := middlewares.CurrentPrincipal(c); ok {
+		for _, it := range req.Items {
+			if !p.Allows(it.Scope()) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + it.Scope()})
+				return
+			}
+		}
+	}
+	res, err := i.Svc.Apply(c.GetUint("user_id"), req.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// MediaController streams objects from the local store for HMAC-signed URLs.
+// With GCS, clients are handed V4 signed URLs and never reach this handler.
+type MediaController struct {
+	Store  *storage.LocalStore
+	Signer *storage.HMACSigner
+}
+
+func NewMediaController(store storage.BlobStore, signer storage.URLSigner) *MediaController {
+	local, _ := store.(*storage.LocalStore)
+	hmacSigner, _ := signer.(*storage.HMACSigner)
+	return &MediaController{Store: local, Signer: hmacSigner}
+}
+
+// Stream serves the object with HTTP Range support so players can seek.
+func (m *MediaController) Stream(c *gin.Context) {
+	if m.Store == nil || m.Signer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := m.Signer.Verify(key, c.Query("expires"), c.Query("sig")); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, storage.ErrURLExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	f, fi, err := m.Store.OpenFile(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	defer f.Close()
+	c.Header("Content-Type", services.AudioContentType(key))
+	c.Header("Cache-Control", "private, max-age=300")
+	http.ServeContent(c.Writer, c.Request, fi.Name(), fi.ModTime(), f)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+// PluginCatalogController serves public plugin usage stats and staff catalog curation.
+type PluginCatalogController struct {
+	Svc *services.PluginCatalogService
+}
+
+func NewPluginCatalogController(db *gorm.DB) *PluginCatalogController {
+	return &PluginCatalogController{Svc: services.NewPluginCatalogService(db)}
+}
+
+// Top lists the most used plugins. period is "month" (the current calendar month, UTC),
+// "year" (the last 365 days) or "all".
+func (p *PluginCatalogController) Top(c *gin.Context) {
+	now := time.Now()
+	var since time.Time
+	switch c.DefaultQuery("period", "month") {
+	case "month":
+		since = services.MonthStart(now)
+	case "year":
+		since = now.AddDate(-1, 0, 0)
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be month, year or all"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	items, err := p.Svc.Top(since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// Get returns one plugin's usage counts by slug or any known spelling of its name.
+func (p *PluginCatalogController) Get(c *gin.Context) {
+	st, err := p.Svc.Stats(c.Param("slug"), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "plugin not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+func (p *PluginCatalogController) List(c *gin.Context) {
+	var f services.CatalogFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	items, total, err := p.Svc.List(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+func (p *PluginCatalogController) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var in services.CatalogUpdate
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := p.Svc.Update(id, in)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+type addAliasReq struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+func (p *PluginCatalogController) AddAlias(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req addAliasReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := p.Svc.AddAlias(id, req.Alias)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+type mergeCatalogReq struct {
+	SourceID uint `json:"sourceId" binding:"required"`
+}
+
+// Merge folds another entry into this one, e.g. when two spellings turn out to be the same plugin.
+func (p *PluginCatalogController) Merge(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req mergeCatalogReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := p.Svc.Merge(id, req.SourceID)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
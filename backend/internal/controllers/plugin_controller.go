@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -61,3 +62,55 @@ func (p *PluginController) ListByProject(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, items)
 }
+
+// syncPluginsReq is the complete plugin list of a project as the VST sees it.
+type syncPluginsReq struct {
+	Plugins []upsertPluginReq `json:"plugins" binding:"required,dive"`
+}
+
+// SyncForProject diffs the submitted plugin list against the stored one.
+func (p *PluginController) SyncForProject(c *gin.Context) {
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var req syncPluginsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	set := make([]services.UpsertPluginInput, 0, len(req.Plugins))
+	for _, r := range req.Plugins {
+		set = append(set, services.UpsertPluginInput{Name: r.Name, Vendor: r.Vendor, Version: r.Version, Format: r.Format, Metadata: []byte(r.Metadata)})
+	}
+	res, err := p.Svc.Sync(uid, id, set)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// History lists the project's plugin additions, removals and version changes, newest first.
+func (p *PluginController) History(c *gin.Context) {
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	items, err := p.Svc.History(uid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
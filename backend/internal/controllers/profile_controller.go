@@ -1,37 +1,312 @@
 package controllers
 
 import (
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/uploadparty/app/internal/imaging"
+	"github.com/uploadparty/app/internal/models"
 	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
 )
 
 type ProfileController struct {
-	Users    *services.UserService
-	Projects *services.ProjectService
+	Users   *services.UserService
+	Me      *services.ProfileService
+	Follows *services.FollowService
+	// Engagement counts profile views; nil disables counting.
+	Engagement *services.EngagementService
+	Projects   *services.ProjectService
+	Catalog    *services.PluginCatalogService
+	Playback   *services.PlaybackService
 }
 
-func NewProfileController(db *gorm.DB, secret string) *ProfileController {
-	return &ProfileController{Users: services.NewUserService(db, secret), Projects: services.NewProjectService(db)}
+func NewProfileController(db *gorm.DB, secret string, playback *services.PlaybackService) *ProfileController {
+	return &ProfileController{Users: services.NewUserService(db, secret), Me: services.NewProfileService(db), Follows: services.NewFollowService(db), Projects: services.NewProjectService(db), Catalog: services.NewPluginCatalogService(db), Playback: playback}
 }
 
-func (p *ProfileController) GetPublicProfile(c *gin.Context) {
+// findProfile resolves the :handle param. An old handle is answered with a permanent
+// redirect to the same path under the current one, so shared links keep working.
+func (p *ProfileController) findProfile(c *gin.Context) (*models.User, bool) {
 	handle := c.Param("handle")
-	u, err := p.Users.FindPublicByHandle(handle)
+	u, moved, err := p.Users.ResolvePublicHandle(handle)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+		return nil, false
+	}
+	if moved {
+		target := "/profiles/" + u.Username + strings.TrimPrefix(c.Request.URL.Path, "/profiles/"+handle)
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, target)
+		return nil, false
+	}
+	return u, true
+}
+
+func (p *ProfileController) GetPublicProfile(c *gin.Context) {
+	u, ok := p.findProfile(c)
+	if !ok {
 		return
 	}
-	projects, err := p.Projects.ListPublicByUser(u.ID)
+	projects, err := p.Projects.ListPublicByUser(u.ID, services.ProjectFilter{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	counts, err := p.Follows.Counts(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load profile"})
+		return
+	}
+	if p.Engagement != nil {
+		if _, err := p.Engagement.RecordProfileView(u.ID, viewerOf(c), time.Now()); err != nil {
+			log.Printf("[profile] counting view of %d: %v", u.ID, err)
+		}
+	}
+	p.Playback.SignProjects(c.Request.Context(), projects.Items)
 	c.JSON(http.StatusOK, gin.H{
-		"user":     gin.H{"id": u.ID, "username": u.Username, "displayName": u.DisplayName, "bio": u.Bio},
-		"projects": projects,
+		"user": gin.H{"id": u.ID, "username": u.Username, "displayName": u.DisplayName, "bio": u.Bio,
+			"picture": u.Picture, "links": u.Links, "followers": counts.Followers, "following": counts.Following,
+			"profileViews": u.ProfileViews},
+		"projects": projects.Items,
+		// More projects are at /profiles/:handle/projects?cursor=
+		"projectsNextCursor": projects.NextCursor,
 	})
 }
+
+// GetPublicProjects pages through a producer's public projects with the same filters as
+// the owner's own list.
+func (p *ProfileController) GetPublicProjects(c *gin.Context) {
+	u, ok := p.findProfile(c)
+	if !ok {
+		return
+	}
+	var f services.ProjectFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := p.Projects.ListPublicByUser(u.ID, f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Playback.SignProjects(c.Request.Context(), page.Items)
+	c.JSON(http.StatusOK, page)
+}
+
+// GetPublicPlugins lists the plugins a producer uses across their public projects.
+func (p *ProfileController) GetPublicPlugins(c *gin.Context) {
+	u, ok := p.findProfile(c)
+	if !ok {
+		return
+	}
+	items, err := p.Catalog.ByProducer(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetFollowers pages through the public users following a producer, most recent first.
+func (p *ProfileController) GetFollowers(c *gin.Context) {
+	p.followList(c, p.Follows.Followers)
+}
+
+// GetFollowing pages through the public users a producer follows, most recent first.
+func (p *ProfileController) GetFollowing(c *gin.Context) {
+	p.followList(c, p.Follows.Following)
+}
+
+func (p *ProfileController) followList(c *gin.Context, list func(uint, services.CursorPage) (*services.Page[services.FollowedUser], error)) {
+	u, ok := p.findProfile(c)
+	if !ok {
+		return
+	}
+	var page services.CursorPage
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := list(u.ID, page)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// followTarget resolves :handle for the follow endpoints; old handles follow the current owner.
+func (p *ProfileController) followTarget(c *gin.Context) (*models.User, bool) {
+	u, _, err := p.Users.ResolvePublicHandle(c.Param("handle"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+		return nil, false
+	}
+	return u, true
+}
+
+// writeFollowState answers the follow endpoints with whether the caller follows u.
+func (p *ProfileController) writeFollowState(c *gin.Context, u *models.User, following bool) {
+	counts, err := p.Follows.Counts(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count followers"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"following": following, "followers": counts.Followers})
+}
+
+// FollowState tells the caller whether they follow a producer.
+func (p *ProfileController) FollowState(c *gin.Context) {
+	u, ok := p.followTarget(c)
+	if !ok {
+		return
+	}
+	following, err := p.Follows.IsFollowing(c.GetUint("user_id"), u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load follow"})
+		return
+	}
+	p.writeFollowState(c, u, following)
+}
+
+func (p *ProfileController) Follow(c *gin.Context) {
+	u, ok := p.followTarget(c)
+	if !ok {
+		return
+	}
+	if err := p.Follows.Follow(c.GetUint("user_id"), u.ID); err != nil {
+		writeProfileError(c, err)
+		return
+	}
+	p.writeFollowState(c, u, true)
+}
+
+func (p *ProfileController) Unfollow(c *gin.Context) {
+	u, ok := p.followTarget(c)
+	if !ok {
+		return
+	}
+	if err := p.Follows.Unfollow(c.GetUint("user_id"), u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unfollow"})
+		return
+	}
+	p.writeFollowState(c, u, false)
+}
+
+// GetMe returns the caller's own account and profile.
+func (p *ProfileController) GetMe(c *gin.Context) {
+	u, err := p.Me.Get(c.GetUint("user_id"))
+	if err != nil {
+		writeProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// UpdateMe edits profile fields, the handle and social links.
+func (p *ProfileController) UpdateMe(c *gin.Context) {
+	var in services.ProfileUpdate
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := p.Me.Update(c.GetUint("user_id"), in)
+	if err != nil {
+		writeProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// UploadAvatar takes an image in the multipart "file" field and makes it the caller's picture.
+func (p *ProfileController) UploadAvatar(c *gin.Context) {
+	if p.Me.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAvatarBytes+multipartOverhead)
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected multipart/form-data body"})
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field required"})
+			return
+		}
+		if err != nil {
+			writeProfileError(c, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		u, err := p.Me.SetAvatar(c.Request.Context(), c.GetUint("user_id"), part)
+		part.Close()
+		if err != nil {
+			writeProfileError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, u)
+		return
+	}
+}
+
+func (p *ProfileController) DeleteAvatar(c *gin.Context) {
+	if p.Me.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return
+	}
+	u, err := p.Me.RemoveAvatar(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		writeProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// Avatar serves an uploaded avatar. Names are unique per upload, so they cache forever.
+func (p *ProfileController) Avatar(c *gin.Context) {
+	if p.Me.Store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	rc, err := p.Me.OpenAvatar(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	defer rc.Close()
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.DataFromReader(http.StatusOK, -1, "image/jpeg", rc, nil)
+}
+
+func writeProfileError(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrHandleTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAvatarTooLarge), errors.As(err, &maxErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrAvatarTooLarge.Error()})
+	case errors.Is(err, imaging.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -10,19 +12,24 @@ import (
 	"github.com/uploadparty/app/internal/services"
 )
 
-type ProjectController struct{ Svc *services.ProjectService }
+type ProjectController struct {
+	Svc      *services.ProjectService
+	Playback *services.PlaybackService
+}
 
-func NewProjectController(db *gorm.DB) *ProjectController {
-	return &ProjectController{Svc: services.NewProjectService(db)}
+func NewProjectController(db *gorm.DB, playback *services.PlaybackService) *ProjectController {
+	return &ProjectController{Svc: services.NewProjectService(db), Playback: playback}
 }
 
 type upsertProjectReq struct {
-	Title           string  `json:"title" binding:"required"`
-	DAW             string  `json:"daw"`
-	PluginVersion   string  `json:"pluginVersion"`
-	DurationSeconds int     `json:"durationSeconds"`
-	Metadata        jsonRaw `json:"metadata"`
-	Public          *bool   `json:"public"`
+	UUID            string     `json:"uuid"` // stable id from the DAW session; title-only requests still work
+	Title           string     `json:"title"`
+	DAW             string     `json:"daw"`
+	PluginVersion   string     `json:"pluginVersion"`
+	DurationSeconds int        `json:"durationSeconds"`
+	Metadata        jsonRaw    `json:"metadata"`
+	Public          *bool      `json:"public"`
+	At              *time.Time `json:"at"` // RFC 3339; set by plugins replaying buffered heartbeats
 }
 
 type jsonRaw []byte
@@ -36,10 +43,10 @@ func (p *ProjectController) Upsert(c *gin.Context) {
 		return
 	}
 	uid := c.GetUint("user_id")
-	in := services.UpsertProjectInput{Title: req.Title, DAW: req.DAW, PluginVersion: req.PluginVersion, DurationSeconds: req.DurationSeconds, Metadata: []byte(req.Metadata), Public: req.Public}
-	proj, err := p.Svc.UpsertByTitle(uid, in)
+	in := services.UpsertProjectInput{UUID: req.UUID, Title: req.Title, DAW: req.DAW, PluginVersion: req.PluginVersion, DurationSeconds: req.DurationSeconds, Metadata: []byte(req.Metadata), Public: req.Public, At: req.At}
+	proj, err := p.Svc.Upsert(uid, in)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		writeProjectError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, proj)
@@ -61,12 +68,134 @@ func (p *ProjectController) MarkComplete(c *gin.Context) {
 	c.JSON(http.StatusOK, proj)
 }
 
+// writeProjectError maps project service errors to responses.
+func writeProjectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+	case errors.Is(err, services.ErrProjectDeleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// ListMine pages through the user's projects; see services.ProjectFilter for the query.
 func (p *ProjectController) ListMine(c *gin.Context) {
+	var f services.ProjectFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := p.Svc.ListByUser(c.GetUint("user_id"), f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Playback.SignProjects(c.Request.Context(), page.Items)
+	c.JSON(http.StatusOK, page)
+}
+
+// Sessions lists when the user worked on a project, newest first, with the total time.
+func (p *ProjectController) Sessions(c *gin.Context) {
+	uid := c.GetUint("user_id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	items, total, err := p.Svc.Sessions.ListByProject(uid, id, limit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "totalSeconds": total, "idleGapSeconds": int(p.Svc.Sessions.IdleGap / time.Second)})
+}
+
+// Heartbeats returns the raw heartbeats behind one session.
+func (p *ProjectController) Heartbeats(c *gin.Context) {
 	uid := c.GetUint("user_id")
-	ps, err := p.Svc.ListByUser(uid)
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	sessionID, ok := parseIDParam(c, "sessionId")
+	if !ok {
+		return
+	}
+	items, err := p.Svc.Sessions.ListHeartbeats(uid, id, sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (p *ProjectController) Get(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	proj, err := p.Svc.Get(c.GetUint("user_id"), id)
+	if err != nil {
+		writeProjectError(c, err)
+		return
+	}
+	p.Playback.Sign(c.Request.Context(), proj.AudioFiles)
+	c.JSON(http.StatusOK, proj)
+}
+
+// Update renames a project, toggles public or reopens it ({"status": "in_progress"}).
+func (p *ProjectController) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var in services.UpdateProjectInput
+	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, ps)
+	proj, err := p.Svc.Update(c.GetUint("user_id"), id, in)
+	if err != nil {
+		writeProjectError(c, err)
+		return
+	}
+	p.Playback.Sign(c.Request.Context(), proj.AudioFiles)
+	c.JSON(http.StatusOK, proj)
+}
+
+// Delete moves a project to the trash; see Restore.
+func (p *ProjectController) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := p.Svc.Delete(c.GetUint("user_id"), id); err != nil {
+		writeProjectError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (p *ProjectController) Restore(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	proj, err := p.Svc.Restore(c.GetUint("user_id"), id)
+	if err != nil {
+		writeProjectError(c, err)
+		return
+	}
+	p.Playback.Sign(c.Request.Context(), proj.AudioFiles)
+	c.JSON(http.StatusOK, proj)
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/uploadparty/app/internal/emails"
 	"github.com/uploadparty/app/internal/models"
 	"github.com/uploadparty/app/internal/services"
 )
@@ -72,6 +73,8 @@ func (r *RSVPController) Create(c *gin.Context) {
 
 	// Get client IP address
 	clientIP := c.ClientIP()
+	// Emails to this person, now and when they refer others, are sent in their language
+	locale := emails.PreferredLocale(c.GetHeader("Accept-Language"))
 
 	// Generate unique referral code
 	var referralCode string
@@ -89,7 +92,7 @@ func (r *RSVPController) Create(c *gin.Context) {
 	// Determine if email will be sent
 	emailSent := false
 	if r.EmailService != nil {
-		err := r.EmailService.SendRSVPConfirmation(req.Email)
+		err := r.EmailService.SendRSVPConfirmation(req.Email, req.FirstName, locale)
 		if err == nil {
 			emailSent = true
 		}
@@ -101,6 +104,7 @@ func (r *RSVPController) Create(c *gin.Context) {
 		LastName:       req.LastName,
 		EmailSent:      emailSent,
 		IPAddress:      clientIP,
+		Locale:         locale,
 		ReferralCode:   referralCode,
 		ReferredByCode: req.ReferralCode,
 		ReferredByID:   referrerID,
@@ -130,7 +134,7 @@ func (r *RSVPController) Create(c *gin.Context) {
 			newUserName = req.Email
 		}
 
-		// Build the referrer's name
+		// Build the referrer's name; without one the email skips it
 		referrerName := referrer.FirstName
 		if referrer.LastName != "" {
 			if referrerName != "" {
@@ -139,12 +143,9 @@ func (r *RSVPController) Create(c *gin.Context) {
 				referrerName = referrer.LastName
 			}
 		}
-		if referrerName == "" {
-			referrerName = "there"
-		}
 
 		// Send the notification (don't fail the request if email fails)
-		if err := r.EmailService.SendReferralNotification(referrer.Email, referrerName, newUserName); err != nil {
+		if err := r.EmailService.SendReferralNotification(referrer.Email, referrerName, newUserName, referrer.Locale); err != nil {
 			// Log but don't fail the request
 			// The email service will already log the error
 		}
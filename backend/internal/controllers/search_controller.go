@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+type SearchController struct {
+	Svc *services.SearchService
+}
+
+func NewSearchController(db *gorm.DB) *SearchController {
+	return &SearchController{Svc: services.NewSearchService(db)}
+}
+
+// Search answers ?q= with public projects, producers and plugins. ?type= narrows it to a
+// comma-separated subset; ?limit= caps each kind.
+func (s *SearchController) Search(c *gin.Context) {
+	var kinds []string
+	if t := c.Query("type"); t != "" {
+		kinds = strings.Split(t, ",")
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	res, err := s.Svc.Search(c.Query("q"), kinds, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
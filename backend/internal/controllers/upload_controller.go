@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// UploadController exposes a tus-style resumable upload protocol:
+// POST creates a session, PATCH appends a chunk at Upload-Offset, HEAD/GET report progress,
+// and POST .../finalize turns the assembled bytes into a project AudioFile.
+type UploadController struct{ Svc *services.UploadService }
+
+func NewUploadController(db *gorm.DB, store storage.BlobStore, maxTotal, maxChunk int64, analysis *services.AnalysisService) *UploadController {
+	audio := services.NewAudioService(db, store, maxTotal)
+	audio.Analysis = analysis
+	return &UploadController{Svc: services.NewUploadService(db, store, audio, maxTotal, maxChunk)}
+}
+
+type initUploadReq struct {
+	ProjectID  uint   `json:"projectId" binding:"required"`
+	Filename   string `json:"filename" binding:"required"`
+	TotalBytes int64  `json:"totalBytes" binding:"required,gt=0"`
+}
+
+func setUploadHeaders(c *gin.Context, sess *models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(sess.ReceivedBytes, 10))
+	c.Header("Upload-Length", strconv.FormatInt(sess.TotalBytes, 10))
+	c.Header("Cache-Control", "no-store")
+}
+
+func (u *UploadController) available(c *gin.Context) bool {
+	if u.Svc.Store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage not configured"})
+		return false
+	}
+	return true
+}
+
+func writeUploadSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+	case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadIncomplete), errors.Is(err, services.ErrUploadBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadClosed):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrChunkTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		writeUploadError(c, err)
+	}
+}
+
+func (u *UploadController) Create(c *gin.Context) {
+	if !u.available(c) {
+		return
+	}
+	var req initUploadReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := c.GetUint("user_id")
+	sess, err := u.Svc.Init(uid, services.InitUploadInput{ProjectID: req.ProjectID, Filename: req.Filename, TotalBytes: req.TotalBytes})
+	if err != nil {
+		writeUploadSessionError(c, err)
+		return
+	}
+	setUploadHeaders(c, sess)
+	c.Header("Location", c.Request.URL.Path+"/"+sess.ID)
+	c.JSON(http.StatusCreated, sess)
+}
+
+// Status answers both HEAD (headers only, as tus clients expect) and GET.
+func (u *UploadController) Status(c *gin.Context) {
+	uid := c.GetUint("user_id")
+	sess, err := u.Svc.Get(uid, c.Param("id"))
+	if err != nil {
+		writeUploadSessionError(c, err)
+		return
+	}
+	setUploadHeaders(c, sess)
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, sess)
+}
+
+// AppendChunk expects the raw chunk bytes as the body and the current offset in Upload-Offset.
+func (u *UploadController) AppendChunk(c *gin.Context) {
+	if !u.available(c) {
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header required"})
+		return
+	}
+	uid := c.GetUint("user_id")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, u.Svc.MaxChunkBytes+1)
+	sess, err := u.Svc.AppendChunk(c.Request.Context(), uid, c.Param("id"), offset, c.Request.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = services.ErrChunkTooLarge
+		}
+		if sess != nil {
+			setUploadHeaders(c, sess)
+		}
+		writeUploadSessionError(c, err)
+		return
+	}
+	setUploadHeaders(c, sess)
+	c.JSON(http.StatusOK, sess)
+}
+
+func (u *UploadController) Finalize(c *gin.Context) {
+	if !u.available(c) {
+		return
+	}
+	uid := c.GetUint("user_id")
+	af, err := u.Svc.Finalize(c.Request.Context(), uid, c.Param("id"))
+	if err != nil {
+		writeUploadSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, af)
+}
+
+func (u *UploadController) Abort(c *gin.Context) {
+	if !u.available(c) {
+		return
+	}
+	uid := c.GetUint("user_id")
+	if err := u.Svc.Abort(c.Request.Context(), uid, c.Param("id")); err != nil {
+		writeUploadSessionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,317 @@
+// Package emails renders transactional emails from templates.
+//
+// Every email has a text/template file with its subject and plain-text body and an
+// html/template file with its HTML body, one pair per locale, named <email>.<locale>.txt and
+// <email>.<locale>.html. Both are wrapped in the shared layout.<locale>.txt/.html. The
+// templates are embedded in the binary; a file of the same name in the override directory
+// replaces the embedded one, so copy can change without a deploy.
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var embedded embed.FS
+
+// DefaultLocale is used when an email has no variant in the recipient's language.
+const DefaultLocale = "en"
+
+// Template names.
+const (
+	RSVPConfirmation     = "rsvp_confirmation"
+	ReferralNotification = "referral_notification"
+	CommentNotification  = "comment_notification"
+	Welcome              = "welcome"
+)
+
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// RSVPData fills RSVPConfirmation.
+type RSVPData struct {
+	FirstName string // may be empty
+}
+
+// ReferralData fills ReferralNotification.
+type ReferralData struct {
+	ReferrerName string // may be empty
+	NewUserName  string
+}
+
+// CommentData fills CommentNotification.
+type CommentData struct {
+	Name          string // the recipient
+	CommenterName string
+	ProjectTitle  string
+	At            string // position in the track, e.g. "1:32"; empty for general comments
+	Reply         bool   // to the recipient's own comment
+	Body          string
+	URL           string
+}
+
+// WelcomeData fills Welcome.
+type WelcomeData struct {
+	Name string
+	URL  string // where the app starts
+}
+
+// samples are what previews are rendered with; every template needs one.
+var samples = map[string]any{
+	RSVPConfirmation: RSVPData{FirstName: "Alex"},
+	ReferralNotification: ReferralData{
+		ReferrerName: "Alex",
+		NewUserName:  "Sam <sam@example.com>",
+	},
+	CommentNotification: CommentData{
+		Name:          "Alex",
+		CommenterName: "Sam",
+		ProjectTitle:  "Late Night Sketch",
+		At:            "1:32",
+		Body:          "The drop at 1:32 is <b>muddy</b> & a bit loud.\nLove the chords though!",
+		URL:           "https://uploadparty.example/projects/1#comment-1",
+	},
+	Welcome: WelcomeData{Name: "Alex", URL: "https://uploadparty.example"},
+}
+
+// Message is a rendered email.
+type Message struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+	Locale  string `json:"locale"` // the variant the body came from
+}
+
+// Renderer renders emails from the embedded templates and the override directory. Templates
+// are read on every render, so edits to overrides apply to the next email sent.
+type Renderer struct {
+	dir fs.FS // nil without an override directory
+}
+
+// New returns a renderer that prefers templates found in dir; dir may be empty.
+func New(dir string) *Renderer {
+	r := &Renderer{}
+	if dir != "" {
+		r.dir = os.DirFS(dir)
+	}
+	return r
+}
+
+func (r *Renderer) read(file string) ([]byte, error) {
+	if r.dir != nil {
+		b, err := fs.ReadFile(r.dir, file)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return b, err
+		}
+	}
+	return fs.ReadFile(embedded, "templates/"+file)
+}
+
+// lookup reads the best variant of name+ext for locale: the locale itself, its language,
+// then DefaultLocale.
+func (r *Renderer) lookup(name, locale, ext string) (src []byte, used string, err error) {
+	for _, l := range fallbacks(locale) {
+		src, err = r.read(name + "." + l + ext)
+		if err == nil {
+			return src, l, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, "", err
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+}
+
+func fallbacks(locale string) []string {
+	var out []string
+	if locale != "" {
+		out = append(out, locale)
+		if lang, _, ok := strings.Cut(locale, "-"); ok {
+			out = append(out, lang)
+		}
+	}
+	if locale != DefaultLocale {
+		out = append(out, DefaultLocale)
+	}
+	return out
+}
+
+// Render renders the email name for a recipient who reads locale ("" for DefaultLocale).
+func (r *Renderer) Render(name, locale string, data any) (*Message, error) {
+	if _, ok := samples[name]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	locale, _ = NormalizeLocale(locale)
+	msg := &Message{}
+
+	// The text template defines the subject and the plain-text body.
+	layout, _, err := r.lookup("layout", locale, ".txt")
+	if err != nil {
+		return nil, err
+	}
+	src, used, err := r.lookup(name, locale, ".txt")
+	if err != nil {
+		return nil, err
+	}
+	msg.Locale = used
+	tt, err := texttemplate.New(name).Parse(string(layout))
+	if err == nil {
+		_, err = tt.Parse(string(src))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s.%s.txt: %w", name, used, err)
+	}
+	var buf bytes.Buffer
+	if err := tt.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return nil, fmt.Errorf("rendering %s subject: %w", name, err)
+	}
+	// Subjects are single header lines.
+	msg.Subject = strings.Join(strings.Fields(buf.String()), " ")
+	buf.Reset()
+	if err := tt.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering %s text: %w", name, err)
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	// The HTML template defines the heading and the body; html/template escapes the data.
+	layout, _, err = r.lookup("layout", locale, ".html")
+	if err != nil {
+		return nil, err
+	}
+	src, used, err = r.lookup(name, locale, ".html")
+	if err != nil {
+		return nil, err
+	}
+	ht, err := htmltemplate.New(name).Parse(string(layout))
+	if err == nil {
+		_, err = ht.Parse(string(src))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s.%s.html: %w", name, used, err)
+	}
+	buf.Reset()
+	if err := ht.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering %s html: %w", name, err)
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+// Preview renders name with sample data.
+func (r *Renderer) Preview(name, locale string) (*Message, error) {
+	data, ok := samples[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	return r.Render(name, locale, data)
+}
+
+// TemplateInfo lists the locales an email has a variant in.
+type TemplateInfo struct {
+	Name    string   `json:"name"`
+	Locales []string `json:"locales"`
+}
+
+var variantPattern = regexp.MustCompile(`^([a-z_]+)\.([a-z0-9-]+)\.txt$`)
+
+// Templates lists every email with the locales it is available in, overrides included.
+func (r *Renderer) Templates() ([]TemplateInfo, error) {
+	locales := map[string]map[string]bool{}
+	for name := range samples {
+		locales[name] = map[string]bool{}
+	}
+	collect := func(fsys fs.FS, dir string) error {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			m := variantPattern.FindStringSubmatch(e.Name())
+			if m == nil || locales[m[1]] == nil {
+				continue
+			}
+			locales[m[1]][m[2]] = true
+		}
+		return nil
+	}
+	if err := collect(embedded, "templates"); err != nil {
+		return nil, err
+	}
+	if r.dir != nil {
+		if err := collect(r.dir, "."); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]TemplateInfo, 0, len(locales))
+	for name, ls := range locales {
+		info := TemplateInfo{Name: name, Locales: []string{}}
+		for l := range ls {
+			info.Locales = append(info.Locales, l)
+		}
+		sort.Strings(info.Locales)
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Check renders every variant of every email with sample data, so broken overrides show up
+// at startup rather than when an email is due.
+func (r *Renderer) Check() error {
+	infos, err := r.Templates()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, info := range infos {
+		for _, l := range info.Locales {
+			if _, err := r.Preview(info.Name, l); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", info.Name, l, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// NormalizeLocale lowercases a language tag such as "pt_BR" to "pt-br" and reports whether
+// it is well formed. Unusable tags normalize to "".
+func NormalizeLocale(s string) (string, bool) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+	if !localePattern.MatchString(s) {
+		return "", false
+	}
+	return s, true
+}
+
+// PreferredLocale picks the most preferred language of an Accept-Language header, or "".
+func PreferredLocale(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		l, ok := NormalizeLocale(tag)
+		if ok && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+	return best
+}
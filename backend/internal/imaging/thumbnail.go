@@ -0,0 +1,115 @@
+// Package imaging turns uploaded pictures into small, square thumbnails using only the
+// standard library decoders.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // registers GIF for image.Decode
+	"image/jpeg"
+	_ "image/png" // registers PNG for image.Decode
+	"io"
+)
+
+// MaxPixels bounds the decoded size of an upload, so a tiny file claiming huge dimensions
+// cannot exhaust memory.
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// Decode reads a JPEG, PNG or GIF after checking its dimensions.
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// Square crops the centre square of img and scales it to size×size. Every output pixel is
+// the area-weighted average of the source pixels it covers, which keeps downscaled
+// pictures smooth; smaller sources are scaled up the same way.
+func Square(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	scale := float64(side) / float64(size)
+	for dy := 0; dy < size; dy++ {
+		sy0, sy1 := float64(dy)*scale, float64(dy+1)*scale
+		for dx := 0; dx < size; dx++ {
+			sx0, sx1 := float64(dx)*scale, float64(dx+1)*scale
+			var r, g, bl, a, total float64
+			for sy := int(sy0); float64(sy) < sy1 && sy < side; sy++ {
+				wy := overlap(float64(sy), sy0, sy1)
+				for sx := int(sx0); float64(sx) < sx1 && sx < side; sx++ {
+					w := wy * overlap(float64(sx), sx0, sx1)
+					pr, pg, pb, pa := img.At(x0+sx, y0+sy).RGBA()
+					r += float64(pr) * w
+					g += float64(pg) * w
+					bl += float64(pb) * w
+					a += float64(pa) * w
+					total += w
+				}
+			}
+			if total == 0 {
+				continue
+			}
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i+0] = uint8(r / total / 257)
+			dst.Pix[i+1] = uint8(g / total / 257)
+			dst.Pix[i+2] = uint8(bl / total / 257)
+			dst.Pix[i+3] = uint8(a / total / 257)
+		}
+	}
+	return dst
+}
+
+// overlap is how much of the unit pixel starting at p lies within [lo, hi).
+func overlap(p, lo, hi float64) float64 {
+	start, end := p, p+1
+	if lo > start {
+		start = lo
+	}
+	if hi < end {
+		end = hi
+	}
+	if end <= start {
+		return 0
+	}
+	return end - start
+}
+
+// EncodeJPEG writes img as a JPEG. JPEG has no alpha, so transparent areas are flattened
+// onto white.
+func EncodeJPEG(w io.Writer, img *image.RGBA, quality int) error {
+	flat := image.NewRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		// Pixels are premultiplied, so adding the missing coverage as white composites them.
+		white := 255 - img.Pix[i+3]
+		flat.Pix[i+0] = img.Pix[i+0] + white
+		flat.Pix[i+1] = img.Pix[i+1] + white
+		flat.Pix[i+2] = img.Pix[i+2] + white
+		flat.Pix[i+3] = 255
+	}
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+}
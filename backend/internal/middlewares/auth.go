@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -11,36 +12,71 @@ import (
 
 type JWTMiddleware struct {
 	Secret string
+	// Revocations, when set, rejects tokens whose jti was revoked by logout.
+	Revocations RevocationChecker
+}
+
+type RevocationChecker interface {
+	IsRevoked(jti string) (bool, error)
 }
 
 func NewJWT(secret string) *JWTMiddleware { return &JWTMiddleware{Secret: secret} }
 
 func (m *JWTMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		h := c.GetHeader("Authorization")
-		if !strings.HasPrefix(strings.ToLower(h), "bearer ") {
+		tokenString, ok := bearerToken(c)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
 			return
 		}
-		tokenString := strings.TrimSpace(h[len("Bearer "):])
-		t, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			return []byte(m.Secret), nil
-		})
-		if err != nil || !t.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		userID, _, err := m.Verify(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
-		claims, ok := t.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
-			return
+		c.Set("user_id", userID)
+		c.Next()
+	}
+}
+
+// Verify checks a legacy HS256 token and returns the user id from its sub claim.
+func (m *JWTMiddleware) Verify(tokenString string) (uint, jwt.MapClaims, error) {
+	t, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return []byte(m.Secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil || !t.Valid {
+		return 0, nil, errors.New("invalid token")
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, nil, errors.New("invalid claims")
+	}
+	// optional exp check if lib didn't validate
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return 0, nil, errors.New("token expired")
+	}
+	sub, ok := claims["sub"].(float64)
+	if !ok || sub < 1 {
+		return 0, nil, errors.New("invalid claims")
+	}
+	// Tokens issued before sessions existed carry no jti and simply run to expiry.
+	if jti, _ := claims["jti"].(string); jti != "" && m.Revocations != nil {
+		revoked, err := m.Revocations.IsRevoked(jti)
+		if err != nil {
+			return 0, nil, errors.New("could not check token revocation")
 		}
-		// optional exp check if lib didn't validate
-		if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
-			return
+		if revoked {
+			return 0, nil, errors.New("token revoked")
 		}
-		c.Set("user_id", uint(claims["sub"].(float64)))
-		c.Next()
 	}
+	return uint(sub), claims, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer ..." header.
+func bearerToken(c *gin.Context) (string, bool) {
+	h := c.GetHeader("Authorization")
+	if !strings.HasPrefix(strings.ToLower(h), "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(h[len("Bearer "):]), true
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -48,80 +49,89 @@ func NewAuth0(domain, audience string) *Auth0Middleware {
 // RequireAuth0 validates Auth0 JWT tokens
 func (m *Auth0Middleware) RequireAuth0() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		h := c.GetHeader("Authorization")
-		if !strings.HasPrefix(strings.ToLower(h), "bearer ") {
+		tokenString, ok := bearerToken(c)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
 			return
 		}
 
-		tokenString := strings.TrimSpace(h[len("Bearer "):])
-
-		// Parse token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// Get key ID from token header
-			kid, ok := token.Header["kid"].(string)
-			if !ok {
-				return nil, errors.New("kid header not found")
-			}
+		claims, err := m.Verify(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
 
-			// Get public key from JWKS
-			publicKey, err := m.getPublicKey(kid)
-			if err != nil {
-				return nil, err
-			}
+		// Set Auth0 ID in context for downstream handlers
+		c.Set("auth0_id", claims["sub"].(string))
+		c.Set("user_claims", claims)
 
-			return publicKey, nil
-		})
+		c.Next()
+	}
+}
 
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			return
+// Verify checks an Auth0 RS256 token's signature, audience and issuer. The returned
+// claims always carry a non-empty string sub.
+func (m *Auth0Middleware) Verify(tokenString string) (jwt.MapClaims, error) {
+	// Parse token
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Verify signing method
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
+		// Get key ID from token header
+		kid, ok := token.Header["kid"].(string)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
-			return
+			return nil, errors.New("kid header not found")
 		}
 
-		// Verify audience if configured
-		if m.Audience != "" {
-			aud, _ := claims["aud"].(string)
-			if aud != m.Audience {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid audience"})
-				return
-			}
-		}
+		// Get public key from JWKS
+		return m.getPublicKey(kid)
+	})
 
-		// Verify issuer
-		expectedIssuer := m.Domain
-		if !strings.HasSuffix(expectedIssuer, "/") {
-			expectedIssuer += "/"
-		}
-		iss, _ := claims["iss"].(string)
-		if iss != expectedIssuer {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid issuer"})
-			return
-		}
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
 
-		// Extract Auth0 user ID (sub claim)
-		auth0ID, _ := claims["sub"].(string)
-		if auth0ID == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing sub claim"})
-			return
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid claims")
+	}
+
+	// Verify audience if configured. Auth0 sends an array when the token also targets /userinfo.
+	if m.Audience != "" {
+		aud, _ := claims.GetAudience()
+		if !slices.Contains(aud, m.Audience) {
+			return nil, errors.New("invalid audience")
 		}
+	}
 
-		// Set Auth0 ID in context for downstream handlers
-		c.Set("auth0_id", auth0ID)
-		c.Set("user_claims", claims)
+	// Verify issuer
+	expectedIssuer := m.Domain
+	if !strings.HasSuffix(expectedIssuer, "/") {
+		expectedIssuer += "/"
+	}
+	iss, _ := claims["iss"].(string)
+	if iss != expectedIssuer {
+		return nil, errors.New("invalid issuer")
+	}
 
-		c.Next()
+	// Extract Auth0 user ID (sub claim)
+	auth0ID, _ := claims["sub"].(string)
+	if auth0ID == "" {
+		return nil, errors.New("missing sub claim")
+	}
+	return claims, nil
+}
+
+// verifiedEmail returns the token's email if Auth0 vouches for it (email_verified), else "".
+// Access tokens only carry these claims when an Auth0 Action adds them.
+func verifiedEmail(claims jwt.MapClaims) string {
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return ""
 	}
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // getPublicKey fetches the public key from Auth0 JWKS endpoint
@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// IdempotencyRecord is what a store keeps per key: the request fingerprint and, once the
+// first request finished, its response.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Status      int // 0 while the first request is still running
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore persists idempotency records. Keys arrive already scoped to the caller.
+type IdempotencyStore interface {
+	// Reserve claims key for a new request. If the key is taken it returns the existing
+	// record and false.
+	Reserve(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	Complete(key string, status int, contentType string, body []byte) error
+	// Release forgets a reservation so the request can be retried.
+	Release(key string) error
+}
+
+// Idempotency makes retried POST/PUT/PATCH/DELETE requests safe: a request carrying an
+// Idempotency-Key runs once, and repeats get the stored response. Only JSON (or empty)
+// bodies up to 1 MiB take part; uploads have their own offset-based resume.
+type Idempotency struct {
+	Store IdempotencyStore
+	TTL   time.Duration
+	// Except lists route paths (as registered) whose responses must not be stored,
+	// e.g. because they contain secrets.
+	Except map[string]bool
+}
+
+func NewIdempotency(store IdempotencyStore, ttl time.Duration, except ...string) *Idempotency {
+	m := &Idempotency{Store: store, TTL: ttl, Except: map[string]bool{}}
+	for _, p := range except {
+		m.Except[p] = true
+	}
+	return m
+}
+
+func idempotencyFingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, method+" "+uri+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scopedIdempotencyKey separates callers, so two users picking the same key never collide.
+// Unauthenticated routes share one scope; the fingerprint still guards against mixups.
+func scopedIdempotencyKey(c *gin.Context, key string) string {
+	scope := "anon"
+	if uid := c.GetUint("user_id"); uid != 0 {
+		scope = "user:" + strconv.FormatUint(uint64(uid), 10)
+	}
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Idempotency) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" || m.Except[c.FullPath()] {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		if ct := c.ContentType(); ct != "" && ct != "application/json" {
+			c.Next()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "could not read request body"})
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		storeKey := scopedIdempotencyKey(c, key)
+		fingerprint := idempotencyFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+		rec, created, err := m.Store.Reserve(storeKey, fingerprint, m.TTL)
+		if err != nil {
+			log.Printf("[idempotency] reserve failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not check Idempotency-Key"})
+			return
+		}
+		if !created {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case rec.Status == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(rec.Status, rec.ContentType, rec.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		w := &responseBodyWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = w
+		completed := false
+		defer func() {
+			// Server errors and panics are not final; let the client retry with the same key.
+			if !completed {
+				if err := m.Store.Release(storeKey); err != nil {
+					log.Printf("[idempotency] release failed: %v", err)
+				}
+			}
+		}()
+		c.Next()
+		if status := w.Status(); status < http.StatusInternalServerError {
+			if err := m.Store.Complete(storeKey, status, w.Header().Get("Content-Type"), w.body.Bytes()); err != nil {
+				log.Printf("[idempotency] storing response failed: %v", err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+type memoryIdempotencyEntry struct {
+	rec     IdempotencyRecord
+	expires time.Time
+}
+
+// MemoryIdempotencyStore keeps records in process; for single-instance deployments and tests.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryIdempotencyEntry
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]*memoryIdempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		rec := e.rec
+		return &rec, false, nil
+	}
+	s.entries[key] = &memoryIdempotencyEntry{rec: IdempotencyRecord{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.rec.Status = status
+		e.rec.ContentType = contentType
+		e.rec.Body = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Cleanup drops expired records on an interval. It never returns.
+func (s *MemoryIdempotencyStore) Cleanup(every time.Duration) {
+	for {
+		time.Sleep(every)
+		now := time.Now()
+		s.mu.Lock()
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
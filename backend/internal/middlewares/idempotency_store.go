@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+// DBIdempotencyStore keeps records in the idempotency_keys table, shared by every instance.
+type DBIdempotencyStore struct{ DB *gorm.DB }
+
+func NewDBIdempotencyStore(db *gorm.DB) *DBIdempotencyStore { return &DBIdempotencyStore{DB: db} }
+
+func (s *DBIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	now := time.Now()
+	row := models.IdempotencyKey{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	// A second attempt covers a row that expired but was not cleaned up yet.
+	for attempt := 0; attempt < 2; attempt++ {
+		res := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if res.Error != nil {
+			return nil, false, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil, true, nil
+		}
+		var existing models.IdempotencyKey
+		err := s.DB.Where("key = ?", key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // released in between
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if existing.ExpiresAt.After(now) {
+			return &IdempotencyRecord{Fingerprint: existing.Fingerprint, Status: existing.Status, ContentType: existing.ContentType, Body: existing.Body}, false, nil
+		}
+		if err := s.DB.Where("key = ? AND expires_at <= ?", key, now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, errors.New("could not reserve idempotency key")
+}
+
+func (s *DBIdempotencyStore) Complete(key string, status int, contentType string, body []byte) error {
+	return s.DB.Model(&models.IdempotencyKey{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"status": status, "content_type": contentType, "body": body}).Error
+}
+
+func (s *DBIdempotencyStore) Release(key string) error {
+	return s.DB.Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// Cleanup deletes expired records on an interval. It never returns.
+func (s *DBIdempotencyStore) Cleanup(every time.Duration) {
+	for {
+		if err := s.DB.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			log.Printf("[idempotency] cleanup failed: %v", err)
+		}
+		time.Sleep(every)
+	}
+}
@@ -0,0 +1,207 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/services"
+)
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAuth0  = "auth0"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Principal is the authenticated caller, resolved to a row in users whichever login was used.
+type Principal struct {
+	UserID  uint
+	Auth0ID string // empty for legacy logins
+	Method  string // AuthMethodJWT, AuthMethodAuth0 or AuthMethodAPIKey
+	// VerifiedEmail is the Auth0 token's email claim when it is marked verified; otherwise
+	// empty. Unlike the stored email it cannot be chosen by the caller.
+	VerifiedEmail string
+
+	// Legacy tokens only: the session family and token id, used by logout.
+	SessionID      string
+	TokenID        string
+	TokenExpiresAt time.Time
+
+	// API keys only: the key and what it may do. User tokens are not scoped.
+	APIKeyID uint
+	Scopes   []string
+}
+
+// Allows reports whether the caller may use scope.
+func (p Principal) Allows(scope string) bool {
+	return p.Method != AuthMethodAPIKey || slices.Contains(p.Scopes, scope)
+}
+
+// CurrentPrincipal returns the caller set by Authenticator.RequireUser.
+func CurrentPrincipal(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get("principal")
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// Authenticator accepts either a legacy HS256 token or an Auth0 RS256 token on the same
+// routes. Handlers keep reading c.GetUint("user_id"); auth0_id is also set for Auth0 callers.
+type Authenticator struct {
+	JWT     *JWTMiddleware
+	Auth0   *Auth0Middleware // nil when Auth0 is not configured
+	Users   *services.UserService
+	APIKeys *services.APIKeyService
+}
+
+func NewAuthenticator(db *gorm.DB, jwtMw *JWTMiddleware, auth0 *Auth0Middleware) *Authenticator {
+	return &Authenticator{
+		JWT:     jwtMw,
+		Auth0:   auth0,
+		Users:   services.NewUserService(db, jwtMw.Secret),
+		APIKeys: services.NewAPIKeyService(db),
+	}
+}
+
+// RequireUser accepts user tokens only (legacy JWT or Auth0).
+func (a *Authenticator) RequireUser() gin.HandlerFunc {
+	return a.require(false)
+}
+
+// RequireUserOrAPIKey also accepts API keys, as a bearer token or an X-API-Key header.
+// Pair it with RequireScope on each route.
+func (a *Authenticator) RequireUserOrAPIKey() gin.HandlerFunc {
+	return a.require(true)
+}
+
+// OptionalUser identifies the caller on public routes when a valid user token is sent;
+// without one, or with an invalid one, the request goes through anonymously.
+func (a *Authenticator) OptionalUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if ok && !strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+			if p, _, err := a.authenticate(tokenString); err == nil {
+				c.Set("principal", p)
+				c.Set("user_id", p.UserID)
+			}
+		}
+		c.Next()
+	}
+}
+
+func (a *Authenticator) require(allowAPIKeys bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok && allowAPIKeys {
+			tokenString = strings.TrimSpace(c.GetHeader("X-API-Key"))
+			ok = tokenString != ""
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+		var p Principal
+		var status int
+		var err error
+		if strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+			if !allowAPIKeys {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api keys are only accepted on ingest routes"})
+				return
+			}
+			p, status, err = a.authenticateAPIKey(tokenString)
+		} else {
+			p, status, err = a.authenticate(tokenString)
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("principal", p)
+		c.Set("user_id", p.UserID)
+		if p.Auth0ID != "" {
+			c.Set("auth0_id", p.Auth0ID)
+		}
+		c.Next()
+	}
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (Principal, int, error) {
+	if a.APIKeys.DB == nil {
+		return Principal{}, http.StatusServiceUnavailable, errors.New("database unavailable")
+	}
+	k, err := a.APIKeys.Authenticate(key)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return Principal{}, http.StatusUnauthorized, err
+	}
+	if err != nil {
+		return Principal{}, http.StatusInternalServerError, err
+	}
+	return Principal{UserID: k.UserID, Method: AuthMethodAPIKey, APIKeyID: k.ID, Scopes: k.Scopes}, 0, nil
+}
+
+// RequireScope rejects API keys that were not granted scope. User tokens always pass.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if !p.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate routes the token by its signing algorithm: our own tokens are HS256,
+// Auth0 access tokens are RS256.
+func (a *Authenticator) authenticate(tokenString string) (Principal, int, error) {
+	t, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return Principal{}, http.StatusUnauthorized, errors.New("invalid token")
+	}
+	if strings.HasPrefix(t.Method.Alg(), "RS") {
+		if a.Auth0 == nil || a.Auth0.Domain == "" {
+			return Principal{}, http.StatusUnauthorized, errors.New("auth0 login is not enabled")
+		}
+		claims, err := a.Auth0.Verify(tokenString)
+		if err != nil {
+			return Principal{}, http.StatusUnauthorized, err
+		}
+		auth0ID := claims["sub"].(string)
+		if a.Users.DB == nil {
+			return Principal{}, http.StatusServiceUnavailable, errors.New("database unavailable")
+		}
+		u, err := a.Users.FindByAuth0ID(auth0ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The frontend calls /api/v1/auth/sync after login to create the row.
+			return Principal{}, http.StatusForbidden, errors.New("account not synced; call /api/v1/auth/sync first")
+		}
+		if err != nil {
+			return Principal{}, http.StatusInternalServerError, err
+		}
+		return Principal{UserID: u.ID, Auth0ID: auth0ID, Method: AuthMethodAuth0, VerifiedEmail: verifiedEmail(claims)}, 0, nil
+	}
+	userID, claims, err := a.JWT.Verify(tokenString)
+	if err != nil {
+		return Principal{}, http.StatusUnauthorized, err
+	}
+	p := Principal{UserID: userID, Method: AuthMethodJWT}
+	p.SessionID, _ = claims["sid"].(string)
+	p.TokenID, _ = claims["jti"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		p.TokenExpiresAt = exp.Time
+	}
+	return p, 0, nil
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+// RoleMiddleware authorizes requests by the caller's stored role.
+type RoleMiddleware struct {
+	DB *gorm.DB
+	// AdminEmails are promoted to admin on first use, so a fresh deployment has someone to
+	// grant roles to everyone else. Only a verified email in an Auth0 token counts: stored
+	// emails come from registration and sync requests and prove nothing.
+	AdminEmails map[string]bool
+}
+
+func NewRoles(db *gorm.DB, adminEmails []string) *RoleMiddleware {
+	allowed := make(map[string]bool, len(adminEmails))
+	for _, e := range adminEmails {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			allowed[e] = true
+		}
+	}
+	return &RoleMiddleware{DB: db, AdminEmails: allowed}
+}
+
+// RequireRole allows callers whose role is at least min. It runs after either RequireAuth
+// (user_id) or RequireAuth0 (auth0_id) and sets user_id and user_role for handlers.
+// The role is read on every request so demotions apply immediately.
+func (m *RoleMiddleware) RequireRole(min models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.DB == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "database unavailable"})
+			return
+		}
+		var u models.User
+		q := m.DB.Select("id", "role")
+		var err error
+		if id := c.GetUint("user_id"); id != 0 {
+			err = q.First(&u, id).Error
+		} else if auth0ID := c.GetString("auth0_id"); auth0ID != "" {
+			err = q.Where("auth0_id = ?", auth0ID).First(&u).Error
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user not found"})
+			return
+		}
+		if email := callerVerifiedEmail(c); u.Role != models.RoleAdmin && email != "" && m.AdminEmails[email] {
+			if err := m.DB.Model(&u).Update("role", models.RoleAdmin).Error; err == nil {
+				u.Role = models.RoleAdmin
+			}
+		}
+		if !u.Role.AtLeast(min) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": string(min) + " access required"})
+			return
+		}
+		c.Set("user_id", u.ID)
+		c.Set("user_role", string(u.Role))
+		c.Next()
+	}
+}
+
+// callerVerifiedEmail is the verified email of an Auth0 caller, from Authenticator or
+// RequireAuth0; legacy logins and API keys have none.
+func callerVerifiedEmail(c *gin.Context) string {
+	if p, ok := CurrentPrincipal(c); ok {
+		return p.VerifiedEmail
+	}
+	if claims, ok := c.Get("user_claims"); ok {
+		if mc, ok := claims.(jwt.MapClaims); ok {
+			return verifiedEmail(mc)
+		}
+	}
+	return ""
+}
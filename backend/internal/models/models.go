@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 type RSVP struct {
@@ -17,6 +18,7 @@ type RSVP struct {
 	EmailSent    bool   `gorm:"default:false" json:"emailSent"`
 	IPAddress    string `gorm:"size:45" json:"ipAddress"`                // IPv6 max length is 45 chars
 	ReferralCode string `gorm:"uniqueIndex;size:20" json:"referralCode"` // Unique code for sharing
+	Locale       string `gorm:"size:16" json:"locale"`                   // From Accept-Language; emails use it
 
 	// Referral tracking - one-to-many relationship
 	ReferredByCode string `gorm:"size:20;index" json:"referredByCode,omitempty"` // Code used to sign up
@@ -28,13 +30,30 @@ type RSVP struct {
 	User   *User `gorm:"constraint:OnDelete:SET NULL" json:"user,omitempty"`
 }
 
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool { return roleRank[r] > 0 }
+
+// AtLeast reports whether r grants everything min does; roles are ordered user < moderator < admin.
+func (r Role) AtLeast(min Role) bool { return roleRank[r] >= roleRank[min] && r.Valid() }
+
 type User struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
 	// Auth0 fields for social login
-	Auth0ID string `gorm:"uniqueIndex;size:255" json:"auth0Id,omitempty"`
+	// Unique only when set: legacy accounts leave it empty.
+	Auth0ID string `gorm:"uniqueIndex:idx_users_auth0_id,where:auth0_id <> '';size:255" json:"auth0Id,omitempty"`
 	Email   string `gorm:"uniqueIndex;size:255" json:"email"`
 
 	// Username and password for legacy auth (optional with Auth0)
@@ -42,10 +61,38 @@ type User struct {
 	PasswordHash string `json:"-"`
 
 	// Profile fields
-	DisplayName string `gorm:"size:100" json:"displayName"`
-	Picture     string `gorm:"size:500" json:"picture,omitempty"`
-	Bio         string `gorm:"size:280" json:"bio"`
-	Public      bool   `json:"public"`
+	DisplayName string      `gorm:"size:100" json:"displayName"`
+	Picture     string      `gorm:"size:500" json:"picture,omitempty"`
+	Bio         string      `gorm:"size:280" json:"bio"`
+	Public      bool        `json:"public"`
+	Links       SocialLinks `gorm:"embedded;embeddedPrefix:link_" json:"links"`
+	// AvatarKey is the blob of an uploaded avatar. While set, Picture points at it and Auth0
+	// logins leave the picture alone.
+	AvatarKey string `gorm:"size:200" json:"-"`
+	// ProfileViews counts deduplicated views of the public profile, updated in the background.
+	ProfileViews int64 `gorm:"not null;default:0" json:"profileViews"`
+	// Locale is the language emails are sent in, e.g. "es" or "pt-br"; empty for the default.
+	Locale string `gorm:"size:16" json:"locale"`
+
+	Role Role `gorm:"size:20;default:user;index" json:"role"`
+}
+
+// SocialLinks are canonical https URLs of a producer's profiles elsewhere; empty when unset.
+type SocialLinks struct {
+	SoundCloud string `gorm:"size:200" json:"soundcloud"`
+	YouTube    string `gorm:"size:200" json:"youtube"`
+	Instagram  string `gorm:"size:200" json:"instagram"`
+}
+
+// HandleRedirect remembers a handle its owner gave up, so old profile links keep working
+// until someone else claims it.
+type HandleRedirect struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Handle string `gorm:"size:50;uniqueIndex" json:"handle"`
+	UserID uint   `gorm:"index" json:"userId"`
+	User   User   `gorm:"constraint:OnDelete:CASCADE" json:"-"`
 }
 
 type ProjectStatus string
@@ -60,19 +107,34 @@ type Project struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
-	UserID uint `json:"userId"`
+	UserID uint `gorm:"uniqueIndex:idx_projects_user_uuid,where:uuid <> ''" json:"userId"`
 	User   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
 
+	// UUID is the ingest key: plugins send the id stored in the DAW session file, so the
+	// title can change freely. Projects created without one get a server-generated UUID.
+	UUID string `gorm:"size:36;uniqueIndex:idx_projects_user_uuid" json:"uuid"`
+	// UUIDFromClient is false until a plugin sent this UUID. Until then a client UUID may
+	// adopt the project by title, which upgrades projects recorded before UUIDs existed.
+	UUIDFromClient bool `json:"-"`
+
 	Title           string         `gorm:"size:200" json:"title"`
 	DAW             string         `gorm:"size:100" json:"daw"`
 	PluginVersion   string         `gorm:"size:50" json:"pluginVersion"`
-	DurationSeconds int            `json:"durationSeconds"`
+	DurationSeconds int            `json:"durationSeconds"` // total of the project's sessions, computed server-side
 	Metadata        datatypes.JSON `json:"metadata"`
 	Status          ProjectStatus  `gorm:"size:20;default:in_progress" json:"status"`
 	CompletedAt     *time.Time     `json:"completedAt"`
 	Public          bool           `json:"public"`
+	// DeletedAt marks a project the owner moved to the trash; it is hidden from listings,
+	// profiles and stats until restored.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt"`
+
+	// Engagement counters, kept up to date in the background from likes and deduplicated plays.
+	LikeCount int64 `gorm:"not null;default:0" json:"likeCount"`
+	PlayCount int64 `gorm:"not null;default:0" json:"playCount"`
 
-	Plugins []Plugin `json:"plugins,omitempty"`
+	Plugins    []Plugin    `json:"plugins,omitempty"`
+	AudioFiles []AudioFile `json:"audioFiles,omitempty"`
 }
 
 // Plugin represents a plugin instance attached to a project.
@@ -91,4 +153,478 @@ type Plugin struct {
 	Version  string         `gorm:"size:50" json:"version"`
 	Format   string         `gorm:"size:20" json:"format"` // e.g., VST3, AU, AAX
 	Metadata datatypes.JSON `json:"metadata"`
+	// RemovedAt is set when a full plugin sync no longer lists the plugin; the row is kept
+	// for history and revived if the plugin comes back.
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+
+	// CatalogID links the free-text name to its canonical catalog entry.
+	CatalogID *uint          `gorm:"index" json:"catalogId,omitempty"`
+	Catalog   *PluginCatalog `gorm:"constraint:OnDelete:SET NULL" json:"catalog,omitempty"`
+}
+
+type AnalysisStatus string
+
+const (
+	AnalysisPending     AnalysisStatus = "pending"
+	AnalysisRunning     AnalysisStatus = "running"
+	AnalysisDone        AnalysisStatus = "done"
+	AnalysisFailed      AnalysisStatus = "failed"
+	AnalysisUnsupported AnalysisStatus = "unsupported"
+)
+
+// AudioFile is an uploaded bounce, stem or beat attached to a project.
+// The bytes live in the configured BlobStore under StorageKey.
+type AudioFile struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	ProjectID uint    `gorm:"index" json:"projectId"`
+	Project   Project `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint    `gorm:"index" json:"userId"`
+
+	StorageKey  string `gorm:"size:500;uniqueIndex" json:"-"`
+	Filename    string `gorm:"size:255" json:"filename"`
+	ContentType string `gorm:"size:100" json:"contentType"`
+	SizeBytes   int64  `json:"sizeBytes"`
+	Checksum    string `gorm:"size:64" json:"checksum"` // hex sha256 of the stored bytes
+
+	// Server-side analysis, filled in by a background job after the upload completes.
+	AnalysisStatus  AnalysisStatus `gorm:"size:20;default:pending;index" json:"analysisStatus"`
+	AnalysisError   string         `gorm:"size:255" json:"analysisError,omitempty"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	SampleRate      int            `json:"sampleRate"`
+	Channels        int            `json:"channels"`
+	LoudnessLUFS    *float64       `json:"loudnessLufs"`
+	Peaks           datatypes.JSON `json:"peaks,omitempty"` // waveform overview, max amplitude 0..1 per slice
+	AnalyzedAt      *time.Time     `json:"analyzedAt,omitempty"`
+
+	// Short-lived playback link, filled in per response and never persisted.
+	URL          string     `gorm:"-" json:"url,omitempty"`
+	URLExpiresAt *time.Time `gorm:"-" json:"urlExpiresAt,omitempty"`
+}
+
+type UploadStatus string
+
+const (
+	UploadPending    UploadStatus = "pending"
+	UploadAssembling UploadStatus = "assembling"
+	UploadComplete   UploadStatus = "complete"
+)
+
+// UploadSession tracks a resumable, chunked upload. Each accepted chunk is stored as its own
+// object under "uploads/<id>/", so progress survives server restarts. Finalizing stitches the
+// chunks into a regular AudioFile.
+type UploadSession struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID    uint `gorm:"index" json:"userId"`
+	User      User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ProjectID uint `gorm:"index" json:"projectId"`
+
+	Filename      string `gorm:"size:255" json:"filename"`
+	TotalBytes    int64  `json:"totalBytes"`
+	ReceivedBytes int64  `json:"receivedBytes"`
+	ChunkCount    int    `json:"chunkCount"`
+	// ChunkKeys are the blobs holding each accepted chunk, in order.
+	ChunkKeys   datatypes.JSONSlice[string] `json:"-"`
+	Status      UploadStatus                `gorm:"size:20;default:pending;index" json:"status"`
+	ExpiresAt   time.Time                   `gorm:"index" json:"expiresAt"`
+	AudioFileID *uint                       `json:"audioFileId,omitempty"`
+}
+
+type VotingMode string
+
+const (
+	VotingSingle VotingMode = "single" // one vote per user
+	VotingRanked VotingMode = "ranked" // ordered ballot of up to BallotSize entries
+)
+
+// Challenge is a community challenge with a submission window. Entries lock when
+// the window closes; admins then announce placements.
+type Challenge struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	CreatedByID uint `gorm:"index" json:"createdById"`
+
+	Slug        string    `gorm:"uniqueIndex;size:80" json:"slug"`
+	Title       string    `gorm:"size:200" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	Rules       string    `gorm:"type:text" json:"rules"`
+	StartsAt    time.Time `gorm:"index" json:"startsAt"`
+	EndsAt      time.Time `gorm:"index" json:"endsAt"`
+
+	// Optional constraints checked when a project is entered
+	RequiredPlugin string `gorm:"size:120" json:"requiredPlugin,omitempty"`
+	RequiredDAW    string `gorm:"size:100" json:"requiredDaw,omitempty"`
+
+	// Community voting. The window opens at VotingStartsAt (default EndsAt); no voting unless VotingEndsAt is set.
+	VotingStartsAt *time.Time `json:"votingStartsAt,omitempty"`
+	VotingEndsAt   *time.Time `json:"votingEndsAt,omitempty"`
+	VotingMode     VotingMode `gorm:"size:20;default:single" json:"votingMode"`
+	BallotSize     int        `gorm:"default:1" json:"ballotSize"` // entries ranked per ballot in ranked mode
+
+	LockedAt    *time.Time `json:"lockedAt,omitempty"`    // entries frozen after EndsAt
+	AnnouncedAt *time.Time `json:"announcedAt,omitempty"` // winners published
+
+	Phase   string           `gorm:"-" json:"phase"` // upcoming, open, voting, closed or judged; computed per response
+	Entries []ChallengeEntry `json:"entries,omitempty"`
+}
+
+// ChallengeEntry submits one of the user's projects to a challenge; one entry per user.
+type ChallengeEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	ChallengeID uint      `gorm:"uniqueIndex:idx_challenge_user" json:"challengeId"`
+	Challenge   Challenge `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID      uint      `gorm:"uniqueIndex:idx_challenge_user" json:"userId"`
+	User        User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ProjectID   uint      `gorm:"index" json:"projectId"`
+	Project     Project   `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	// The bounce being judged; pinned to the project's latest upload at lock time if not chosen.
+	AudioFileID *uint      `json:"audioFileId,omitempty"`
+	AudioFile   *AudioFile `gorm:"constraint:OnDelete:SET NULL" json:"-"`
+
+	Locked    bool `gorm:"default:false" json:"locked"`
+	Placement *int `json:"placement,omitempty"` // 1 = winner
+}
+
+// ChallengeVote is one line of a user's ballot. Single-vote challenges have one row
+// per voter; ranked ballots have one row per ranked entry. Points are fixed at cast
+// time so tallies are a plain SUM.
+type ChallengeVote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ChallengeID uint           `gorm:"uniqueIndex:idx_vote_rank;uniqueIndex:idx_vote_entry;index:idx_vote_tally" json:"challengeId"`
+	Challenge   Challenge      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID      uint           `gorm:"uniqueIndex:idx_vote_rank;uniqueIndex:idx_vote_entry" json:"userId"`
+	User        User           `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	EntryID     uint           `gorm:"uniqueIndex:idx_vote_entry;index:idx_vote_tally" json:"entryId"`
+	Entry       ChallengeEntry `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	Rank   int `gorm:"uniqueIndex:idx_vote_rank" json:"rank"`
+	Points int `json:"points"`
+}
+
+// Session is one issued refresh token. Rotation creates a new row in the same family and
+// marks the old one replaced; presenting a replaced token revokes the whole family.
+type Session struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID   uint   `gorm:"index" json:"userId"`
+	User     User   `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	FamilyID string `gorm:"size:36;index" json:"familyId"`
+
+	TokenHash    string `gorm:"size:64;uniqueIndex" json:"-"` // sha256 of the refresh token
+	ReplacedByID *uint  `json:"-"`
+
+	// The access token issued alongside, so it can be revoked with the session
+	AccessJTI       string    `gorm:"size:36;index" json:"-"`
+	AccessExpiresAt time.Time `json:"-"`
+
+	ExpiresAt  time.Time  `gorm:"index" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	UserAgent  string     `gorm:"size:255" json:"userAgent"`
+	IPAddress  string     `gorm:"size:45" json:"ipAddress"`
+}
+
+// RevokedToken lists access token ids rejected before their natural expiry.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:36" json:"jti"`
+	CreatedAt time.Time `json:"createdAt"`
+	UserID    uint      `gorm:"index" json:"userId"`
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+}
+
+// API key scopes. Keys only work on the ingest routes, each of which requires one scope.
+const (
+	ScopeProjectsWrite = "projects:write"
+	ScopePluginsWrite  = "plugins:write"
+)
+
+// APIKey lets a plugin or script call the ingest API without a user session.
+// Only a hash of the secret is stored; Prefix identifies the key in lists and logs.
+type APIKey struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	UserID uint `gorm:"index" json:"userId"`
+	User   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	Name   string                      `gorm:"size:100" json:"name"`
+	Prefix string                      `gorm:"size:16;uniqueIndex" json:"prefix"`
+	Hash   string                      `gorm:"size:64" json:"-"`
+	Scopes datatypes.JSONSlice[string] `json:"scopes"`
+
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type DeviceAuthStatus string
+
+const (
+	DeviceAuthPending  DeviceAuthStatus = "pending"
+	DeviceAuthApproved DeviceAuthStatus = "approved"
+	DeviceAuthDenied   DeviceAuthStatus = "denied"
+	DeviceAuthConsumed DeviceAuthStatus = "consumed" // credential delivered to the device
+)
+
+// DeviceAuthorization is one run of the device login flow: the plugin shows UserCode, the
+// user approves it in the web app, and the plugin's poll with the device code is answered
+// with a fresh ingest API key.
+type DeviceAuthorization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	DeviceCodeHash string           `gorm:"size:64;uniqueIndex" json:"-"`
+	UserCode       string           `gorm:"size:9;uniqueIndex" json:"userCode"` // XXXX-XXXX
+	ClientName     string           `gorm:"size:100" json:"clientName"`
+	Status         DeviceAuthStatus `gorm:"size:20;default:pending" json:"status"`
+	IPAddress      string           `gorm:"size:45" json:"ipAddress"`
+
+	UserID   *uint `gorm:"index" json:"userId,omitempty"`
+	APIKeyID *uint `json:"apiKeyId,omitempty"`
+
+	IntervalSeconds int        `json:"interval"`
+	LastPolledAt    *time.Time `json:"-"`
+	ExpiresAt       time.Time  `gorm:"index" json:"expiresAt"`
+}
+
+// ProjectSession is a stretch of work on a project, inferred from heartbeats: heartbeats
+// closer together than the idle gap belong to the same session.
+type ProjectSession struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	ProjectID uint    `gorm:"index:idx_project_sessions_span,priority:1" json:"projectId"`
+	Project   Project `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint    `gorm:"index" json:"userId"`
+
+	StartedAt       time.Time `gorm:"index:idx_project_sessions_span,priority:2" json:"startedAt"`
+	EndedAt         time.Time `json:"endedAt"`
+	DurationSeconds int       `json:"durationSeconds"` // EndedAt - StartedAt
+	HeartbeatCount  int       `json:"heartbeatCount"`
+	DAW             string    `gorm:"size:100" json:"daw"`
+	PluginVersion   string    `gorm:"size:50" json:"pluginVersion"`
+}
+
+// ProjectHeartbeat is one ingest call from the VST, kept as history.
+type ProjectHeartbeat struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ProjectID uint           `gorm:"index:idx_project_heartbeats_at,priority:1" json:"projectId"`
+	Project   Project        `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	SessionID uint           `gorm:"index" json:"sessionId"`
+	Session   ProjectSession `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	At                    time.Time      `gorm:"index:idx_project_heartbeats_at,priority:2" json:"at"`
+	DAW                   string         `gorm:"size:100" json:"daw"`
+	PluginVersion         string         `gorm:"size:50" json:"pluginVersion"`
+	ClientDurationSeconds int            `json:"clientDurationSeconds"` // as reported; informational only
+	Metadata              datatypes.JSON `json:"metadata"`
+}
+
+type PluginEventKind string
+
+const (
+	PluginAdded          PluginEventKind = "added"
+	PluginRemoved        PluginEventKind = "removed"
+	PluginVersionChanged PluginEventKind = "version_changed"
+)
+
+// PluginEvent is one entry of a project's append-only plugin change log.
+type PluginEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_plugin_events_project,priority:2" json:"createdAt"`
+
+	ProjectID uint    `gorm:"index:idx_plugin_events_project,priority:1" json:"projectId"`
+	Project   Project `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	PluginID  uint    `gorm:"index" json:"pluginId"`
+	Plugin    Plugin  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	Kind            PluginEventKind `gorm:"size:20" json:"kind"`
+	Name            string          `gorm:"size:120" json:"name"`
+	Vendor          string          `gorm:"size:120" json:"vendor"`
+	Format          string          `gorm:"size:20" json:"format"`
+	Version         string          `gorm:"size:50" json:"version"`
+	PreviousVersion string          `gorm:"size:50" json:"previousVersion,omitempty"` // version_changed only
+}
+
+// PluginCatalog is the canonical identity of a plugin across projects, so "Serum",
+// "serum" and "Serum x64" count as one.
+type PluginCatalog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Slug   string `gorm:"size:140;uniqueIndex" json:"slug"`
+	Name   string `gorm:"size:120" json:"name"`
+	Vendor string `gorm:"size:120" json:"vendor"` // normalized, e.g. "Xfer Records"
+
+	Aliases []PluginAlias `gorm:"foreignKey:CatalogID" json:"aliases,omitempty"`
+}
+
+// PluginAlias maps a normalized plugin name to its catalog entry.
+type PluginAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	CatalogID uint          `gorm:"index" json:"catalogId"`
+	Catalog   PluginCatalog `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Key       string        `gorm:"size:140;uniqueIndex" json:"key"`
+}
+
+// IngestReceipt remembers a batch ingest item by its client idempotency key, so a plugin
+// re-sending its offline queue does not apply anything twice.
+type IngestReceipt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	UserID    uint   `gorm:"uniqueIndex:idx_ingest_receipts_key,priority:1" json:"userId"`
+	Key       string `gorm:"size:100;uniqueIndex:idx_ingest_receipts_key,priority:2" json:"key"`
+	Type      string `gorm:"size:20" json:"type"`
+	ProjectID uint   `json:"projectId,omitempty"`
+	PluginID  uint   `json:"pluginId,omitempty"`
+}
+
+// IdempotencyKey stores the outcome of a request sent with an Idempotency-Key header.
+// Key is a hash of the caller scope and the client's key.
+type IdempotencyKey struct {
+	Key       string `gorm:"primaryKey;size:64"`
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+
+	Fingerprint string `gorm:"size:64"` // hash of method, path and body
+	Status      int    // 0 while the first request is running
+	ContentType string `gorm:"size:100"`
+	Body        []byte
+}
+
+// Follow is one user following another's public activity.
+type Follow struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	FollowerID uint `gorm:"uniqueIndex:idx_follows_pair,priority:1" json:"followerId"`
+	Follower   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	FolloweeID uint `gorm:"uniqueIndex:idx_follows_pair,priority:2;index" json:"followeeId"`
+	Followee   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+type ActivityKind string
+
+const (
+	ActivityProjectPublished ActivityKind = "project_published"
+	ActivityProjectCompleted ActivityKind = "project_completed"
+	ActivityChallengeEntered ActivityKind = "challenge_entered"
+)
+
+// Activity is something a user did that their followers see in their feed. Feeds are
+// assembled when read, so an activity is written once, however many followers there are.
+type Activity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_activities_user_created,priority:2" json:"createdAt"`
+
+	UserID uint         `gorm:"index:idx_activities_user_created,priority:1" json:"userId"`
+	User   User         `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Kind   ActivityKind `gorm:"size:30" json:"kind"`
+
+	ProjectID   uint       `gorm:"index" json:"projectId"`
+	Project     Project    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ChallengeID *uint      `gorm:"index" json:"challengeId,omitempty"`
+	Challenge   *Challenge `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Comment is feedback on a public project. Replies point at a top-level comment, so threads
+// are one level deep. TimestampSeconds pins a comment to a moment in the project's audio.
+type Comment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_comments_project_created,priority:2" json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	ProjectID uint     `gorm:"index:idx_comments_project_created,priority:1" json:"projectId"`
+	Project   Project  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint     `gorm:"index" json:"userId"`
+	User      User     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ParentID  *uint    `gorm:"index" json:"parentId,omitempty"`
+	Parent    *Comment `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	Body             string     `gorm:"size:2000" json:"body"`
+	TimestampSeconds *float64   `json:"timestampSeconds,omitempty"`
+	AudioFileID      *uint      `json:"audioFileId,omitempty"`
+	AudioFile        *AudioFile `gorm:"constraint:OnDelete:SET NULL" json:"-"`
+	EditedAt         *time.Time `json:"editedAt,omitempty"`
+
+	// A comment deleted by its author or removed by a moderator stays as a placeholder
+	// while it has replies; its body is no longer shown.
+	DeletedAt     *time.Time `gorm:"index" json:"deletedAt,omitempty"`
+	RemovedAt     *time.Time `json:"removedAt,omitempty"`
+	RemovedByID   *uint      `json:"-"`
+	RemovedReason string     `gorm:"size:200" json:"-"`
+}
+
+// ProjectLike is one user liking a project; each user likes a project at most once.
+type ProjectLike struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ProjectID uint    `gorm:"uniqueIndex:idx_project_likes_pair,priority:1" json:"projectId"`
+	Project   Project `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID    uint    `gorm:"uniqueIndex:idx_project_likes_pair,priority:2;index" json:"userId"`
+	User      User    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+type EngagementKind string
+
+const (
+	EngagementPlay        EngagementKind = "play"
+	EngagementLike        EngagementKind = "like"
+	EngagementProfileView EngagementKind = "profile_view"
+)
+
+// EngagementHit remembers that a viewer (a user, or a hashed IP) was counted for a target in
+// one time window, so repeats within the window are not counted again.
+type EngagementHit struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	Kind      EngagementKind `gorm:"size:20;uniqueIndex:idx_engagement_hits_key,priority:1"`
+	TargetID  uint           `gorm:"uniqueIndex:idx_engagement_hits_key,priority:2"`
+	ViewerKey string         `gorm:"size:40;uniqueIndex:idx_engagement_hits_key,priority:3"`
+	Bucket    int64          `gorm:"uniqueIndex:idx_engagement_hits_key,priority:4"` // window number since the epoch
+}
+
+// CounterDelta is a pending change to an engagement counter. The aggregator folds deltas
+// into the counter columns, so hot rows are not updated on every play.
+type CounterDelta struct {
+	ID       uint           `gorm:"primaryKey"`
+	Kind     EngagementKind `gorm:"size:20"`
+	TargetID uint
+	Delta    int
 }
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+// recordActivity notes something a user did for their followers' feeds. A project is
+// published or completed at most once as far as feeds go, so toggling it back and forth
+// does not flood them; challenge entries are tracked by onChallengeEntry instead.
+func recordActivity(tx *gorm.DB, userID uint, kind models.ActivityKind, projectID uint, at time.Time) error {
+	var n int64
+	err := tx.Model(&models.Activity{}).Where("user_id = ? AND kind = ? AND project_id = ?", userID, kind, projectID).Count(&n).Error
+	if err != nil || n > 0 {
+		return err
+	}
+	return tx.Create(&models.Activity{UserID: userID, Kind: kind, ProjectID: projectID, CreatedAt: at}).Error
+}
+
+// onChallengeEntry keeps one activity per challenge entry, pointing at the entered project.
+func onChallengeEntry(tx *gorm.DB, entry *models.ChallengeEntry) error {
+	res := tx.Model(&models.Activity{}).
+		Where("user_id = ? AND kind = ? AND challenge_id = ?", entry.UserID, models.ActivityChallengeEntered, entry.ChallengeID).
+		Update("project_id", entry.ProjectID)
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	challengeID := entry.ChallengeID
+	return tx.Create(&models.Activity{UserID: entry.UserID, Kind: models.ActivityChallengeEntered,
+		ProjectID: entry.ProjectID, ChallengeID: &challengeID}).Error
+}
+
+// onChallengeWithdraw drops the activity of a withdrawn entry.
+func onChallengeWithdraw(tx *gorm.DB, userID, challengeID uint) error {
+	return tx.Where("user_id = ? AND kind = ? AND challenge_id = ?", userID, models.ActivityChallengeEntered, challengeID).
+		Delete(&models.Activity{}).Error
+}
+
+// FeedService assembles a user's feed from the activities of the people they follow.
+// Nothing is copied per follower: the feed is read through the follows index, which keeps
+// writes cheap and lets unfollowing or going private take effect immediately.
+type FeedService struct {
+	DB *gorm.DB
+}
+
+func NewFeedService(db *gorm.DB) *FeedService { return &FeedService{DB: db} }
+
+// FeedItem is one activity with what a feed needs to render it.
+type FeedItem struct {
+	ID             uint                `json:"id"`
+	Kind           models.ActivityKind `json:"kind"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UserID         uint                `json:"userId"`
+	Username       string              `json:"username"`
+	DisplayName    string              `json:"displayName"`
+	Picture        string              `json:"picture,omitempty"`
+	ProjectID      uint                `json:"projectId"`
+	ProjectTitle   string              `json:"projectTitle"`
+	ProjectDAW     string              `json:"projectDaw"`
+	ChallengeID    *uint               `json:"challengeId,omitempty"`
+	ChallengeSlug  string              `json:"challengeSlug,omitempty"`
+	ChallengeTitle string              `json:"challengeTitle,omitempty"`
+}
+
+// Feed pages through the activities of the users userID follows, newest first. Activities
+// of private profiles and of projects that are private or in the trash are left out.
+func (s *FeedService) Feed(userID uint, page CursorPage) (*Page[FeedItem], error) {
+	q := s.DB.Table("activities AS a").
+		Select("a.id, a.kind, a.created_at, a.user_id, u.username, u.display_name, u.picture, "+
+			"a.project_id, p.title AS project_title, p.daw AS project_daw, a.challenge_id, "+
+			"c.slug AS challenge_slug, c.title AS challenge_title").
+		Joins("JOIN users u ON u.id = a.user_id").
+		Joins("JOIN projects p ON p.id = a.project_id").
+		Joins("LEFT JOIN challenges c ON c.id = a.challenge_id").
+		Where("a.user_id IN (?)", s.DB.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", userID)).
+		Where("u.public = ? AND p.public = ? AND p.deleted_at IS NULL", true, true)
+	v, p := timeKey(func(it *FeedItem) time.Time { return it.CreatedAt })
+	return paginate(q, page, keyset[FeedItem]{Name: "feed", Column: "a.created_at", IDColumn: "a.id", Desc: true,
+		value: v, parse: p, id: func(it *FeedItem) uint { return it.ID }})
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+var ErrSelfDemotion = errors.New("admins cannot demote or delete themselves")
+
+const maxAdminPageSize = 100
+
+// AdminService backs the /api/v1/admin routes. Handlers authorize by role before calling it.
+type AdminService struct{ DB *gorm.DB }
+
+func NewAdminService(db *gorm.DB) *AdminService { return &AdminService{DB: db} }
+
+// AdminPage is a limit/offset window with the total row count.
+type AdminPage struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+func (p AdminPage) apply(q *gorm.DB) *gorm.DB {
+	if p.Limit <= 0 || p.Limit > maxAdminPageSize {
+		p.Limit = maxAdminPageSize
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	return q.Limit(p.Limit).Offset(p.Offset)
+}
+
+type AdminUserFilter struct {
+	AdminPage
+	Query string `form:"q"` // email, username or display name substring
+	Role  string `form:"role"`
+}
+
+type AdminUserUpdate struct {
+	Role        *models.Role `json:"role"`
+	Public      *bool        `json:"public"`
+	DisplayName *string      `json:"displayName"`
+	Bio         *string      `json:"bio"`
+}
+
+type AdminProjectFilter struct {
+	AdminPage
+	UserID uint   `form:"userId"`
+	Query  string `form:"q"`
+}
+
+type AdminProjectUpdate struct {
+	Public *bool                 `json:"public"`
+	Status *models.ProjectStatus `json:"status"`
+}
+
+func likePattern(q string) string {
+	return "%" + strings.ToLower(strings.TrimSpace(q)) + "%"
+}
+
+func (s *AdminService) ListUsers(f AdminUserFilter) ([]models.User, int64, error) {
+	q := s.DB.Model(&models.User{})
+	if f.Query != "" {
+		like := likePattern(f.Query)
+		q = q.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ? OR LOWER(display_name) LIKE ?", like, like, like)
+	}
+	if f.Role != "" {
+		q = q.Where("role = ?", f.Role)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if err := f.apply(q).Order("id asc").Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *AdminService) GetUser(id uint) (*models.User, error) {
+	var u models.User
+	if err := s.DB.First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpdateUser edits a user. Only admins may change roles (enforced by the caller's route);
+// actorID keeps an admin from locking themselves out.
+func (s *AdminService) UpdateUser(actorID, id uint, in AdminUserUpdate) (*models.User, error) {
+	u, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if in.Role != nil {
+		if !in.Role.Valid() {
+			return nil, errors.New("role must be user, moderator or admin")
+		}
+		if id == actorID && *in.Role != models.RoleAdmin {
+			return nil, ErrSelfDemotion
+		}
+		updates["role"] = *in.Role
+	}
+	if in.Public != nil {
+		updates["public"] = *in.Public
+	}
+	if in.DisplayName != nil {
+		updates["display_name"] = strings.TrimSpace(*in.DisplayName)
+	}
+	if in.Bio != nil {
+		updates["bio"] = *in.Bio
+	}
+	if len(updates) == 0 {
+		return u, nil
+	}
+	if err := s.DB.Model(u).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// DeleteUser removes a user; projects, entries and votes cascade.
+func (s *AdminService) DeleteUser(actorID, id uint) error {
+	if id == actorID {
+		return ErrSelfDemotion
+	}
+	res := s.DB.Delete(&models.User{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *AdminService) ListRSVPs(q string, page AdminPage) ([]models.RSVP, int64, error) {
+	db := s.DB.Model(&models.RSVP{})
+	if q != "" {
+		like := likePattern(q)
+		db = db.Where("LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ? OR referral_code LIKE ?", like, like, like, like)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []models.RSVP
+	if err := page.apply(db).Order("created_at desc").Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (s *AdminService) DeleteRSVP(id uint) error {
+	res := s.DB.Delete(&models.RSVP{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *AdminService) ListProjects(f AdminProjectFilter) ([]models.Project, int64, error) {
+	q := s.DB.Model(&models.Project{})
+	if f.UserID != 0 {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if f.Query != "" {
+		q = q.Where("LOWER(title) LIKE ?", likePattern(f.Query))
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []models.Project
+	if err := f.apply(q).Order("updated_at desc").Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// UpdateProject lets moderators hide a project from public listings or fix its status.
+func (s *AdminService) UpdateProject(id uint, in AdminProjectUpdate) (*models.Project, error) {
+	var p models.Project
+	if err := s.DB.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if in.Public != nil {
+		updates["public"] = *in.Public
+	}
+	if in.Status != nil {
+		su, err := statusUpdates(&p, *in.Status)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range su {
+			updates[k] = v
+		}
+	}
+	if len(updates) > 0 {
+		if err := s.DB.Model(&p).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := s.DB.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteProject removes a project with its plugins and audio rows, including one the owner
+// already moved to the trash. Stored blobs are left to the bucket's lifecycle rules.
+func (s *AdminService) DeleteProject(id uint) error {
+	res := s.DB.Unscoped().Delete(&models.Project{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/audio"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// AnalysisService decodes uploaded audio in the background and stores duration,
+// format, loudness and waveform peaks on the AudioFile row. Work is tracked through
+// AnalysisStatus, so pending files are picked up again after a restart.
+type AnalysisService struct {
+	DB    *gorm.DB
+	Store storage.BlobStore
+	queue chan uint
+}
+
+func NewAnalysisService(db *gorm.DB, store storage.BlobStore) *AnalysisService {
+	return &AnalysisService{DB: db, Store: store, queue: make(chan uint, 256)}
+}
+
+// Enqueue schedules a file without blocking; if the queue is full the next poll finds it.
+func (s *AnalysisService) Enqueue(audioID uint) {
+	if s == nil {
+		return
+	}
+	select {
+	case s.queue <- audioID:
+	default:
+	}
+}
+
+// Run starts the workers and polls for pending files. It never returns.
+func (s *AnalysisService) Run(workers int, poll time.Duration) {
+	// Anything left running belonged to a previous process that died mid-job.
+	s.DB.Model(&models.AudioFile{}).Where("analysis_status = ?", models.AnalysisRunning).
+		Update("analysis_status", models.AnalysisPending)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range s.queue {
+				if err := s.Process(context.Background(), id); err != nil {
+					log.Printf("[analysis] audio %d: %v", id, err)
+				}
+			}
+		}()
+	}
+	for {
+		var ids []uint
+		s.DB.Model(&models.AudioFile{}).Where("analysis_status = ?", models.AnalysisPending).
+			Order("id asc").Limit(cap(s.queue)).Pluck("id", &ids)
+		for _, id := range ids {
+			s.Enqueue(id)
+		}
+		time.Sleep(poll)
+	}
+}
+
+// Process analyzes one file. It claims the row first so duplicate enqueues are harmless.
+func (s *AnalysisService) Process(ctx context.Context, audioID uint) error {
+	res := s.DB.Model(&models.AudioFile{}).
+		Where("id = ? AND analysis_status = ?", audioID, models.AnalysisPending).
+		Update("analysis_status", models.AnalysisRunning)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	var af models.AudioFile
+	if err := s.DB.First(&af, audioID).Error; err != nil {
+		return err
+	}
+
+	result, err := s.analyze(ctx, &af)
+	now := time.Now()
+	updates := map[string]interface{}{"analyzed_at": now, "analysis_error": ""}
+	switch {
+	case errors.Is(err, audio.ErrUnsupportedFormat):
+		updates["analysis_status"] = models.AnalysisUnsupported
+	case err != nil:
+		updates["analysis_status"] = models.AnalysisFailed
+		msg := err.Error()
+		if len(msg) > 255 {
+			msg = msg[:255]
+		}
+		updates["analysis_error"] = msg
+	default:
+		peaks, _ := json.Marshal(result.Peaks)
+		updates["analysis_status"] = models.AnalysisDone
+		updates["duration_seconds"] = result.DurationSeconds
+		updates["sample_rate"] = result.SampleRate
+		updates["channels"] = result.Channels
+		updates["loudness_lufs"] = result.LoudnessLUFS
+		updates["peaks"] = peaks
+	}
+	if uerr := s.DB.Model(&models.AudioFile{}).Where("id = ?", audioID).Updates(updates).Error; uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+func (s *AnalysisService) analyze(ctx context.Context, af *models.AudioFile) (a *audio.Analysis, err error) {
+	// Decoders read untrusted uploads; a crafted file must fail its row, not the server.
+	defer func() {
+		if r := recover(); r != nil {
+			a, err = nil, fmt.Errorf("decoder panic: %v", r)
+		}
+	}()
+	rc, err := s.Store.Open(ctx, af.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return audio.Analyze(rc, af.ContentType)
+}
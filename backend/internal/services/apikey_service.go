@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const (
+	// APIKeyPrefix marks a bearer credential as an API key rather than a JWT.
+	APIKeyPrefix = "up_"
+
+	maxActiveAPIKeys = 25
+	// lastUsedResolution limits last_used_at writes to one per key per minute.
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrTooManyAPIKeys = fmt.Errorf("at most %d active api keys per user", maxActiveAPIKeys)
+	ErrUnknownScope   = errors.New("unknown scope")
+)
+
+var allAPIKeyScopes = []string{models.ScopeProjectsWrite, models.ScopePluginsWrite}
+
+const (
+	apiKeyAlphabet     = "abcdefghijklmnopqrstuvwxyz0123456789"
+	apiKeyIDLength     = 8
+	apiKeySecretLength = 32
+)
+
+type APIKeyService struct{ DB *gorm.DB }
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService { return &APIKeyService{DB: db} }
+
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes"`        // defaults to every ingest scope
+	ExpiresInDays int      `json:"expiresInDays"` // 0 = never
+}
+
+// randomString draws n characters uniformly from alphabet (at most 256 symbols).
+func randomString(alphabet string, n int) (string, error) {
+	// Reject bytes above the largest multiple of the alphabet size to avoid modulo bias.
+	limit := 256 - 256%len(alphabet)
+	out := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(out) < n {
+				out = append(out, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(out), nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashAPIKey(key string) string { return sha256Hex(key) }
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return append([]string(nil), allAPIKeyScopes...), nil
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		valid := false
+		for _, known := range allAPIKeyScopes {
+			valid = valid || s == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w %q", ErrUnknownScope, s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// checkAPIKeyLimit returns ErrTooManyAPIKeys when the user cannot be issued another key.
+func checkAPIKeyLimit(db *gorm.DB, userID uint) error {
+	var active int64
+	if err := db.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&active).Error; err != nil {
+		return err
+	}
+	if active >= maxActiveAPIKeys {
+		return ErrTooManyAPIKeys
+	}
+	return nil
+}
+
+// Create issues a key and returns it with its plaintext secret, which is never shown again.
+// Keys look like up_<8 char id>_<32 char secret>; the up_<id> part is the stored prefix.
+func (s *APIKeyService) Create(userID uint, in CreateAPIKeyInput) (*models.APIKey, string, error) {
+	scopes, err := normalizeScopes(in.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if in.ExpiresInDays < 0 {
+		return nil, "", errors.New("expiresInDays must not be negative")
+	}
+	if err := checkAPIKeyLimit(s.DB, userID); err != nil {
+		return nil, "", err
+	}
+	id, err := randomString(apiKeyAlphabet, apiKeyIDLength)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString(apiKeyAlphabet, apiKeySecretLength)
+	if err != nil {
+		return nil, "", err
+	}
+	prefix := APIKeyPrefix + id
+	plain := prefix + "_" + secret
+	key := models.APIKey{
+		UserID: userID,
+		Name:   strings.TrimSpace(in.Name),
+		Prefix: prefix,
+		Hash:   hashAPIKey(plain),
+		Scopes: scopes,
+	}
+	if in.ExpiresInDays > 0 {
+		exp := time.Now().AddDate(0, 0, in.ExpiresInDays)
+		key.ExpiresAt = &exp
+	}
+	if err := s.DB.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+	return &key, plain, nil
+}
+
+// ListByUser returns the user's keys, newest first, including revoked ones.
+func (s *APIKeyService) ListByUser(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke disables a key immediately. Revoking twice is a no-op.
+func (s *APIKeyService) Revoke(userID, keyID uint) error {
+	var key models.APIKey
+	if err := s.DB.Where("id = ? AND user_id = ?", keyID, userID).First(&key).Error; err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	return s.DB.Model(&key).Update("revoked_at", time.Now()).Error
+}
+
+// Authenticate resolves a plaintext key to its active record and stamps last use.
+func (s *APIKeyService) Authenticate(plain string) (*models.APIKey, error) {
+	if !strings.HasPrefix(plain, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(plain[len(APIKeyPrefix):], "_")
+	if !ok || len(prefix) != apiKeyIDLength {
+		return nil, ErrInvalidAPIKey
+	}
+	var key models.APIKey
+	if err := s.DB.Where("prefix = ?", APIKeyPrefix+prefix).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(plain))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.DB.Model(&key).UpdateColumn("last_used_at", now).Error; err == nil {
+			key.LastUsedAt = &now
+		}
+	}
+	return &key, nil
+}
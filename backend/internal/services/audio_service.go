@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+var (
+	ErrAudioTooLarge    = errors.New("file exceeds upload size limit")
+	ErrUnsupportedAudio = errors.New("unsupported audio format; use WAV, AIFF, FLAC, MP3 or OGG")
+)
+
+// audioExtensions lists the accepted formats, keyed by the content type SniffAudioType reports.
+var audioExtensions = map[string]string{
+	"audio/wav":  ".wav",
+	"audio/aiff": ".aiff",
+	"audio/flac": ".flac",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+}
+
+type AudioService struct {
+	DB       *gorm.DB
+	Store    storage.BlobStore
+	MaxBytes int64
+	Analysis *AnalysisService // optional; receives every newly stored file
+}
+
+func NewAudioService(db *gorm.DB, store storage.BlobStore, maxBytes int64) *AudioService {
+	return &AudioService{DB: db, Store: store, MaxBytes: maxBytes}
+}
+
+// SniffAudioType inspects the leading bytes of a file and returns its audio content type,
+// or "" if the format is not accepted. Client supplied content types are never trusted.
+func SniffAudioType(head []byte) string {
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return "audio/wav"
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("FORM")) && (bytes.Equal(head[8:12], []byte("AIFF")) || bytes.Equal(head[8:12], []byte("AIFC"))):
+		return "audio/aiff"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "audio/mpeg"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		// bare MPEG audio frame sync
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// AudioContentType maps a stored key or filename back to the content type it was accepted as.
+func AudioContentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	for ctype, e := range audioExtensions {
+		if e == ext {
+			return ctype
+		}
+	}
+	return "application/octet-stream"
+}
+
+// cappedReader fails with ErrAudioTooLarge as soon as more than left bytes have been read.
+type cappedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.left < 0 {
+		return 0, ErrAudioTooLarge
+	}
+	if int64(len(p)) > c.left+1 {
+		p = p[:c.left+1]
+	}
+	n, err := c.r.Read(p)
+	c.left -= int64(n)
+	if c.left < 0 {
+		return n, ErrAudioTooLarge
+	}
+	return n, err
+}
+
+// cleanFilename keeps only the base name of a client supplied filename.
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+// Upload streams body into the blob store and records it against the user's project.
+// The body is never buffered in full; size and format are enforced while streaming.
+func (s *AudioService) Upload(ctx context.Context, userID, projectID uint, filename string, body io.Reader) (*models.AudioFile, error) {
+	if _, err := findOwnedProject(s.DB, userID, projectID); err != nil {
+		return nil, err
+	}
+	return s.store(ctx, userID, projectID, filename, body, s.MaxBytes)
+}
+
+// store sniffs, hashes and writes body under a fresh key, then creates the AudioFile row.
+// Callers are responsible for checking project ownership.
+func (s *AudioService) store(ctx context.Context, userID, projectID uint, filename string, body io.Reader, limit int64) (*models.AudioFile, error) {
+	br := bufio.NewReaderSize(body, 512)
+	head, _ := br.Peek(12)
+	ctype := SniffAudioType(head)
+	if ctype == "" {
+		return nil, ErrUnsupportedAudio
+	}
+
+	key := fmt.Sprintf("projects/%d/audio/%s%s", projectID, uuid.NewString(), audioExtensions[ctype])
+	h := sha256.New()
+	n, err := s.Store.Put(ctx, key, io.TeeReader(&cappedReader{r: br, left: limit}, h), ctype)
+	if err != nil {
+		return nil, err
+	}
+	af := models.AudioFile{
+		ProjectID:   projectID,
+		UserID:      userID,
+		StorageKey:  key,
+		Filename:    cleanFilename(filename),
+		ContentType: ctype,
+		SizeBytes:   n,
+		Checksum:    hex.EncodeToString(h.Sum(nil)),
+
+		AnalysisStatus: models.AnalysisPending,
+	}
+	if err := s.DB.Create(&af).Error; err != nil {
+		_ = s.Store.Delete(ctx, key)
+		return nil, err
+	}
+	s.Analysis.Enqueue(af.ID)
+	return &af, nil
+}
+
+func (s *AudioService) ListByProject(userID, projectID uint) ([]models.AudioFile, error) {
+	if _, err := findOwnedProject(s.DB, userID, projectID); err != nil {
+		return nil, err
+	}
+	var items []models.AudioFile
+	if err := s.DB.Where("project_id = ?", projectID).Order("created_at desc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *AudioService) Get(userID, projectID, audioID uint) (*models.AudioFile, error) {
+	var af models.AudioFile
+	if err := s.DB.Where("id = ? AND project_id = ? AND user_id = ?", audioID, projectID, userID).First(&af).Error; err != nil {
+		return nil, err
+	}
+	return &af, nil
+}
+
+// Delete removes the record first so a failed blob delete leaves an orphaned object rather than a dangling row.
+func (s *AudioService) Delete(ctx context.Context, userID, projectID, audioID uint) error {
+	af, err := s.Get(userID, projectID, audioID)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.Delete(af).Error; err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, af.StorageKey)
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const (
+	PhaseUpcoming = "upcoming"
+	PhaseOpen     = "open"
+	PhaseVoting   = "voting"
+	PhaseClosed   = "closed"
+	PhaseJudged   = "judged"
+)
+
+var (
+	ErrChallengeNotOpen   = errors.New("challenge is not accepting entries")
+	ErrChallengeNotClosed = errors.New("challenge has not closed yet")
+	ErrEntryLocked        = errors.New("entries are locked")
+)
+
+type ChallengeService struct{ DB *gorm.DB }
+
+func NewChallengeService(db *gorm.DB) *ChallengeService { return &ChallengeService{DB: db} }
+
+type ChallengeInput struct {
+	Slug           *string    `json:"slug"`
+	Title          *string    `json:"title"`
+	Description    *string    `json:"description"`
+	Rules          *string    `json:"rules"`
+	StartsAt       *time.Time `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt"`
+	RequiredPlugin *string    `json:"requiredPlugin"`
+	RequiredDAW    *string    `json:"requiredDaw"`
+	VotingStartsAt *time.Time `json:"votingStartsAt"`
+	VotingEndsAt   *time.Time `json:"votingEndsAt"`
+	VotingMode     *string    `json:"votingMode"`
+	BallotSize     *int       `json:"ballotSize"`
+}
+
+type SubmitEntryInput struct {
+	ProjectID   uint  `json:"projectId"`
+	AudioFileID *uint `json:"audioFileId"`
+}
+
+type Placement struct {
+	EntryID   uint `json:"entryId"`
+	Placement int  `json:"placement"`
+}
+
+// ChallengePhase derives where a challenge is in its lifecycle.
+func ChallengePhase(ch *models.Challenge, now time.Time) string {
+	switch {
+	case ch.AnnouncedAt != nil:
+		return PhaseJudged
+	case now.Before(ch.StartsAt):
+		return PhaseUpcoming
+	case now.Before(ch.EndsAt):
+		return PhaseOpen
+	case VotingOpen(ch, now):
+		return PhaseVoting
+	default:
+		return PhaseClosed
+	}
+}
+
+// votingStart is when ballots open: VotingStartsAt if set, otherwise the submission deadline.
+func votingStart(ch *models.Challenge) time.Time {
+	if ch.VotingStartsAt != nil {
+		return *ch.VotingStartsAt
+	}
+	return ch.EndsAt
+}
+
+// VotingOpen reports whether ballots are accepted at now.
+func VotingOpen(ch *models.Challenge, now time.Time) bool {
+	return ch.VotingEndsAt != nil && !now.Before(votingStart(ch)) && now.Before(*ch.VotingEndsAt)
+}
+
+var slugStrip = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(s string) string {
+	s = strings.Trim(slugStrip.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 80 {
+		s = strings.TrimRight(s[:80], "-")
+	}
+	return s
+}
+
+func applyChallengeInput(ch *models.Challenge, in ChallengeInput) {
+	if in.Title != nil {
+		ch.Title = strings.TrimSpace(*in.Title)
+	}
+	if in.Slug != nil {
+		ch.Slug = slugify(*in.Slug)
+	}
+	if in.Description != nil {
+		ch.Description = *in.Description
+	}
+	if in.Rules != nil {
+		ch.Rules = *in.Rules
+	}
+	if in.StartsAt != nil {
+		ch.StartsAt = *in.StartsAt
+	}
+	if in.EndsAt != nil {
+		ch.EndsAt = *in.EndsAt
+	}
+	if in.RequiredPlugin != nil {
+		ch.RequiredPlugin = strings.TrimSpace(*in.RequiredPlugin)
+	}
+	if in.RequiredDAW != nil {
+		ch.RequiredDAW = strings.TrimSpace(*in.RequiredDAW)
+	}
+	if in.VotingStartsAt != nil {
+		ch.VotingStartsAt = in.VotingStartsAt
+	}
+	if in.VotingEndsAt != nil {
+		ch.VotingEndsAt = in.VotingEndsAt
+	}
+	if in.VotingMode != nil {
+		ch.VotingMode = models.VotingMode(strings.ToLower(*in.VotingMode))
+	}
+	if in.BallotSize != nil {
+		ch.BallotSize = *in.BallotSize
+	}
+}
+
+func validateChallenge(ch *models.Challenge) error {
+	if ch.Title == "" {
+		return errors.New("title required")
+	}
+	if ch.Slug == "" {
+		return errors.New("slug required")
+	}
+	if ch.StartsAt.IsZero() || ch.EndsAt.IsZero() || !ch.EndsAt.After(ch.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	switch ch.VotingMode {
+	case "":
+		ch.VotingMode = models.VotingSingle
+	case models.VotingSingle, models.VotingRanked:
+	default:
+		return errors.New("votingMode must be single or ranked")
+	}
+	if ch.VotingMode == models.VotingSingle || ch.BallotSize < 1 {
+		ch.BallotSize = 1
+	}
+	if ch.BallotSize > 10 {
+		return errors.New("ballotSize must be at most 10")
+	}
+	if ch.VotingEndsAt != nil && !ch.VotingEndsAt.After(votingStart(ch)) {
+		return errors.New("votingEndsAt must be after the voting start")
+	}
+	if ch.VotingStartsAt != nil && ch.VotingStartsAt.Before(ch.StartsAt) {
+		return errors.New("votingStartsAt must not be before startsAt")
+	}
+	return nil
+}
+
+func (s *ChallengeService) Create(creatorID uint, in ChallengeInput) (*models.Challenge, error) {
+	ch := models.Challenge{CreatedByID: creatorID}
+	applyChallengeInput(&ch, in)
+	if ch.Slug == "" {
+		ch.Slug = slugify(ch.Title)
+	}
+	if err := validateChallenge(&ch); err != nil {
+		return nil, err
+	}
+	if err := s.DB.Create(&ch).Error; err != nil {
+		return nil, err
+	}
+	ch.Phase = ChallengePhase(&ch, time.Now())
+	return &ch, nil
+}
+
+// Update edits a challenge. Windows can no longer move once entries are locked.
+func (s *ChallengeService) Update(id uint, in ChallengeInput) (*models.Challenge, error) {
+	var ch models.Challenge
+	if err := s.DB.First(&ch, id).Error; err != nil {
+		return nil, err
+	}
+	if ch.LockedAt != nil && (in.StartsAt != nil || in.EndsAt != nil) {
+		return nil, ErrEntryLocked
+	}
+	applyChallengeInput(&ch, in)
+	if err := validateChallenge(&ch); err != nil {
+		return nil, err
+	}
+	if err := s.DB.Save(&ch).Error; err != nil {
+		return nil, err
+	}
+	ch.Phase = ChallengePhase(&ch, time.Now())
+	return &ch, nil
+}
+
+// Delete removes a challenge with its entries and votes.
+func (s *ChallengeService) Delete(id uint) error {
+	res := s.DB.Delete(&models.Challenge{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List returns challenges, optionally filtered by phase, soonest deadline first.
+func (s *ChallengeService) List(phase string) ([]models.Challenge, error) {
+	now := time.Now()
+	q := s.DB.Model(&models.Challenge{})
+	switch phase {
+	case "":
+	case PhaseUpcoming:
+		q = q.Where("starts_at > ?", now)
+	case PhaseOpen:
+		q = q.Where("starts_at <= ? AND ends_at > ?", now, now)
+	case PhaseVoting:
+		q = q.Where("announced_at IS NULL AND ends_at <= ? AND COALESCE(voting_starts_at, ends_at) <= ? AND voting_ends_at > ?", now, now, now)
+	case PhaseClosed:
+		q = q.Where("announced_at IS NULL AND ends_at <= ? AND (voting_ends_at IS NULL OR voting_ends_at <= ? OR COALESCE(voting_starts_at, ends_at) > ?)", now, now, now)
+	case PhaseJudged:
+		q = q.Where("announced_at IS NOT NULL")
+	default:
+		return nil, fmt.Errorf("unknown phase %q", phase)
+	}
+	var items []models.Challenge
+	if err := q.Order("ends_at asc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Phase = ChallengePhase(&items[i], now)
+	}
+	return items, nil
+}
+
+// Get loads a challenge by numeric id or slug with its entries, projects and entrants.
+func (s *ChallengeService) Get(idOrSlug string) (*models.Challenge, error) {
+	// Entries keep showing their project even if the owner has since trashed it.
+	q := s.DB.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("placement IS NULL, placement asc, created_at asc")
+	}).Preload("Entries.Project", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).Preload("Entries.User").Preload("Entries.AudioFile")
+	var ch models.Challenge
+	var err error
+	if id, perr := strconv.ParseUint(idOrSlug, 10, 64); perr == nil {
+		err = q.First(&ch, id).Error
+	} else {
+		err = q.Where("slug = ?", strings.ToLower(idOrSlug)).First(&ch).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch.Phase = ChallengePhase(&ch, time.Now())
+	return &ch, nil
+}
+
+// projectHasPlugin matches plugin names case-insensitively.
+func (s *ChallengeService) projectHasPlugin(projectID uint, name string) (bool, error) {
+	var n int64
+	err := s.DB.Model(&models.Plugin{}).Where("project_id = ? AND LOWER(name) = ? AND removed_at IS NULL", projectID, strings.ToLower(name)).Count(&n).Error
+	return n > 0, err
+}
+
+// Submit enters a project, replacing the user's previous entry while the challenge is open.
+func (s *ChallengeService) Submit(userID, challengeID uint, in SubmitEntryInput) (*models.ChallengeEntry, error) {
+	var ch models.Challenge
+	if err := s.DB.First(&ch, challengeID).Error; err != nil {
+		return nil, err
+	}
+	if ChallengePhase(&ch, time.Now()) != PhaseOpen || ch.LockedAt != nil {
+		return nil, ErrChallengeNotOpen
+	}
+	p, err := findOwnedProject(s.DB, userID, in.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Public {
+		return nil, errors.New("project must be public to enter a challenge")
+	}
+	if ch.RequiredDAW != "" && !strings.EqualFold(strings.TrimSpace(p.DAW), ch.RequiredDAW) {
+		return nil, fmt.Errorf("this challenge requires projects made in %s", ch.RequiredDAW)
+	}
+	if ch.RequiredPlugin != "" {
+		ok, err := s.projectHasPlugin(p.ID, ch.RequiredPlugin)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("this challenge requires the %s plugin", ch.RequiredPlugin)
+		}
+	}
+	if in.AudioFileID != nil {
+		var n int64
+		if err := s.DB.Model(&models.AudioFile{}).Where("id = ? AND project_id = ?", *in.AudioFileID, p.ID).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errors.New("audio file does not belong to project")
+		}
+	}
+
+	var entry models.ChallengeEntry
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// The locker takes the same row lock, so entries cannot change once it has run.
+		var cur models.Challenge
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cur, challengeID).Error; err != nil {
+			return err
+		}
+		if ChallengePhase(&cur, time.Now()) != PhaseOpen || cur.LockedAt != nil {
+			return ErrChallengeNotOpen
+		}
+		err := tx.Where("challenge_id = ? AND user_id = ?", challengeID, userID).First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			entry = models.ChallengeEntry{ChallengeID: challengeID, UserID: userID, ProjectID: p.ID, AudioFileID: in.AudioFileID}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			return onChallengeEntry(tx, &entry)
+		}
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&models.ChallengeEntry{}).Where("id = ? AND locked = ?", entry.ID, false).
+			Updates(map[string]interface{}{"project_id": p.ID, "audio_file_id": in.AudioFileID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrEntryLocked
+		}
+		entry.ProjectID, entry.AudioFileID = p.ID, in.AudioFileID
+		return onChallengeEntry(tx, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *ChallengeService) Withdraw(userID, challengeID uint) error {
+	var entry models.ChallengeEntry
+	if err := s.DB.Where("challenge_id = ? AND user_id = ?", challengeID, userID).First(&entry).Error; err != nil {
+		return err
+	}
+	if entry.Locked {
+		return ErrEntryLocked
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		return onChallengeWithdraw(tx, userID, challengeID)
+	})
+}
+
+// lock freezes all entries of a challenge, pinning each to its project's latest upload.
+func lockChallenge(tx *gorm.DB, ch *models.Challenge, now time.Time) error {
+	// Waits for submissions in flight, which hold this lock while they write.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Challenge{}, ch.ID).Error; err != nil {
+		return err
+	}
+	var entries []models.ChallengeEntry
+	if err := tx.Where("challenge_id = ? AND audio_file_id IS NULL", ch.ID).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var af models.AudioFile
+		err := tx.Where("project_id = ?", e.ProjectID).Order("created_at desc").First(&af).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ChallengeEntry{}).Where("id = ?", e.ID).Update("audio_file_id", af.ID).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.ChallengeEntry{}).Where("challenge_id = ?", ch.ID).Update("locked", true).Error; err != nil {
+		return err
+	}
+	ch.LockedAt = &now
+	return tx.Model(ch).Update("locked_at", now).Error
+}
+
+// LockDue locks every challenge whose deadline has passed and returns how many were locked.
+func (s *ChallengeService) LockDue(now time.Time) (int, error) {
+	var due []models.Challenge
+	if err := s.DB.Where("ends_at <= ? AND locked_at IS NULL", now).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if err := s.DB.Transaction(func(tx *gorm.DB) error { return lockChallenge(tx, &due[i], now) }); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// RunLocker checks for passed deadlines on an interval. It never returns.
+func (s *ChallengeService) RunLocker(every time.Duration) {
+	for {
+		if n, err := s.LockDue(time.Now()); err != nil {
+			log.Printf("[challenges] lock failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[challenges] locked entries for %d challenge(s)", n)
+		}
+		time.Sleep(every)
+	}
+}
+
+// AnnounceWinners replaces the placements of a closed challenge and publishes them.
+func (s *ChallengeService) AnnounceWinners(challengeID uint, placements []Placement) (*models.Challenge, error) {
+	if len(placements) == 0 {
+		return nil, errors.New("at least one placement required")
+	}
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var ch models.Challenge
+		if err := tx.First(&ch, challengeID).Error; err != nil {
+			return err
+		}
+		if now.Before(ch.EndsAt) {
+			return ErrChallengeNotClosed
+		}
+		if ch.LockedAt == nil {
+			if err := lockChallenge(tx, &ch, now); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.ChallengeEntry{}).Where("challenge_id = ?", ch.ID).Update("placement", nil).Error; err != nil {
+			return err
+		}
+		for _, p := range placements {
+			if p.Placement < 1 {
+				return errors.New("placement must be 1 or greater")
+			}
+			res := tx.Model(&models.ChallengeEntry{}).Where("id = ? AND challenge_id = ?", p.EntryID, ch.ID).Update("placement", p.Placement)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("entry %d is not part of this challenge", p.EntryID)
+			}
+		}
+		return tx.Model(&ch).Update("announced_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(strconv.FormatUint(uint64(challengeID), 10))
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+var (
+	ErrNotCommentAuthor = errors.New("only the author can change this comment")
+	ErrCommentGone      = errors.New("comment was deleted")
+)
+
+const maxCommentLength = 2000
+
+// CommentMailer delivers comment notifications; EmailService implements it.
+type CommentMailer interface {
+	SendCommentNotification(to, name string, n CommentNotification) error
+}
+
+// CommentNotification tells a project owner (or the author of the comment replied to) about
+// a new comment.
+type CommentNotification struct {
+	ProjectTitle     string
+	CommenterName    string
+	Body             string
+	TimestampSeconds *float64
+	Reply            bool // to the recipient's own comment
+	URL              string
+	Locale           string // the recipient's
+}
+
+// CommentService handles threaded, optionally timestamped comments on public projects.
+type CommentService struct {
+	DB *gorm.DB
+	// Mailer, when set, notifies project owners and the people replied to. Sending happens
+	// in the background and never fails the comment.
+	Mailer CommentMailer
+	// LinkBase is the frontend URL comment links in notifications start with.
+	LinkBase string
+}
+
+func NewCommentService(db *gorm.DB) *CommentService { return &CommentService{DB: db} }
+
+// CommentAuthor is the public part of a commenter's profile.
+type CommentAuthor struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	Picture     string `json:"picture,omitempty"`
+}
+
+// CommentView is a comment as shown to readers. Deleted and removed comments keep their
+// place in a thread but lose their body and author.
+type CommentView struct {
+	models.Comment
+	Author  *CommentAuthor `json:"author,omitempty"`
+	Replies []CommentView  `json:"replies,omitempty"`
+}
+
+func commentView(c models.Comment) CommentView {
+	v := CommentView{Comment: c}
+	if c.DeletedAt != nil || c.RemovedAt != nil {
+		// Nothing may point back at the author, including the raw user id.
+		v.Body, v.TimestampSeconds, v.AudioFileID, v.UserID = "", nil, nil, 0
+		return v
+	}
+	v.Author = &CommentAuthor{ID: c.User.ID, Username: c.User.Username, DisplayName: c.User.DisplayName, Picture: c.User.Picture}
+	return v
+}
+
+type CommentInput struct {
+	Body     string `json:"body"`
+	ParentID *uint  `json:"parentId"` // reply to this comment
+	// TimestampSeconds pins the comment to a moment in the audio, on AudioFileID or, when
+	// that is not given, the project's latest upload.
+	TimestampSeconds *float64 `json:"timestampSeconds"`
+	AudioFileID      *uint    `json:"audioFileId"`
+}
+
+// CommentEdit changes a comment's text or timestamp; nil fields are left alone.
+type CommentEdit struct {
+	Body             *string  `json:"body"`
+	TimestampSeconds *float64 `json:"timestampSeconds"`
+}
+
+// CommentFilter pages through a project's threads.
+type CommentFilter struct {
+	CursorPage
+	Sort string `form:"sort"` // newest (default) | oldest
+}
+
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errors.New("body required")
+	}
+	if len([]rune(body)) > maxCommentLength {
+		return "", fmt.Errorf("body must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// findPublicProject loads a project that is public and belongs to a public profile.
+func findPublicProject(db *gorm.DB, projectID uint) (*models.Project, error) {
+	var p models.Project
+	err := db.Joins("JOIN users u ON u.id = projects.user_id").
+		Where("projects.id = ? AND projects.public = ? AND u.public = ?", projectID, true, true).First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checkTimestamp validates a timestamp against the audio it refers to and returns that
+// audio's id, if the project has any.
+func checkTimestamp(db *gorm.DB, projectID uint, at float64, audioFileID *uint) (*uint, error) {
+	if at < 0 {
+		return nil, errors.New("timestampSeconds must not be negative")
+	}
+	var af models.AudioFile
+	q := db.Where("project_id = ?", projectID)
+	if audioFileID != nil {
+		q = q.Where("id = ?", *audioFileID)
+	}
+	err := q.Order("created_at desc").First(&af).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound) && audioFileID != nil:
+		return nil, errors.New("audio file does not belong to project")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	// Durations are filled in by analysis; until then any timestamp is accepted.
+	if af.DurationSeconds > 0 && at > af.DurationSeconds {
+		return nil, fmt.Errorf("timestampSeconds is past the end of the audio (%s)", formatTimestamp(af.DurationSeconds))
+	}
+	return &af.ID, nil
+}
+
+// Create comments on a public project, or replies to one of its comments. Replies to a
+// reply join the same thread.
+func (s *CommentService) Create(userID, projectID uint, in CommentInput) (*CommentView, error) {
+	body, err := normalizeCommentBody(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	p, err := findPublicProject(s.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+	c := models.Comment{ProjectID: p.ID, UserID: userID, Body: body}
+	var parent *models.Comment
+	if in.ParentID != nil {
+		parent = &models.Comment{}
+		if err := s.DB.Where("id = ? AND project_id = ?", *in.ParentID, p.ID).First(parent).Error; err != nil {
+			return nil, err
+		}
+		if parent.DeletedAt != nil || parent.RemovedAt != nil {
+			return nil, ErrCommentGone
+		}
+		root := parent.ID
+		if parent.ParentID != nil {
+			root = *parent.ParentID
+		}
+		c.ParentID = &root
+	}
+	if in.AudioFileID != nil && in.TimestampSeconds == nil {
+		return nil, errors.New("audioFileId needs timestampSeconds")
+	}
+	if in.TimestampSeconds != nil {
+		at := *in.TimestampSeconds
+		if c.AudioFileID, err = checkTimestamp(s.DB, p.ID, at, in.AudioFileID); err != nil {
+			return nil, err
+		}
+		c.TimestampSeconds = &at
+	}
+	if err := s.DB.Create(&c).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.Preload("User").First(&c, c.ID).Error; err != nil {
+		return nil, err
+	}
+	if s.Mailer != nil {
+		go s.notify(p, parent, c)
+	}
+	v := commentView(c)
+	return &v, nil
+}
+
+// notify mails the project owner and, for replies, the author of the comment replied to.
+// Nobody is told about their own comment.
+func (s *CommentService) notify(p *models.Project, parent *models.Comment, c models.Comment) {
+	n := CommentNotification{
+		ProjectTitle:     p.Title,
+		CommenterName:    displayNameOf(&c.User),
+		Body:             c.Body,
+		TimestampSeconds: c.TimestampSeconds,
+		URL:              fmt.Sprintf("%s/projects/%d#comment-%d", s.LinkBase, p.ID, c.ID),
+	}
+	sent := map[uint]bool{c.UserID: true}
+	send := func(userID uint, reply bool) {
+		if sent[userID] {
+			return
+		}
+		sent[userID] = true
+		var u models.User
+		if err := s.DB.First(&u, userID).Error; err != nil || u.Email == "" {
+			return
+		}
+		n.Reply = reply
+		n.Locale = u.Locale
+		if err := s.Mailer.SendCommentNotification(u.Email, displayNameOf(&u), n); err != nil {
+			log.Printf("[comments] notifying user %d about comment %d: %v", userID, c.ID, err)
+		}
+	}
+	if parent != nil {
+		send(parent.UserID, true)
+	}
+	send(p.UserID, false)
+}
+
+func displayNameOf(u *models.User) string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// findOwnComment loads a live comment for its author to change.
+func (s *CommentService) findOwnComment(userID, commentID uint) (*models.Comment, error) {
+	var c models.Comment
+	if err := s.DB.First(&c, commentID).Error; err != nil {
+		return nil, err
+	}
+	if c.DeletedAt != nil || c.RemovedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if c.UserID != userID {
+		return nil, ErrNotCommentAuthor
+	}
+	return &c, nil
+}
+
+// Update lets the author edit the text or move the timestamp of their comment.
+func (s *CommentService) Update(userID, commentID uint, in CommentEdit) (*CommentView, error) {
+	c, err := s.findOwnComment(userID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if in.Body != nil {
+		body, err := normalizeCommentBody(*in.Body)
+		if err != nil {
+			return nil, err
+		}
+		updates["body"] = body
+	}
+	if in.TimestampSeconds != nil {
+		audioID, err := checkTimestamp(s.DB, c.ProjectID, *in.TimestampSeconds, c.AudioFileID)
+		if err != nil {
+			return nil, err
+		}
+		updates["timestamp_seconds"] = *in.TimestampSeconds
+		updates["audio_file_id"] = audioID
+	}
+	if len(updates) > 0 {
+		updates["edited_at"] = time.Now()
+		if err := s.DB.Model(c).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	var out models.Comment
+	if err := s.DB.Preload("User").First(&out, c.ID).Error; err != nil {
+		return nil, err
+	}
+	v := commentView(out)
+	return &v, nil
+}
+
+// Delete lets the author take back their comment.
+func (s *CommentService) Delete(userID, commentID uint) error {
+	c, err := s.findOwnComment(userID, commentID)
+	if err != nil {
+		return err
+	}
+	return s.DB.Model(c).Update("deleted_at", time.Now()).Error
+}
+
+// Remove hides a comment on behalf of a moderator, keeping who did it and why.
+func (s *CommentService) Remove(moderatorID, commentID uint, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 200 {
+		return errors.New("reason is too long")
+	}
+	res := s.DB.Model(&models.Comment{}).Where("id = ? AND removed_at IS NULL", commentID).
+		Updates(map[string]interface{}{"removed_at": time.Now(), "removed_by_id": moderatorID, "removed_reason": reason})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List pages through the threads on a public project, each with all its live replies in
+// order. Deleted or removed comments only show, as placeholders, while they have replies.
+func (s *CommentService) List(projectID uint, f CommentFilter) (*Page[CommentView], error) {
+	if _, err := findPublicProject(s.DB, projectID); err != nil {
+		return nil, err
+	}
+	k := keyset[models.Comment]{Column: "comments.created_at", IDColumn: "comments.id", id: func(c *models.Comment) uint { return c.ID }}
+	k.value, k.parse = timeKey(func(c *models.Comment) time.Time { return c.CreatedAt })
+	switch f.Sort {
+	case "", "newest":
+		k.Name, k.Desc = "newest", true
+	case "oldest":
+		k.Name = "oldest"
+	default:
+		return nil, errors.New("sort must be newest or oldest")
+	}
+	q := s.DB.Model(&models.Comment{}).Preload("User").
+		Where("comments.project_id = ? AND comments.parent_id IS NULL", projectID).
+		Where("((comments.deleted_at IS NULL AND comments.removed_at IS NULL) OR EXISTS (" +
+			"SELECT 1 FROM comments r WHERE r.parent_id = comments.id AND r.deleted_at IS NULL AND r.removed_at IS NULL))")
+	page, err := paginate(q, f.CursorPage, k)
+	if err != nil {
+		return nil, err
+	}
+	out := &Page[CommentView]{Items: make([]CommentView, len(page.Items)), NextCursor: page.NextCursor}
+	if len(page.Items) == 0 {
+		return out, nil
+	}
+	ids := make([]uint, len(page.Items))
+	index := map[uint]int{}
+	for i, c := range page.Items {
+		out.Items[i] = commentView(c)
+		ids[i] = c.ID
+		index[c.ID] = i
+	}
+	var replies []models.Comment
+	err = s.DB.Preload("User").Where("parent_id IN ? AND deleted_at IS NULL AND removed_at IS NULL", ids).
+		Order("created_at asc, id asc").Find(&replies).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replies {
+		i := index[*r.ParentID]
+		out.Items[i].Replies = append(out.Items[i].Replies, commentView(r))
+	}
+	return out, nil
+}
+
+// formatTimestamp renders seconds as m:ss, or h:mm:ss past an hour.
+func formatTimestamp(sec float64) string {
+	t := int(sec)
+	if t >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", t/3600, t/60%60, t%60)
+	}
+	return fmt.Sprintf("%d:%02d", t/60, t%60)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+// Polling outcomes, named after the OAuth 2.0 device grant (RFC 8628) error codes.
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+	ErrInvalidDeviceCode    = errors.New("invalid_grant")
+)
+
+const (
+	// No vowels, so codes never spell words, and no digits to mistake for letters.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 // seconds
+)
+
+type DeviceAuthService struct {
+	DB *gorm.DB
+	// VerificationURL is the web app page where users enter the code.
+	VerificationURL string
+}
+
+func NewDeviceAuthService(db *gorm.DB, verificationURL string) *DeviceAuthService {
+	return &DeviceAuthService{DB: db, VerificationURL: verificationURL}
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationUri"`
+	VerificationURIComplete string `json:"verificationUriComplete"`
+	ExpiresIn               int    `json:"expiresIn"` // seconds
+	Interval                int    `json:"interval"`  // minimum seconds between polls
+}
+
+// DeviceCredential is handed to the device once the user approves.
+type DeviceCredential struct {
+	APIKey string         `json:"apiKey"`
+	Key    *models.APIKey `json:"key"`
+}
+
+// NormalizeUserCode uppercases a typed code and restores its dash, ignoring spacing.
+func NormalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	s := b.String()
+	if len(s) != userCodeLength {
+		return s
+	}
+	return s[:4] + "-" + s[4:]
+}
+
+// Start begins a device login for clientName (e.g. "UploadParty VST on Studio-PC").
+func (s *DeviceAuthService) Start(clientName, ip string) (*DeviceCodeResponse, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	deviceCode := base64.RawURLEncoding.EncodeToString(raw)
+	clientName = strings.TrimSpace(clientName)
+	if clientName == "" {
+		clientName = "UploadParty plugin"
+	}
+	// User codes are short, so retry the rare collision with a live code.
+	for attempt := 0; ; attempt++ {
+		code, err := randomString(userCodeAlphabet, userCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		da := models.DeviceAuthorization{
+			DeviceCodeHash:  sha256Hex(deviceCode),
+			UserCode:        NormalizeUserCode(code),
+			ClientName:      truncate(clientName, 100),
+			Status:          models.DeviceAuthPending,
+			IPAddress:       truncate(ip, 45),
+			IntervalSeconds: devicePollInterval,
+			ExpiresAt:       time.Now().Add(deviceCodeTTL),
+		}
+		err = s.DB.Create(&da).Error
+		if err == nil {
+			return &DeviceCodeResponse{
+				DeviceCode:              deviceCode,
+				UserCode:                da.UserCode,
+				VerificationURI:         s.VerificationURL,
+				VerificationURIComplete: s.VerificationURL + "?code=" + da.UserCode,
+				ExpiresIn:               int(deviceCodeTTL / time.Second),
+				Interval:                da.IntervalSeconds,
+			}, nil
+		}
+		if attempt >= 3 {
+			return nil, err
+		}
+	}
+}
+
+// Lookup finds a pending, unexpired request by the code the user typed.
+func (s *DeviceAuthService) Lookup(userCode string) (*models.DeviceAuthorization, error) {
+	var da models.DeviceAuthorization
+	err := s.DB.Where("user_code = ? AND status = ? AND expires_at > ?", NormalizeUserCode(userCode), models.DeviceAuthPending, time.Now()).First(&da).Error
+	if err != nil {
+		return nil, err
+	}
+	return &da, nil
+}
+
+// Decide approves or denies a pending request on behalf of userID.
+func (s *DeviceAuthService) Decide(userID uint, userCode string, approve bool) (*models.DeviceAuthorization, error) {
+	status := models.DeviceAuthDenied
+	if approve {
+		status = models.DeviceAuthApproved
+	}
+	// Approving only to fail every poll later helps nobody; ask for a key to be revoked first.
+	if approve {
+		if err := checkAPIKeyLimit(s.DB, userID); err != nil {
+			return nil, err
+		}
+	}
+	code := NormalizeUserCode(userCode)
+	res := s.DB.Model(&models.DeviceAuthorization{}).
+		Where("user_code = ? AND status = ? AND expires_at > ?", code, models.DeviceAuthPending, time.Now()).
+		Updates(map[string]interface{}{"status": status, "user_id": userID})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var da models.DeviceAuthorization
+	if err := s.DB.Where("user_code = ?", code).First(&da).Error; err != nil {
+		return nil, err
+	}
+	return &da, nil
+}
+
+// Poll answers the device. Once approved, the first poll creates an ingest API key for the
+// approving user and returns it; the device code is then spent.
+func (s *DeviceAuthService) Poll(deviceCode string) (*DeviceCredential, error) {
+	now := time.Now()
+	var da models.DeviceAuthorization
+	if err := s.DB.Where("device_code_hash = ?", sha256Hex(deviceCode)).First(&da).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidDeviceCode
+		}
+		return nil, err
+	}
+	if now.After(da.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+	switch da.Status {
+	case models.DeviceAuthDenied:
+		return nil, ErrAccessDenied
+	case models.DeviceAuthPending:
+		return nil, s.recordPoll(&da, now)
+	case models.DeviceAuthApproved:
+		return s.redeem(&da)
+	default:
+		return nil, ErrInvalidDeviceCode
+	}
+}
+
+// recordPoll stamps a pending poll. Polling faster than the interval backs the device off
+// by another interval, as RFC 8628 prescribes for slow_down.
+func (s *DeviceAuthService) recordPoll(da *models.DeviceAuthorization, now time.Time) error {
+	tooSoon := da.LastPolledAt != nil && now.Sub(*da.LastPolledAt) < time.Duration(da.IntervalSeconds)*time.Second
+	updates := map[string]interface{}{"last_polled_at": now}
+	if tooSoon {
+		updates["interval_seconds"] = da.IntervalSeconds + devicePollInterval
+	}
+	if err := s.DB.Model(da).Updates(updates).Error; err != nil {
+		return err
+	}
+	if tooSoon {
+		return ErrSlowDown
+	}
+	return ErrAuthorizationPending
+}
+
+func (s *DeviceAuthService) redeem(da *models.DeviceAuthorization) (*DeviceCredential, error) {
+	var cred *DeviceCredential
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Spend the code first so concurrent polls cannot mint two keys.
+		res := tx.Model(&models.DeviceAuthorization{}).Where("id = ? AND status = ?", da.ID, models.DeviceAuthApproved).
+			Update("status", models.DeviceAuthConsumed)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 || da.UserID == nil {
+			return ErrInvalidDeviceCode
+		}
+		key, plain, err := NewAPIKeyService(tx).Create(*da.UserID, CreateAPIKeyInput{Name: da.ClientName})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.DeviceAuthorization{}).Where("id = ?", da.ID).Update("api_key_id", key.ID).Error; err != nil {
+			return err
+		}
+		cred = &DeviceCredential{APIKey: plain, Key: key}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// Cleanup deletes device requests a day after they expire. It never returns.
+func (s *DeviceAuthService) Cleanup(every time.Duration) {
+	for {
+		if err := s.DB.Where("expires_at < ?", time.Now().Add(-24*time.Hour)).Delete(&models.DeviceAuthorization{}).Error; err != nil {
+			log.Printf("[device] cleanup failed: %v", err)
+		}
+		time.Sleep(every)
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"log"
 
 	"github.com/uploadparty/app/config"
+	"github.com/uploadparty/app/internal/emails"
 	"github.com/wneessen/go-mail"
 )
 
 type EmailService struct {
 	config *config.Config
 	client *mail.Client
+	// Templates renders the emails; NewEmailService reads overrides from EMAIL_TEMPLATES_DIR.
+	Templates *emails.Renderer
 }
 
 type EmailData struct {
@@ -23,7 +26,7 @@ type EmailData struct {
 func NewEmailService(cfg *config.Config) (*EmailService, error) {
 	if cfg.SMTPUsername == "" || cfg.SMTPPassword == "" {
 		log.Println("[EMAIL] SMTP credentials not configured, email service disabled")
-		return &EmailService{config: cfg}, nil
+		return &EmailService{config: cfg, Templates: emails.New(cfg.EmailTemplatesDir)}, nil
 	}
 
 	client, err := mail.NewClient(cfg.SMTPHost,
@@ -38,8 +41,9 @@ func NewEmailService(cfg *config.Config) (*EmailService, error) {
 	}
 
 	return &EmailService{
-		config: cfg,
-		client: client,
+		config:    cfg,
+		client:    client,
+		Templates: emails.New(cfg.EmailTemplatesDir),
 	}, nil
 }
 
@@ -81,97 +85,46 @@ func (e *EmailService) SendEmail(data EmailData) error {
 	return nil
 }
 
-// Future-proof: Add template-based email methods
-func (e *EmailService) SendRSVPConfirmation(email string) error {
-	html := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>RSVP Confirmation</title>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: #4f46e5; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background: #f9f9f9; }
-        .footer { padding: 20px; text-align: center; color: #666; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Thanks for your RSVP! 🎉</h1>
-        </div>
-        <div class="content">
-            <p>Hi there!</p>
-            <p>We've received your RSVP and you're all set! We're excited to have you join us.</p>
-            <p>Keep an eye on your inbox for more updates and details about the event.</p>
-            <p>Can't wait to see you there!</p>
-        </div>
-        <div class="footer">
-            <p>Best regards,<br>The UploadParty Team</p>
-        </div>
-    </div>
-</body>
-</html>`)
-
-	return e.SendEmail(EmailData{
-		To:      email,
-		Subject: "RSVP Confirmed - Thanks for RSVPing",
-		HTML:    html,
-		Text:    "Thanks for your RSVP! We've received your confirmation and you're all set. Keep an eye on your inbox for more updates about the event, join or slack or discord which ever one is more comfortable.",
-	})
+// send renders a templated email in the recipient's locale and sends it.
+func (e *EmailService) send(to, name, locale string, data any) error {
+	msg, err := e.Templates.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+	return e.SendEmail(EmailData{To: to, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text})
+}
+
+// SendRSVPConfirmation confirms an RSVP; firstName may be empty.
+func (e *EmailService) SendRSVPConfirmation(email, firstName, locale string) error {
+	return e.send(email, emails.RSVPConfirmation, locale, emails.RSVPData{FirstName: firstName})
 }
 
 // SendReferralNotification sends an email to the referrer when someone uses their code
-func (e *EmailService) SendReferralNotification(referrerEmail, referrerName, newUserName string) error {
-	html := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>New Referral!</title>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: #10b981; color: white; padding: 20px; text-align: center; }
-        .content { padding: 20px; background: #f9f9f9; }
-        .highlight { background: #d1fae5; padding: 10px; border-radius: 5px; margin: 15px 0; }
-        .footer { padding: 20px; text-align: center; color: #666; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Great news, %s! 🎉</h1>
-        </div>
-        <div class="content">
-            <p>Someone just used your referral code to RSVP!</p>
-            <div class="highlight">
-                <strong>%s</strong> has signed up using your referral link.
-            </div>
-            <p>Thank you for spreading the word about UploadParty! Every referral helps us grow our community.</p>
-            <p>Keep sharing your referral code to invite more people!</p>
-        </div>
-        <div class="footer">
-            <p>Best regards,<br>The UploadParty Team</p>
-        </div>
-    </div>
-</body>
-</html>`, referrerName, newUserName)
-
-	plainText := fmt.Sprintf("Great news, %s! %s just used your referral code to RSVP. Thank you for spreading the word about UploadParty!", referrerName, newUserName)
-
-	return e.SendEmail(EmailData{
-		To:      referrerEmail,
-		Subject: "Someone used your referral code! 🎉",
-		HTML:    html,
-		Text:    plainText,
+func (e *EmailService) SendReferralNotification(referrerEmail, referrerName, newUserName, locale string) error {
+	return e.send(referrerEmail, emails.ReferralNotification, locale, emails.ReferralData{
+		ReferrerName: referrerName,
+		NewUserName:  newUserName,
 	})
 }
 
-// Future method:
-func (e *EmailService) SendWelcomeEmail(email, name string) error {
-	// Implementation for welcome emails
-	return nil
+// SendCommentNotification tells a producer about a new comment on their project, or a reply
+// to their comment.
+func (e *EmailService) SendCommentNotification(to, name string, n CommentNotification) error {
+	data := emails.CommentData{
+		Name:          name,
+		CommenterName: n.CommenterName,
+		ProjectTitle:  n.ProjectTitle,
+		Reply:         n.Reply,
+		Body:          n.Body,
+		URL:           n.URL,
+	}
+	if n.TimestampSeconds != nil {
+		data.At = formatTimestamp(*n.TimestampSeconds)
+	}
+	return e.send(to, emails.CommentNotification, n.Locale, data)
+}
+
+// SendWelcomeEmail greets a new user; UserService sends it when an account is created.
+func (e *EmailService) SendWelcomeEmail(email, name, locale string) error {
+	return e.send(email, emails.Welcome, locale, emails.WelcomeData{Name: name, URL: e.config.FrontendURL})
 }
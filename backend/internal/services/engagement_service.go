@@ -0,0 +1,230 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+var errBatchTaken = errors.New("counter deltas were aggregated concurrently")
+
+const (
+	defaultEngagementWindow = 30 * time.Minute
+	aggregateBatchSize      = 5000
+)
+
+// engagementCounters maps each kind to the counter column its deltas are folded into.
+var engagementCounters = map[models.EngagementKind]struct {
+	model  interface{}
+	column string
+}{
+	models.EngagementPlay:        {&models.Project{}, "play_count"},
+	models.EngagementLike:        {&models.Project{}, "like_count"},
+	models.EngagementProfileView: {&models.User{}, "profile_views"},
+}
+
+// EngagementService records likes, plays and profile views. Plays and views count once per
+// viewer per Window; every change is queued as a delta and folded into the counter columns
+// by Aggregate, so listings read plain columns and popular rows are not written on every hit.
+type EngagementService struct {
+	DB     *gorm.DB
+	Window time.Duration
+	// Salt is mixed into hashed IPs, so the stored keys cannot be matched to addresses.
+	Salt string
+}
+
+func NewEngagementService(db *gorm.DB) *EngagementService {
+	return &EngagementService{DB: db, Window: defaultEngagementWindow}
+}
+
+// Viewer identifies who played or viewed something: a signed-in user, or else their IP.
+type Viewer struct {
+	UserID uint
+	IP     string
+}
+
+func (s *EngagementService) viewerKey(v Viewer) string {
+	if v.UserID != 0 {
+		return "u:" + strconv.FormatUint(uint64(v.UserID), 10)
+	}
+	sum := sha256.Sum256([]byte(s.Salt + "\x00" + v.IP))
+	return "ip:" + hex.EncodeToString(sum[:16])
+}
+
+// LikeState is what like endpoints return.
+type LikeState struct {
+	Liked     bool  `json:"liked"`
+	LikeCount int64 `json:"likeCount"`
+}
+
+func (s *EngagementService) likeState(userID, projectID uint) (*LikeState, error) {
+	var st LikeState
+	if err := s.DB.Model(&models.ProjectLike{}).Where("project_id = ?", projectID).Count(&st.LikeCount).Error; err != nil {
+		return nil, err
+	}
+	var n int64
+	err := s.DB.Model(&models.ProjectLike{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&n).Error
+	st.Liked = n > 0
+	return &st, err
+}
+
+// LikeState reports whether userID likes a public project, with its current like count.
+func (s *EngagementService) LikeState(userID, projectID uint) (*LikeState, error) {
+	if _, err := findPublicProject(s.DB, projectID); err != nil {
+		return nil, err
+	}
+	return s.likeState(userID, projectID)
+}
+
+// Like likes a public project; liking it again changes nothing.
+func (s *EngagementService) Like(userID, projectID uint) (*LikeState, error) {
+	if _, err := findPublicProject(s.DB, projectID); err != nil {
+		return nil, err
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProjectLike{ProjectID: projectID, UserID: userID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Create(&models.CounterDelta{Kind: models.EngagementLike, TargetID: projectID, Delta: 1}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.likeState(userID, projectID)
+}
+
+// Unlike takes a like back. The project may have gone private since.
+func (s *EngagementService) Unlike(userID, projectID uint) (*LikeState, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectLike{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Create(&models.CounterDelta{Kind: models.EngagementLike, TargetID: projectID, Delta: -1}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.likeState(userID, projectID)
+}
+
+// RecordPlay counts a play of a public project, unless the viewer already played it in the
+// current window or is its owner. It reports whether the play counted.
+func (s *EngagementService) RecordPlay(projectID uint, v Viewer, now time.Time) (bool, error) {
+	p, err := findPublicProject(s.DB, projectID)
+	if err != nil {
+		return false, err
+	}
+	if v.UserID == p.UserID {
+		return false, nil
+	}
+	return s.record(models.EngagementPlay, projectID, v, now)
+}
+
+// RecordProfileView counts a view of a public profile by anyone but its owner.
+func (s *EngagementService) RecordProfileView(userID uint, v Viewer, now time.Time) (bool, error) {
+	if v.UserID == userID {
+		return false, nil
+	}
+	return s.record(models.EngagementProfileView, userID, v, now)
+}
+
+func (s *EngagementService) record(kind models.EngagementKind, targetID uint, v Viewer, now time.Time) (bool, error) {
+	hit := models.EngagementHit{CreatedAt: now, Kind: kind, TargetID: targetID, ViewerKey: s.viewerKey(v),
+		Bucket: now.Unix() / int64(s.Window/time.Second)}
+	counted := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&hit)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		counted = true
+		return tx.Create(&models.CounterDelta{Kind: kind, TargetID: targetID, Delta: 1}).Error
+	})
+	return counted, err
+}
+
+// Aggregate folds pending deltas into the counter columns and returns how many it applied.
+// Several instances may run it at once: a batch another instance already took is rolled back.
+func (s *EngagementService) Aggregate() (int, error) {
+	var deltas []models.CounterDelta
+	if err := s.DB.Order("id").Limit(aggregateBatchSize).Find(&deltas).Error; err != nil {
+		return 0, err
+	}
+	if len(deltas) == 0 {
+		return 0, nil
+	}
+	type target struct {
+		kind models.EngagementKind
+		id   uint
+	}
+	sums := map[target]int{}
+	ids := make([]uint, len(deltas))
+	for i, d := range deltas {
+		sums[target{d.Kind, d.TargetID}] += d.Delta
+		ids[i] = d.ID
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id IN ?", ids).Delete(&models.CounterDelta{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return errBatchTaken
+		}
+		for t, sum := range sums {
+			c, ok := engagementCounters[t.kind]
+			if !ok || sum == 0 {
+				continue
+			}
+			// UpdateColumn leaves updated_at alone: a play is not an edit. Trashed projects keep counting.
+			err := tx.Unscoped().Model(c.model).Where("id = ?", t.id).
+				UpdateColumn(c.column, gorm.Expr(c.column+" + ?", sum)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchTaken) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(deltas), nil
+}
+
+// Cleanup forgets hits from windows that have ended; they can no longer cause duplicates.
+func (s *EngagementService) Cleanup(now time.Time) error {
+	return s.DB.Where("created_at < ?", now.Add(-2*s.Window)).Delete(&models.EngagementHit{}).Error
+}
+
+// RunAggregator aggregates deltas and cleans up old hits on an interval. It never returns.
+func (s *EngagementService) RunAggregator(every time.Duration) {
+	for {
+		time.Sleep(every)
+		for {
+			n, err := s.Aggregate()
+			if err != nil {
+				log.Printf("[engagement] aggregate failed: %v", err)
+				break
+			}
+			if n < aggregateBatchSize {
+				break
+			}
+		}
+		if err := s.Cleanup(time.Now()); err != nil {
+			log.Printf("[engagement] cleanup failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+var ErrFollowSelf = errors.New("you cannot follow yourself")
+
+// FollowService manages who follows whom. Only public profiles can be followed, and the
+// lists only show public users.
+type FollowService struct {
+	DB *gorm.DB
+}
+
+func NewFollowService(db *gorm.DB) *FollowService { return &FollowService{DB: db} }
+
+// FollowedUser is one row of a follower or following list.
+type FollowedUser struct {
+	FollowID    uint      `json:"-"`
+	FollowedAt  time.Time `json:"followedAt"`
+	UserID      uint      `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName"`
+	Picture     string    `json:"picture,omitempty"`
+}
+
+// FollowCounts is shown on public profiles.
+type FollowCounts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// Follow makes followerID follow followeeID. Following someone twice is not an error.
+func (s *FollowService) Follow(followerID, followeeID uint) error {
+	if followerID == followeeID {
+		return ErrFollowSelf
+	}
+	var n int64
+	if err := s.DB.Model(&models.User{}).Where("id = ? AND public = ?", followeeID, true).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Follow{FollowerID: followerID, FolloweeID: followeeID}).Error
+}
+
+// Unfollow stops following; it is not an error if followerID was not following.
+func (s *FollowService) Unfollow(followerID, followeeID uint) error {
+	return s.DB.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.Follow{}).Error
+}
+
+func (s *FollowService) IsFollowing(followerID, followeeID uint) (bool, error) {
+	var n int64
+	err := s.DB.Model(&models.Follow{}).Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Count(&n).Error
+	return n > 0, err
+}
+
+func (s *FollowService) Counts(userID uint) (FollowCounts, error) {
+	var c FollowCounts
+	if err := s.followList("f.follower_id", "f.followee_id", userID).Count(&c.Followers).Error; err != nil {
+		return c, err
+	}
+	err := s.followList("f.followee_id", "f.follower_id", userID).Count(&c.Following).Error
+	return c, err
+}
+
+// Followers pages through the public users following userID, most recent first.
+func (s *FollowService) Followers(userID uint, page CursorPage) (*Page[FollowedUser], error) {
+	return paginate(s.followList("f.follower_id", "f.followee_id", userID).Select(followedUserColumns), page, followKeyset())
+}
+
+// Following pages through the public users userID follows, most recent first.
+func (s *FollowService) Following(userID uint, page CursorPage) (*Page[FollowedUser], error) {
+	return paginate(s.followList("f.followee_id", "f.follower_id", userID).Select(followedUserColumns), page, followKeyset())
+}
+
+// followList selects the users in column other of the follows where column self is userID.
+func (s *FollowService) followList(other, self string, userID uint) *gorm.DB {
+	return s.DB.Table("follows AS f").Joins("JOIN users u ON u.id = "+other).
+		Where(self+" = ? AND u.public = ?", userID, true)
+}
+
+const followedUserColumns = "f.id AS follow_id, f.created_at AS followed_at, u.id AS user_id, u.username, u.display_name, u.picture"
+
+// followKeyset orders follow lists newest first.
+func followKeyset() keyset[FollowedUser] {
+	v, p := timeKey(func(f *FollowedUser) time.Time { return f.FollowedAt })
+	return keyset[FollowedUser]{Name: "followed", Column: "f.created_at", IDColumn: "f.id", Desc: true,
+		value: v, parse: p, id: func(f *FollowedUser) uint { return f.FollowID }}
+}
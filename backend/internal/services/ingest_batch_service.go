@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const (
+	BatchProject  = "project"
+	BatchPlugin   = "plugin"
+	BatchComplete = "complete"
+
+	MaxBatchItems = 500
+	// ingestReceiptTTL bounds how long a plugin may hold an item and still have a retry deduplicated.
+	ingestReceiptTTL = 30 * 24 * time.Hour
+	maxIngestKeyLen  = 100
+)
+
+const (
+	BatchApplied   = "applied"
+	BatchDuplicate = "duplicate"
+	BatchFailed    = "failed"
+)
+
+// IngestBatchService applies the queue a plugin buffered while offline.
+type IngestBatchService struct {
+	DB       *gorm.DB
+	Projects *ProjectService
+}
+
+func NewIngestBatchService(db *gorm.DB) *IngestBatchService {
+	return &IngestBatchService{DB: db, Projects: NewProjectService(db)}
+}
+
+// BatchItem is one queued ingest call. Plugin and complete items name their project by id or,
+// for projects created while offline, by UUID or title.
+type BatchItem struct {
+	Type         string              `json:"type"`
+	Key          string              `json:"key"` // client idempotency key, unique per user
+	At           *time.Time          `json:"at"`  // when it happened on the client
+	ProjectID    uint                `json:"projectId"`
+	ProjectUUID  string              `json:"projectUuid"`
+	ProjectTitle string              `json:"projectTitle"`
+	Project      *UpsertProjectInput `json:"project"`
+	Plugin       *UpsertPluginInput  `json:"plugin"`
+}
+
+// Scope is the API key scope an item needs.
+func (it BatchItem) Scope() string {
+	if it.Type == BatchPlugin {
+		return models.ScopePluginsWrite
+	}
+	return models.ScopeProjectsWrite
+}
+
+type BatchItemResult struct {
+	Index     int    `json:"index"`
+	Key       string `json:"key,omitempty"`
+	Status    string `json:"status"` // applied | duplicate | failed
+	Error     string `json:"error,omitempty"`
+	ProjectID uint   `json:"projectId,omitempty"`
+	PluginID  uint   `json:"pluginId,omitempty"`
+}
+
+type BatchResult struct {
+	Results    []BatchItemResult `json:"results"`
+	Applied    int               `json:"applied"`
+	Duplicates int               `json:"duplicates"`
+	Failed     int               `json:"failed"`
+}
+
+// Apply runs the items in order inside one transaction. Each item gets a savepoint, so a bad
+// item is rolled back and reported on its own while the rest commit together. Items whose key
+// was seen before are skipped and answered with what they produced the first time.
+func (s *IngestBatchService) Apply(userID uint, items []BatchItem) (*BatchResult, error) {
+	if len(items) == 0 {
+		return nil, errors.New("items required")
+	}
+	if len(items) > MaxBatchItems {
+		return nil, fmt.Errorf("at most %d items per batch", MaxBatchItems)
+	}
+	res := &BatchResult{Results: make([]BatchItemResult, len(items))}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i, it := range items {
+			r := BatchItemResult{Index: i, Key: it.Key}
+			if len(it.Key) > maxIngestKeyLen {
+				r.Status, r.Error = BatchFailed, fmt.Sprintf("key longer than %d characters", maxIngestKeyLen)
+				res.Results[i] = r
+				continue
+			}
+			if it.Key != "" {
+				var seen models.IngestReceipt
+				err := tx.Where("user_id = ? AND key = ?", userID, it.Key).First(&seen).Error
+				if err == nil {
+					r.Status, r.ProjectID, r.PluginID = BatchDuplicate, seen.ProjectID, seen.PluginID
+					res.Results[i] = r
+					continue
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			}
+			err := tx.Transaction(func(item *gorm.DB) error {
+				projectID, pluginID, err := s.applyItem(item, userID, it)
+				if err != nil {
+					return err
+				}
+				r.ProjectID, r.PluginID = projectID, pluginID
+				if it.Key == "" {
+					return nil
+				}
+				return item.Create(&models.IngestReceipt{UserID: userID, Key: it.Key, Type: it.Type, ProjectID: projectID, PluginID: pluginID}).Error
+			})
+			if err != nil {
+				r.Status, r.Error = BatchFailed, err.Error()
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					r.Error = "project not found"
+				}
+			} else {
+				r.Status = BatchApplied
+			}
+			res.Results[i] = r
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res.Results {
+		switch r.Status {
+		case BatchApplied:
+			res.Applied++
+		case BatchDuplicate:
+			res.Duplicates++
+		default:
+			res.Failed++
+		}
+	}
+	return res, nil
+}
+
+func (s *IngestBatchService) applyItem(tx *gorm.DB, userID uint, it BatchItem) (uint, uint, error) {
+	switch it.Type {
+	case BatchProject:
+		if it.Project == nil {
+			return 0, 0, errors.New("project required")
+		}
+		in := *it.Project
+		if in.At == nil {
+			in.At = it.At
+		}
+		p, err := s.Projects.upsert(tx, userID, in)
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.ID, 0, nil
+	case BatchPlugin:
+		if it.Plugin == nil || it.Plugin.Name == "" {
+			return 0, 0, errors.New("plugin name required")
+		}
+		p, err := batchProject(tx, userID, it)
+		if err != nil {
+			return 0, 0, err
+		}
+		pl, _, err := upsertPlugin(tx, p.ID, *it.Plugin)
+		if err != nil {
+			return 0, 0, err
+		}
+		return p.ID, pl.ID, nil
+	case BatchComplete:
+		p, err := batchProject(tx, userID, it)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := markComplete(tx, userID, p.ID, heartbeatTime(it.At, time.Now())); err != nil {
+			return 0, 0, err
+		}
+		return p.ID, 0, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown item type %q", it.Type)
+	}
+}
+
+// batchProject resolves the project an item refers to, by id, UUID or title.
+func batchProject(tx *gorm.DB, userID uint, it BatchItem) (*models.Project, error) {
+	switch {
+	case it.ProjectID != 0:
+		return findOwnedProject(tx, userID, it.ProjectID)
+	case it.ProjectUUID != "":
+		id, err := normalizeProjectUUID(it.ProjectUUID)
+		if err != nil {
+			return nil, err
+		}
+		return findProjectByUUID(tx, userID, id, "")
+	case it.ProjectTitle != "":
+		return findProjectByTitle(tx, userID, it.ProjectTitle)
+	default:
+		return nil, errors.New("projectId, projectUuid or projectTitle required")
+	}
+}
+
+// Cleanup forgets idempotency keys once they are too old to be retried. It never returns.
+func (s *IngestBatchService) Cleanup(every time.Duration) {
+	for {
+		if err := s.DB.Where("created_at < ?", time.Now().Add(-ingestReceiptTTL)).Delete(&models.IngestReceipt{}).Error; err != nil {
+			log.Printf("[ingest] receipt cleanup failed: %v", err)
+		}
+		time.Sleep(every)
+	}
+}
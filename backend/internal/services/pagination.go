@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by the same list and sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// Page is the envelope of cursor-paginated lists. NextCursor is empty on the last page;
+// otherwise clients pass it back as ?cursor= with the same filters to get the next one.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CursorPage is the ?limit=&cursor= part of a list request.
+type CursorPage struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+func (p CursorPage) size() int {
+	if p.Limit <= 0 {
+		return defaultPageSize
+	}
+	if p.Limit > maxPageSize {
+		return maxPageSize
+	}
+	return p.Limit
+}
+
+// pageCursor is the position after the last item of a page: its sort value and its id,
+// which breaks ties. Clients treat it as opaque.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// keyset is a sort order over one column, with the id as tie-breaker, that pages can resume
+// from without OFFSET.
+type keyset[T any] struct {
+	Name     string // the ?sort= value; cursors from another sort are rejected
+	Column   string
+	IDColumn string
+	Desc     bool
+	// value renders an item's sort value into the cursor; parse turns it back into a query arg.
+	value func(*T) string
+	parse func(string) (interface{}, error)
+	id    func(*T) uint
+}
+
+func timeKey[T any](get func(*T) time.Time) (func(*T) string, func(string) (interface{}, error)) {
+	return func(it *T) string { return get(it).Format(time.RFC3339Nano) },
+		func(s string) (interface{}, error) { return time.Parse(time.RFC3339Nano, s) }
+}
+
+func intKey[T any](get func(*T) int) (func(*T) string, func(string) (interface{}, error)) {
+	return func(it *T) string { return strconv.Itoa(get(it)) },
+		func(s string) (interface{}, error) { return strconv.Atoi(s) }
+}
+
+func stringKey[T any](get func(*T) string) (func(*T) string, func(string) (interface{}, error)) {
+	return get, func(s string) (interface{}, error) { return s, nil }
+}
+
+func (k keyset[T]) direction() string {
+	if k.Desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+// paginate loads one page of q in keyset order, starting after page.Cursor.
+func paginate[T any](q *gorm.DB, page CursorPage, k keyset[T]) (*Page[T], error) {
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != k.Name {
+			return nil, ErrInvalidCursor
+		}
+		v, err := k.parse(c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		op := ">"
+		if k.Desc {
+			op = "<"
+		}
+		q = q.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", k.Column, op, k.Column, k.IDColumn, op), v, v, c.ID)
+	}
+	size := page.size()
+	items := []T{}
+	err := q.Order(k.Column + " " + k.direction()).Order(k.IDColumn + " " + k.direction()).
+		Limit(size + 1).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	out := &Page[T]{Items: items}
+	if len(items) > size {
+		out.Items = items[:size]
+		last := &out.Items[size-1]
+		out.NextCursor = encodeCursor(pageCursor{Sort: k.Name, Value: k.value(last), ID: k.id(last)})
+	}
+	return out, nil
+}
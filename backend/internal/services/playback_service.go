@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// PlaybackService attaches short-lived playback URLs to audio records so clients
+// stream straight from storage instead of through the API.
+type PlaybackService struct {
+	Signer storage.URLSigner
+	TTL    time.Duration
+}
+
+func NewPlaybackService(signer storage.URLSigner, ttl time.Duration) *PlaybackService {
+	return &PlaybackService{Signer: signer, TTL: ttl}
+}
+
+// Sign fills URL and URLExpiresAt in place. Failures are logged and leave the URL empty.
+func (s *PlaybackService) Sign(ctx context.Context, files []models.AudioFile) {
+	if s == nil || s.Signer == nil {
+		return
+	}
+	expires := time.Now().Add(s.TTL)
+	for i := range files {
+		u, err := s.Signer.SignURL(ctx, files[i].StorageKey, s.TTL)
+		if err != nil {
+			log.Printf("[playback] sign audio %d: %v", files[i].ID, err)
+			continue
+		}
+		files[i].URL = u
+		files[i].URLExpiresAt = &expires
+	}
+}
+
+func (s *PlaybackService) SignProjects(ctx context.Context, ps []models.Project) {
+	for i := range ps {
+		s.Sign(ctx, ps[i].AudioFiles)
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const maxPluginStatsLimit = 100
+
+var (
+	// Build and format markers hosts append to plugin names: "Serum x64", "Pro-Q 3 (VST3)".
+	pluginNameNoise = regexp.MustCompile(`(?i)\b(x64|x86|x32|(?:64|32)[ -]?bit|vst[23]?|au|aax|stereo|mono)\b`)
+	emptyBrackets   = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
+	nonAlnum        = regexp.MustCompile(`[^a-z0-9]+`)
+	vendorSuffix    = regexp.MustCompile(`(?i)[,\s]+(inc|llc|ltd|gmbh|corp|co|s\.?a)\.?$`)
+)
+
+// knownVendors spells common vendors consistently, keyed by pluginKey of the raw vendor.
+var knownVendors = map[string]string{
+	"xfer":               "Xfer Records",
+	"xfer records":       "Xfer Records",
+	"fabfilter":          "FabFilter",
+	"native instruments": "Native Instruments",
+	"ni":                 "Native Instruments",
+	"valhalla":           "Valhalla DSP",
+	"valhalla dsp":       "Valhalla DSP",
+	"valhalladsp":        "Valhalla DSP",
+	"izotope":            "iZotope",
+	"waves":              "Waves",
+	"waves audio":        "Waves",
+	"u he":               "u-he",
+	"uhe":                "u-he",
+	"arturia":            "Arturia",
+	"spectrasonics":      "Spectrasonics",
+	"lennardigital":      "LennarDigital",
+	"image line":         "Image-Line",
+	"soundtoys":          "Soundtoys",
+}
+
+// PluginCatalogService resolves reported plugins to canonical catalog entries and reports
+// how widely each one is used.
+type PluginCatalogService struct{ DB *gorm.DB }
+
+func NewPluginCatalogService(db *gorm.DB) *PluginCatalogService {
+	return &PluginCatalogService{DB: db}
+}
+
+// pluginKey reduces a name to lowercase words without build markers or punctuation.
+func pluginKey(s string) string {
+	s = pluginNameNoise.ReplaceAllString(s, " ")
+	return strings.TrimSpace(nonAlnum.ReplaceAllString(strings.ToLower(s), " "))
+}
+
+// cleanPluginName keeps the reported spelling but drops build markers.
+func cleanPluginName(s string) string {
+	s = emptyBrackets.ReplaceAllString(pluginNameNoise.ReplaceAllString(s, ""), "")
+	return strings.Trim(strings.Join(strings.Fields(s), " "), " -_")
+}
+
+// NormalizeVendor trims company suffixes and applies the canonical spelling of known vendors.
+func NormalizeVendor(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	v = strings.TrimSpace(vendorSuffix.ReplaceAllString(v, ""))
+	if known, ok := knownVendors[pluginKey(v)]; ok {
+		return known
+	}
+	return v
+}
+
+// catalogKey is the alias key for a plugin: its name key, minus a leading vendor name
+// ("FabFilter Pro-Q 3" and "Pro-Q 3" are the same plugin).
+func catalogKey(name, vendor string) string {
+	key := pluginKey(name)
+	if v := pluginKey(NormalizeVendor(vendor)); v != "" && strings.HasPrefix(key, v+" ") {
+		key = strings.TrimPrefix(key, v+" ")
+	}
+	return key
+}
+
+// resolvePluginCatalog finds or creates the catalog entry for a reported plugin inside tx.
+// It returns nil for names that normalize to nothing.
+func resolvePluginCatalog(tx *gorm.DB, name, vendor string) (*models.PluginCatalog, error) {
+	key := catalogKey(name, vendor)
+	if key == "" {
+		return nil, nil
+	}
+	vendor = NormalizeVendor(vendor)
+	var alias models.PluginAlias
+	err := tx.Preload("Catalog").Where("key = ?", key).First(&alias).Error
+	if err == nil {
+		c := alias.Catalog
+		if c.Vendor == "" && vendor != "" {
+			if err := tx.Model(&c).Update("vendor", vendor).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &c, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	display := cleanPluginName(name)
+	if display == "" {
+		display = name
+	}
+	c := models.PluginCatalog{
+		Slug:    strings.ReplaceAll(key, " ", "-"),
+		Name:    truncate(display, 120),
+		Vendor:  truncate(vendor, 120),
+		Aliases: []models.PluginAlias{{Key: key}},
+	}
+	if err := tx.Create(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LinkUnlinked attaches catalog entries to plugins recorded before the catalog existed.
+func (s *PluginCatalogService) LinkUnlinked() {
+	linked := 0
+	var lastID uint
+	for {
+		var batch []models.Plugin
+		if err := s.DB.Where("catalog_id IS NULL AND id > ?", lastID).Order("id asc").Limit(200).Find(&batch).Error; err != nil {
+			log.Printf("[catalog] loading unlinked plugins failed: %v", err)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, pl := range batch {
+			lastID = pl.ID
+			err := s.DB.Transaction(func(tx *gorm.DB) error {
+				c, err := resolvePluginCatalog(tx, pl.Name, pl.Vendor)
+				if err != nil || c == nil {
+					return err
+				}
+				linked++
+				return tx.Model(&models.Plugin{}).Where("id = ?", pl.ID).Update("catalog_id", c.ID).Error
+			})
+			if err != nil {
+				log.Printf("[catalog] linking plugin %d failed: %v", pl.ID, err)
+			}
+		}
+	}
+	if linked > 0 {
+		log.Printf("[catalog] linked %d plugins", linked)
+	}
+}
+
+// PluginUsage is a catalog entry with how many projects and producers use it.
+type PluginUsage struct {
+	CatalogID uint   `json:"catalogId"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Vendor    string `json:"vendor"`
+	Projects  int64  `json:"projects"`
+	Producers int64  `json:"producers"`
+}
+
+// usageQuery counts active plugin rows per catalog entry over the projects selected by scope.
+func (s *PluginCatalogService) usageQuery(scope func(*gorm.DB) *gorm.DB) *gorm.DB {
+	q := s.DB.Table("plugin_catalogs AS c").
+		Select("c.id AS catalog_id, c.slug, c.name, c.vendor, COUNT(DISTINCT pl.project_id) AS projects, COUNT(DISTINCT p.user_id) AS producers").
+		Joins("JOIN plugins pl ON pl.catalog_id = c.id AND pl.removed_at IS NULL").
+		Joins("JOIN projects p ON p.id = pl.project_id AND p.deleted_at IS NULL")
+	return scope(q).Group("c.id, c.slug, c.name, c.vendor")
+}
+
+func clampStatsLimit(limit int) int {
+	if limit <= 0 || limit > maxPluginStatsLimit {
+		return 20
+	}
+	return limit
+}
+
+// Top ranks plugins by the number of projects worked on since the given time.
+func (s *PluginCatalogService) Top(since time.Time, limit int) ([]PluginUsage, error) {
+	items := []PluginUsage{}
+	err := s.usageQuery(func(q *gorm.DB) *gorm.DB { return q.Where("p.updated_at >= ?", since) }).
+		Order("projects desc, producers desc, c.name asc").Limit(clampStatsLimit(limit)).Scan(&items).Error
+	return items, err
+}
+
+// ByProducer lists the plugins in a producer's public projects, most used first.
+func (s *PluginCatalogService) ByProducer(userID uint) ([]PluginUsage, error) {
+	items := []PluginUsage{}
+	err := s.usageQuery(func(q *gorm.DB) *gorm.DB { return q.Where("p.user_id = ? AND p.public = ?", userID, true) }).
+		Order("projects desc, c.name asc").Scan(&items).Error
+	return items, err
+}
+
+// PluginStats is one catalog entry with its usage overall and within the current month.
+type PluginStats struct {
+	Catalog           models.PluginCatalog `json:"plugin"`
+	Projects          int64                `json:"projects"`
+	Producers         int64                `json:"producers"`
+	ProjectsThisMonth int64                `json:"projectsThisMonth"`
+}
+
+// Stats looks a plugin up by catalog slug or any of its aliases.
+func (s *PluginCatalogService) Stats(slugOrName string, now time.Time) (*PluginStats, error) {
+	var c models.PluginCatalog
+	err := s.DB.Where("slug = ?", strings.ToLower(slugOrName)).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var alias models.PluginAlias
+		if err := s.DB.Where("key = ?", pluginKey(slugOrName)).First(&alias).Error; err != nil {
+			return nil, err
+		}
+		err = s.DB.First(&c, alias.CatalogID).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all, month []PluginUsage
+	byID := func(q *gorm.DB) *gorm.DB { return q.Where("c.id = ?", c.ID) }
+	if err := s.usageQuery(byID).Scan(&all).Error; err != nil {
+		return nil, err
+	}
+	if err := s.usageQuery(func(q *gorm.DB) *gorm.DB { return byID(q).Where("p.updated_at >= ?", MonthStart(now)) }).Scan(&month).Error; err != nil {
+		return nil, err
+	}
+	st := &PluginStats{Catalog: c}
+	if len(all) > 0 {
+		st.Projects, st.Producers = all[0].Projects, all[0].Producers
+	}
+	if len(month) > 0 {
+		st.ProjectsThisMonth = month[0].Projects
+	}
+	return st, nil
+}
+
+// MonthStart is midnight UTC on the first of now's month.
+func MonthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CatalogFilter pages through the catalog for staff.
+type CatalogFilter struct {
+	AdminPage
+	Query string `form:"q"`
+}
+
+func (s *PluginCatalogService) List(f CatalogFilter) ([]models.PluginCatalog, int64, error) {
+	q := s.DB.Model(&models.PluginCatalog{})
+	if f.Query != "" {
+		like := likePattern(f.Query)
+		q = q.Where("LOWER(name) LIKE ? OR LOWER(vendor) LIKE ? OR slug LIKE ?", like, like, like)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []models.PluginCatalog
+	if err := f.apply(q).Preload("Aliases").Order("name asc").Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+type CatalogUpdate struct {
+	Name   *string `json:"name"`
+	Vendor *string `json:"vendor"`
+}
+
+// Update corrects an entry's display name or vendor.
+func (s *PluginCatalogService) Update(id uint, in CatalogUpdate) (*models.PluginCatalog, error) {
+	var c models.PluginCatalog
+	if err := s.DB.First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return nil, errors.New("name must not be empty")
+		}
+		updates["name"] = truncate(name, 120)
+	}
+	if in.Vendor != nil {
+		updates["vendor"] = truncate(NormalizeVendor(*in.Vendor), 120)
+	}
+	if len(updates) > 0 {
+		if err := s.DB.Model(&c).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.get(id)
+}
+
+// AddAlias points another spelling at an entry. Plugins already stored under that spelling
+// move over with it.
+func (s *PluginCatalogService) AddAlias(id uint, alias string) (*models.PluginCatalog, error) {
+	key := pluginKey(alias)
+	if key == "" {
+		return nil, errors.New("alias must contain letters or digits")
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var c models.PluginCatalog
+		if err := tx.First(&c, id).Error; err != nil {
+			return err
+		}
+		var existing models.PluginAlias
+		err := tx.Where("key = ?", key).First(&existing).Error
+		switch {
+		case err == nil && existing.CatalogID == id:
+			return nil
+		case err == nil:
+			// The spelling had its own entry; fold it in when that entry has no other aliases.
+			var n int64
+			if err := tx.Model(&models.PluginAlias{}).Where("catalog_id = ?", existing.CatalogID).Count(&n).Error; err != nil {
+				return err
+			}
+			if n == 1 {
+				return mergeCatalog(tx, existing.CatalogID, id)
+			}
+			if err := tx.Model(&existing).Update("catalog_id", id).Error; err != nil {
+				return err
+			}
+			var plugins []models.Plugin
+			if err := tx.Where("catalog_id = ?", existing.CatalogID).Find(&plugins).Error; err != nil {
+				return err
+			}
+			for _, pl := range plugins {
+				if catalogKey(pl.Name, pl.Vendor) != key {
+					continue
+				}
+				if err := tx.Model(&models.Plugin{}).Where("id = ?", pl.ID).Update("catalog_id", id).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Create(&models.PluginAlias{CatalogID: id, Key: key}).Error
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.get(id)
+}
+
+// Merge folds entry sourceID into targetID: aliases and plugin links move, the source is deleted.
+func (s *PluginCatalogService) Merge(targetID, sourceID uint) (*models.PluginCatalog, error) {
+	if targetID == sourceID {
+		return nil, errors.New("cannot merge an entry into itself")
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range []uint{targetID, sourceID} {
+			if err := tx.First(&models.PluginCatalog{}, id).Error; err != nil {
+				return err
+			}
+		}
+		return mergeCatalog(tx, sourceID, targetID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.get(targetID)
+}
+
+func mergeCatalog(tx *gorm.DB, sourceID, targetID uint) error {
+	if err := tx.Model(&models.PluginAlias{}).Where("catalog_id = ?", sourceID).Update("catalog_id", targetID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Plugin{}).Where("catalog_id = ?", sourceID).Update("catalog_id", targetID).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.PluginCatalog{}, sourceID).Error
+}
+
+func (s *PluginCatalogService) get(id uint) (*models.PluginCatalog, error) {
+	var c models.PluginCatalog
+	if err := s.DB.Preload("Aliases").First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -3,6 +3,9 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -24,14 +27,11 @@ type UpsertPluginInput struct {
 
 // ensureProjectOwned checks that the project belongs to the user and returns it.
 func (s *PluginService) ensureProjectOwned(userID, projectID uint) (*models.Project, error) {
-	var p models.Project
-	if err := s.DB.Where("user_id = ? AND id = ?", userID, projectID).First(&p).Error; err != nil {
-		return nil, err
-	}
-	return &p, nil
+	return findOwnedProject(s.DB, userID, projectID)
 }
 
 // UpsertByName creates or updates a plugin for a project identified by name (unique per project).
+// Additions and version changes are appended to the project's plugin change log.
 func (s *PluginService) UpsertByName(userID, projectID uint, in UpsertPluginInput) (*models.Plugin, error) {
 	if in.Name == "" {
 		return nil, errors.New("name required")
@@ -39,27 +39,146 @@ func (s *PluginService) UpsertByName(userID, projectID uint, in UpsertPluginInpu
 	if _, err := s.ensureProjectOwned(userID, projectID); err != nil {
 		return nil, err
 	}
+	var pl *models.Plugin
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pl, _, err = upsertPlugin(tx, projectID, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// SyncResult is the project's plugin list after a sync and the changes it caused.
+type SyncResult struct {
+	Plugins []models.Plugin      `json:"plugins"`
+	Events  []models.PluginEvent `json:"events"`
+}
+
+// Sync replaces the project's plugin list with the full set the VST reports: listed plugins
+// are upserted and stored ones missing from the set are marked removed.
+func (s *PluginService) Sync(userID, projectID uint, set []UpsertPluginInput) (*SyncResult, error) {
+	if _, err := s.ensureProjectOwned(userID, projectID); err != nil {
+		return nil, err
+	}
+	// Names are unique per project regardless of case, as in the database index.
+	listed := map[string]bool{}
+	for _, in := range set {
+		if in.Name == "" {
+			return nil, errors.New("name required")
+		}
+		key := strings.ToLower(in.Name)
+		if listed[key] {
+			return nil, fmt.Errorf("plugin %q listed twice", in.Name)
+		}
+		listed[key] = true
+	}
+	res := &SyncResult{Events: []models.PluginEvent{}}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, in := range set {
+			_, events, err := upsertPlugin(tx, projectID, in)
+			if err != nil {
+				return err
+			}
+			res.Events = append(res.Events, events...)
+		}
+		var active []models.Plugin
+		if err := tx.Where("project_id = ? AND removed_at IS NULL", projectID).Find(&active).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for i := range active {
+			pl := &active[i]
+			if listed[strings.ToLower(pl.Name)] {
+				continue
+			}
+			if err := tx.Model(pl).Update("removed_at", now).Error; err != nil {
+				return err
+			}
+			ev, err := logPluginEvent(tx, pl, models.PluginRemoved, "")
+			if err != nil {
+				return err
+			}
+			res.Events = append(res.Events, *ev)
+		}
+		return tx.Where("project_id = ? AND removed_at IS NULL", projectID).Order("name asc").Find(&res.Plugins).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// upsertPlugin writes one plugin inside tx and logs what changed. A plugin that was removed
+// and shows up again counts as added. Names match case-insensitively and keep their first spelling.
+func upsertPlugin(tx *gorm.DB, projectID uint, in UpsertPluginInput) (*models.Plugin, []models.PluginEvent, error) {
 	var pl models.Plugin
-	err := s.DB.Where("project_id = ? AND name = ?", projectID, in.Name).First(&pl).Error
+	err := tx.Where("project_id = ? AND LOWER(name) = ?", projectID, strings.ToLower(in.Name)).First(&pl).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		pl = models.Plugin{ProjectID: projectID, Name: in.Name}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	kind, previous := models.PluginEventKind(""), pl.Version
+	switch {
+	case pl.ID == 0 || pl.RemovedAt != nil:
+		kind = models.PluginAdded
+	case in.Version != pl.Version:
+		kind = models.PluginVersionChanged
 	}
 	pl.Vendor = in.Vendor
 	pl.Version = in.Version
 	pl.Format = in.Format
+	pl.RemovedAt = nil
+	catalog, err := resolvePluginCatalog(tx, in.Name, in.Vendor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if catalog != nil {
+		pl.CatalogID = &catalog.ID
+	}
 	if in.Metadata != nil {
 		pl.Metadata = datatypes.JSON(in.Metadata)
 	}
 	if pl.ID == 0 {
-		if err := s.DB.Create(&pl).Error; err != nil {
-			return nil, err
+		if err := tx.Create(&pl).Error; err != nil {
+			return nil, nil, err
 		}
 	} else {
-		if err := s.DB.Save(&pl).Error; err != nil {
-			return nil, err
+		if err := tx.Save(&pl).Error; err != nil {
+			return nil, nil, err
 		}
 	}
-	return &pl, nil
+	if kind == "" {
+		return &pl, nil, nil
+	}
+	if kind == models.PluginAdded {
+		previous = ""
+	}
+	ev, err := logPluginEvent(tx, &pl, kind, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pl, []models.PluginEvent{*ev}, nil
+}
+
+func logPluginEvent(tx *gorm.DB, pl *models.Plugin, kind models.PluginEventKind, previousVersion string) (*models.PluginEvent, error) {
+	ev := models.PluginEvent{
+		ProjectID:       pl.ProjectID,
+		PluginID:        pl.ID,
+		Kind:            kind,
+		Name:            pl.Name,
+		Vendor:          pl.Vendor,
+		Format:          pl.Format,
+		Version:         pl.Version,
+		PreviousVersion: previousVersion,
+	}
+	if err := tx.Create(&ev).Error; err != nil {
+		return nil, err
+	}
+	return &ev, nil
 }
 
 func (s *PluginService) ListByProject(userID, projectID uint) ([]models.Plugin, error) {
@@ -67,7 +186,19 @@ func (s *PluginService) ListByProject(userID, projectID uint) ([]models.Plugin,
 		return nil, err
 	}
 	var items []models.Plugin
-	if err := s.DB.Where("project_id = ?", projectID).Order("name asc").Find(&items).Error; err != nil {
+	if err := s.DB.Where("project_id = ? AND removed_at IS NULL", projectID).Order("name asc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// History returns the project's plugin change log, newest first.
+func (s *PluginService) History(userID, projectID uint) ([]models.PluginEvent, error) {
+	if _, err := s.ensureProjectOwned(userID, projectID); err != nil {
+		return nil, err
+	}
+	var items []models.PluginEvent
+	if err := s.DB.Where("project_id = ?", projectID).Order("created_at desc, id desc").Find(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
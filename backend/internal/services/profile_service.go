@@ -0,0 +1,381 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/emails"
+	"github.com/uploadparty/app/internal/imaging"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+var (
+	ErrInvalidHandle  = errors.New("handle must be 3-30 characters of lowercase letters, digits, _ or -, starting with a letter or digit")
+	ErrHandleReserved = errors.New("handle is reserved")
+	ErrHandleTaken    = errors.New("handle is already taken")
+	ErrAvatarTooLarge = errors.New("avatar is too large")
+)
+
+const (
+	AvatarSize     = 256
+	MaxAvatarBytes = 5 << 20
+	avatarQuality  = 85
+	// handleRedirectHold keeps a given-up handle pointing at its old owner; afterwards
+	// someone else may claim it and the redirect ends.
+	handleRedirectHold = 90 * 24 * time.Hour
+)
+
+var (
+	handlePattern     = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,29}$`)
+	avatarNamePattern = regexp.MustCompile(`^[0-9a-f-]{36}\.jpg$`)
+
+	// reservedHandles would shadow app routes or impersonate staff.
+	reservedHandles = map[string]bool{
+		"about": true, "admin": true, "administrator": true, "api": true, "app": true, "auth": true,
+		"avatars": true, "challenges": true, "dashboard": true, "device": true, "edit": true,
+		"explore": true, "feed": true, "health": true, "help": true, "login": true, "logout": true,
+		"me": true, "media": true, "mod": true, "moderator": true, "new": true, "null": true,
+		"plugins": true, "privacy": true, "profiles": true, "projects": true, "register": true,
+		"root": true, "rsvp": true, "search": true, "settings": true, "signup": true, "staff": true,
+		"support": true, "system": true, "terms": true, "undefined": true, "uploadparty": true, "www": true,
+	}
+)
+
+// ProfileService lets users edit their own profile: fields, handle, avatar and links.
+type ProfileService struct {
+	DB    *gorm.DB
+	Store storage.BlobStore
+	// AvatarBaseURL is where avatars are served publicly, e.g. PUBLIC_API_URL + "/avatars".
+	AvatarBaseURL string
+}
+
+func NewProfileService(db *gorm.DB) *ProfileService {
+	return &ProfileService{DB: db}
+}
+
+// SocialLinksInput edits links; nil leaves a link alone and "" removes it.
+type SocialLinksInput struct {
+	SoundCloud *string `json:"soundcloud"`
+	YouTube    *string `json:"youtube"`
+	Instagram  *string `json:"instagram"`
+}
+
+// ProfileUpdate edits the caller's profile; nil fields are left alone.
+type ProfileUpdate struct {
+	Username    *string           `json:"username"` // the public handle
+	DisplayName *string           `json:"displayName"`
+	Bio         *string           `json:"bio"`
+	Public      *bool             `json:"public"`
+	Links       *SocialLinksInput `json:"links"`
+	Locale      *string           `json:"locale"` // for emails; "" restores the default
+}
+
+func (s *ProfileService) Get(userID uint) (*models.User, error) {
+	var u models.User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *ProfileService) Update(userID uint, in ProfileUpdate) (*models.User, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var u models.User
+		if err := tx.First(&u, userID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{}
+		if in.DisplayName != nil {
+			name := strings.TrimSpace(*in.DisplayName)
+			if len([]rune(name)) > 100 {
+				return errors.New("displayName is too long")
+			}
+			updates["display_name"] = name
+		}
+		if in.Bio != nil {
+			bio := strings.TrimSpace(*in.Bio)
+			if len([]rune(bio)) > 280 {
+				return errors.New("bio is too long")
+			}
+			updates["bio"] = bio
+		}
+		if in.Public != nil {
+			updates["public"] = *in.Public
+		}
+		if in.Locale != nil {
+			locale, ok := emails.NormalizeLocale(*in.Locale)
+			if !ok && strings.TrimSpace(*in.Locale) != "" {
+				return errors.New("locale must be a language tag such as en or pt-BR")
+			}
+			updates["locale"] = locale
+		}
+		if l := in.Links; l != nil {
+			for _, f := range []struct {
+				in     *string
+				column string
+				norm   func(string) (string, error)
+			}{
+				{l.SoundCloud, "link_sound_cloud", NormalizeSoundCloudLink},
+				{l.YouTube, "link_you_tube", NormalizeYouTubeLink},
+				{l.Instagram, "link_instagram", NormalizeInstagramLink},
+			} {
+				if f.in == nil {
+					continue
+				}
+				v, err := f.norm(*f.in)
+				if err != nil {
+					return err
+				}
+				updates[f.column] = v
+			}
+		}
+		if in.Username != nil {
+			if err := changeHandle(tx, &u, *in.Username, time.Now()); err != nil {
+				return err
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&u).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(userID)
+}
+
+// NormalizeHandle lowercases a handle and checks it is allowed, without checking availability.
+func NormalizeHandle(raw string) (string, error) {
+	h := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "@")))
+	if !handlePattern.MatchString(h) {
+		return "", ErrInvalidHandle
+	}
+	if reservedHandles[h] {
+		return "", ErrHandleReserved
+	}
+	return h, nil
+}
+
+// claimHandle checks that a normalized handle is free for userID (0 for a new account): no
+// other user has it and no one else's redirect still holds it. An expired redirect is removed.
+func claimHandle(tx *gorm.DB, handle string, userID uint, now time.Time) error {
+	var n int64
+	if err := tx.Model(&models.User{}).Where("username = ? AND id <> ?", handle, userID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrHandleTaken
+	}
+	var held models.HandleRedirect
+	err := tx.Where("handle = ?", handle).First(&held).Error
+	switch {
+	case err == nil:
+		// Owners may take their old handle back at any time; others wait for the hold to end.
+		if held.UserID != userID && now.Sub(held.CreatedAt) < handleRedirectHold {
+			return ErrHandleTaken
+		}
+		return tx.Delete(&held).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// createWithHandle creates u under the handle it asks for, once that handle is allowed and free.
+func createWithHandle(db *gorm.DB, u *models.User, now time.Time) error {
+	handle, err := NormalizeHandle(u.Username)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := claimHandle(tx, handle, 0, now); err != nil {
+			return err
+		}
+		u.Username = handle
+		return tx.Create(u).Error
+	})
+}
+
+// changeHandle moves u to a new handle and leaves a redirect behind on the old one.
+func changeHandle(tx *gorm.DB, u *models.User, raw string, now time.Time) error {
+	handle, err := NormalizeHandle(raw)
+	if err != nil {
+		return err
+	}
+	if handle == u.Username {
+		return nil
+	}
+	if err := claimHandle(tx, handle, u.ID, now); err != nil {
+		return err
+	}
+	if u.Username != "" {
+		if err := tx.Create(&models.HandleRedirect{Handle: u.Username, UserID: u.ID}).Error; err != nil {
+			return err
+		}
+	}
+	u.Username = handle
+	return tx.Model(u).Update("username", handle).Error
+}
+
+// linkRule accepts a profile URL on one site, or a bare handle, and renders it canonically.
+type linkRule struct {
+	site  string
+	hosts []string
+	// paths match the URL path; the first submatch is kept and formatted into canonical.
+	paths     []*regexp.Regexp
+	canonical []string
+	// handle matches a bare handle typed without URL; it uses canonical[0].
+	handle *regexp.Regexp
+}
+
+func (r linkRule) normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	invalid := fmt.Errorf("%s link must be a %s profile URL", strings.ToLower(r.site), r.site)
+	if !strings.Contains(raw, "/") {
+		if m := r.handle.FindStringSubmatch(raw); m != nil {
+			return fmt.Sprintf(r.canonical[0], m[1]), nil
+		}
+		return "", invalid
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil {
+		return "", invalid
+	}
+	host := strings.ToLower(u.Hostname())
+	known := false
+	for _, h := range r.hosts {
+		known = known || host == h
+	}
+	if !known {
+		return "", invalid
+	}
+	for i, p := range r.paths {
+		if m := p.FindStringSubmatch(u.Path); m != nil {
+			return fmt.Sprintf(r.canonical[i], m[1]), nil
+		}
+	}
+	return "", invalid
+}
+
+var (
+	soundCloudLinks = linkRule{
+		site:      "SoundCloud",
+		hosts:     []string{"soundcloud.com", "www.soundcloud.com", "m.soundcloud.com"},
+		paths:     []*regexp.Regexp{regexp.MustCompile(`^/([A-Za-z0-9_-]{1,50})/?$`)},
+		canonical: []string{"https://soundcloud.com/%s"},
+		handle:    regexp.MustCompile(`^@?([A-Za-z0-9_-]{1,50})$`),
+	}
+	youTubeLinks = linkRule{
+		site:  "YouTube",
+		hosts: []string{"youtube.com", "www.youtube.com", "m.youtube.com"},
+		paths: []*regexp.Regexp{
+			regexp.MustCompile(`^/(@[A-Za-z0-9._-]{3,30})/?$`),
+			regexp.MustCompile(`^/channel/(UC[A-Za-z0-9_-]{22})/?$`),
+			regexp.MustCompile(`^/((?:c|user)/[A-Za-z0-9_-]{1,100})/?$`),
+		},
+		canonical: []string{"https://www.youtube.com/%s", "https://www.youtube.com/channel/%s", "https://www.youtube.com/%s"},
+		handle:    regexp.MustCompile(`^(@[A-Za-z0-9._-]{3,30})$`),
+	}
+	instagramLinks = linkRule{
+		site:      "Instagram",
+		hosts:     []string{"instagram.com", "www.instagram.com"},
+		paths:     []*regexp.Regexp{regexp.MustCompile(`^/([A-Za-z0-9._]{1,30})/?$`)},
+		canonical: []string{"https://www.instagram.com/%s"},
+		handle:    regexp.MustCompile(`^@?([A-Za-z0-9._]{1,30})$`),
+	}
+)
+
+func NormalizeSoundCloudLink(raw string) (string, error) { return soundCloudLinks.normalize(raw) }
+func NormalizeYouTubeLink(raw string) (string, error)    { return youTubeLinks.normalize(raw) }
+func NormalizeInstagramLink(raw string) (string, error)  { return instagramLinks.normalize(raw) }
+
+// SetAvatar crops and scales an uploaded JPEG, PNG or GIF to a square AvatarSize JPEG and
+// makes it the user's picture. The previous upload, if any, is deleted.
+func (s *ProfileService) SetAvatar(ctx context.Context, userID uint, body io.Reader) (*models.User, error) {
+	data, err := io.ReadAll(io.LimitReader(body, MaxAvatarBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxAvatarBytes {
+		return nil, ErrAvatarTooLarge
+	}
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := imaging.EncodeJPEG(&out, imaging.Square(img, AvatarSize), avatarQuality); err != nil {
+		return nil, err
+	}
+	u, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	name := uuid.NewString() + ".jpg"
+	key := "avatars/" + name
+	if _, err := s.Store.Put(ctx, key, &out, "image/jpeg"); err != nil {
+		return nil, err
+	}
+	old := u.AvatarKey
+	err = s.DB.Model(u).Updates(map[string]interface{}{"avatar_key": key, "picture": s.AvatarBaseURL + "/" + name}).Error
+	if err != nil {
+		_ = s.Store.Delete(ctx, key)
+		return nil, err
+	}
+	s.deleteAvatar(ctx, old)
+	return s.Get(userID)
+}
+
+// RemoveAvatar drops the uploaded avatar; the next Auth0 login fills the picture again.
+func (s *ProfileService) RemoveAvatar(ctx context.Context, userID uint) (*models.User, error) {
+	u, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.AvatarKey == "" {
+		return u, nil
+	}
+	old := u.AvatarKey
+	if err := s.DB.Model(u).Updates(map[string]interface{}{"avatar_key": "", "picture": ""}).Error; err != nil {
+		return nil, err
+	}
+	s.deleteAvatar(ctx, old)
+	return s.Get(userID)
+}
+
+func (s *ProfileService) deleteAvatar(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := s.Store.Delete(ctx, key); err != nil {
+		log.Printf("[profile] deleting avatar %s: %v", key, err)
+	}
+}
+
+// OpenAvatar streams a stored avatar by the file name in its public URL.
+func (s *ProfileService) OpenAvatar(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !avatarNamePattern.MatchString(name) {
+		return nil, storage.ErrNotFound
+	}
+	return s.Store.Open(ctx, "avatars/"+name)
+}
@@ -3,39 +3,144 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
 	"github.com/uploadparty/app/internal/models"
 )
 
-type ProjectService struct{ DB *gorm.DB }
+type ProjectService struct {
+	DB       *gorm.DB
+	Sessions *ProjectSessionService
+}
 
-func NewProjectService(db *gorm.DB) *ProjectService { return &ProjectService{DB: db} }
+func NewProjectService(db *gorm.DB) *ProjectService {
+	return &ProjectService{DB: db, Sessions: NewProjectSessionService(db)}
+}
 
 type UpsertProjectInput struct {
+	UUID            string          `json:"uuid"` // stable id from the DAW session; preferred over title
 	Title           string          `json:"title"`
 	DAW             string          `json:"daw"`
 	PluginVersion   string          `json:"pluginVersion"`
-	DurationSeconds int             `json:"durationSeconds"`
+	DurationSeconds int             `json:"durationSeconds"` // client's own count; kept on the heartbeat only
 	Metadata        json.RawMessage `json:"metadata"`
 	Public          *bool           `json:"public"`
+	At              *time.Time      `json:"at"` // when the heartbeat happened; defaults to now
 }
 
-func (s *ProjectService) UpsertByTitle(userID uint, in UpsertProjectInput) (*models.Project, error) {
-	if in.Title == "" {
-		return nil, errors.New("title required")
+// ErrProjectDeleted is returned when a plugin reports on a project the user moved to the trash.
+var ErrProjectDeleted = errors.New("project is in the trash; restore it to keep tracking")
+
+// findOwnedProject loads a project only if it belongs to userID; shared by services that hang data off projects.
+func findOwnedProject(db *gorm.DB, userID, projectID uint) (*models.Project, error) {
+	var p models.Project
+	if err := db.Where("user_id = ? AND id = ?", userID, projectID).First(&p).Error; err != nil {
+		return nil, err
 	}
+	return &p, nil
+}
+
+// findProjectByTitle picks the user's most recently active live project with this title.
+// Titles are not unique, so this is only used by clients that do not send a UUID.
+func findProjectByTitle(db *gorm.DB, userID uint, title string) (*models.Project, error) {
 	var p models.Project
-	err := s.DB.Where("user_id = ? AND title = ?", userID, in.Title).First(&p).Error
+	if err := db.Where("user_id = ? AND title = ?", userID, title).Order("updated_at desc, id desc").First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// normalizeProjectUUID validates a client UUID and returns its canonical lowercase form.
+func normalizeProjectUUID(raw string) (string, error) {
+	id, err := uuid.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", errors.New("uuid must be a valid UUID")
+	}
+	return id.String(), nil
+}
+
+// findProjectByUUID loads the project a client UUID refers to. A project recorded before the
+// plugin sent UUIDs is adopted by title: the first client UUID seen for it replaces the
+// server-generated one.
+func findProjectByUUID(db *gorm.DB, userID uint, id, title string) (*models.Project, error) {
+	var p models.Project
+	err := db.Unscoped().Where("user_id = ? AND uuid = ?", userID, id).First(&p).Error
+	if err == nil {
+		if p.DeletedAt.Valid {
+			return nil, ErrProjectDeleted
+		}
+		return &p, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) || title == "" {
+		return nil, err
+	}
+	err = db.Where("user_id = ? AND title = ? AND uuid_from_client = ?", userID, title, false).
+		Order("updated_at desc, id desc").First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	p.UUID = id
+	p.UUIDFromClient = true
+	return &p, nil
+}
+
+// Upsert creates or updates a project from a VST heartbeat, keyed by the client UUID or, for
+// older plugins, by title. Every call is also kept as a heartbeat, and the project's duration
+// is recomputed from the sessions they form.
+func (s *ProjectService) Upsert(userID uint, in UpsertProjectInput) (*models.Project, error) {
+	var p *models.Project
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		p, err = s.upsert(tx, userID, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *ProjectService) upsert(tx *gorm.DB, userID uint, in UpsertProjectInput) (*models.Project, error) {
+	in.Title = strings.TrimSpace(in.Title)
+	if in.UUID == "" && in.Title == "" {
+		return nil, errors.New("uuid or title required")
+	}
+	if len(in.Title) > maxProjectTitleLength {
+		return nil, errors.New("title is too long")
+	}
+	var p *models.Project
+	var err error
+	if in.UUID != "" {
+		if in.UUID, err = normalizeProjectUUID(in.UUID); err != nil {
+			return nil, err
+		}
+		p, err = findProjectByUUID(tx, userID, in.UUID, in.Title)
+	} else {
+		p, err = findProjectByTitle(tx, userID, in.Title)
+	}
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		p = models.Project{UserID: userID, Title: in.Title}
+		if in.Title == "" {
+			return nil, errors.New("title required for a new project")
+		}
+		p = &models.Project{UserID: userID, UUID: in.UUID, UUIDFromClient: in.UUID != ""}
+		if p.UUID == "" {
+			p.UUID = uuid.NewString()
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	wasPublic := p.Public
+	if in.Title != "" {
+		p.Title = in.Title // the DAW file may have been renamed
 	}
 	p.DAW = in.DAW
 	p.PluginVersion = in.PluginVersion
-	p.DurationSeconds = in.DurationSeconds
 	if in.Metadata != nil {
 		p.Metadata = datatypes.JSON(in.Metadata)
 	}
@@ -43,43 +148,323 @@ func (s *ProjectService) UpsertByTitle(userID uint, in UpsertProjectInput) (*mod
 		p.Public = *in.Public
 	}
 	if p.ID == 0 {
-		if err := s.DB.Create(&p).Error; err != nil {
+		if err := tx.Create(p).Error; err != nil {
 			return nil, err
 		}
 	} else {
-		if err := s.DB.Save(&p).Error; err != nil {
+		if err := tx.Save(p).Error; err != nil {
 			return nil, err
 		}
 	}
-	return &p, nil
+	if p.Public && !wasPublic {
+		if err := recordActivity(tx, userID, models.ActivityProjectPublished, p.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	err = s.Sessions.record(tx, p, heartbeat{
+		At:                    heartbeatTime(in.At, time.Now()),
+		DAW:                   in.DAW,
+		PluginVersion:         in.PluginVersion,
+		ClientDurationSeconds: in.DurationSeconds,
+		Metadata:              datatypes.JSON(in.Metadata),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func (s *ProjectService) MarkComplete(userID, projectID uint) (*models.Project, error) {
-	var p models.Project
-	if err := s.DB.Where("user_id = ? AND id = ?", userID, projectID).First(&p).Error; err != nil {
+	var p *models.Project
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		p, err = markComplete(tx, userID, projectID, time.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// markComplete completes a project as of at, which may be a buffered client timestamp.
+func markComplete(db *gorm.DB, userID, projectID uint, at time.Time) (*models.Project, error) {
+	p, err := findOwnedProject(db, userID, projectID)
+	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
+	wasComplete := p.Status == models.StatusComplete
 	p.Status = models.StatusComplete
-	p.CompletedAt = &now
-	if err := s.DB.Save(&p).Error; err != nil {
+	p.CompletedAt = &at
+	if err := db.Save(p).Error; err != nil {
+		return nil, err
+	}
+	if !wasComplete {
+		if err := recordActivity(db, userID, models.ActivityProjectCompleted, p.ID, at); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+const maxProjectTitleLength = 200
+
+// UpdateProjectInput edits a project from the app; nil fields are left alone.
+type UpdateProjectInput struct {
+	Title  *string               `json:"title"`
+	Public *bool                 `json:"public"`
+	Status *models.ProjectStatus `json:"status"` // in_progress reopens a completed project
+}
+
+// statusUpdates returns the columns to set when moving a project to status.
+func statusUpdates(p *models.Project, status models.ProjectStatus) (map[string]interface{}, error) {
+	switch status {
+	case models.StatusInProgress:
+		return map[string]interface{}{"status": status, "completed_at": nil}, nil
+	case models.StatusComplete:
+		updates := map[string]interface{}{"status": status}
+		if p.CompletedAt == nil {
+			updates["completed_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+		}
+		return updates, nil
+	default:
+		return nil, errors.New("status must be in_progress or complete")
+	}
+}
+
+// Get returns one of the user's projects with its plugins and audio.
+func (s *ProjectService) Get(userID, projectID uint) (*models.Project, error) {
+	var p models.Project
+	err := s.DB.Where("user_id = ? AND id = ?", userID, projectID).
+		Preload("Plugins", activePlugins).Preload("AudioFiles", orderAudioFiles).First(&p).Error
+	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
 
-func (s *ProjectService) ListPublicByUser(userID uint) ([]models.Project, error) {
-	var ps []models.Project
-	if err := s.DB.Where("user_id = ? AND public = ?", userID, true).Preload("Plugins").Order("created_at desc").Find(&ps).Error; err != nil {
+// Update renames a project in place, toggles its visibility or moves it between in progress
+// and complete.
+func (s *ProjectService) Update(userID, projectID uint, in UpdateProjectInput) (*models.Project, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		p, err := findOwnedProject(tx, userID, projectID)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{}
+		if in.Status != nil {
+			if updates, err = statusUpdates(p, *in.Status); err != nil {
+				return err
+			}
+		}
+		if in.Title != nil {
+			title := strings.TrimSpace(*in.Title)
+			if title == "" {
+				return errors.New("title required")
+			}
+			if len(title) > maxProjectTitleLength {
+				return errors.New("title is too long")
+			}
+			updates["title"] = title
+		}
+		if in.Public != nil {
+			updates["public"] = *in.Public
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		wasPublic, wasComplete := p.Public, p.Status == models.StatusComplete
+		if err := tx.Model(p).Updates(updates).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if in.Public != nil && *in.Public && !wasPublic {
+			if err := recordActivity(tx, userID, models.ActivityProjectPublished, p.ID, now); err != nil {
+				return err
+			}
+		}
+		if in.Status != nil && *in.Status == models.StatusComplete && !wasComplete {
+			return recordActivity(tx, userID, models.ActivityProjectCompleted, p.ID, now)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	return ps, nil
+	return s.Get(userID, projectID)
+}
+
+// Delete moves a project to the trash. Its plugins, audio and sessions are kept so it can
+// be restored. Heartbeats for its UUID are refused meanwhile; a title-only heartbeat starts
+// a new project.
+func (s *ProjectService) Delete(userID, projectID uint) error {
+	res := s.DB.Where("user_id = ?", userID).Delete(&models.Project{}, projectID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore brings a project back from the trash.
+func (s *ProjectService) Restore(userID, projectID uint) (*models.Project, error) {
+	res := s.DB.Unscoped().Model(&models.Project{}).
+		Where("user_id = ? AND id = ? AND deleted_at IS NOT NULL", userID, projectID).Update("deleted_at", nil)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.Get(userID, projectID)
 }
 
-func (s *ProjectService) ListByUser(userID uint) ([]models.Project, error) {
-	var ps []models.Project
-	if err := s.DB.Where("user_id = ?", userID).Preload("Plugins").Order("created_at desc").Find(&ps).Error; err != nil {
+func orderAudioFiles(db *gorm.DB) *gorm.DB { return db.Order("created_at desc") }
+
+// activePlugins hides plugins a sync marked as removed.
+func activePlugins(db *gorm.DB) *gorm.DB { return db.Where("removed_at IS NULL") }
+
+// ProjectFilter selects and orders a page of projects. Zero values mean "any".
+type ProjectFilter struct {
+	CursorPage
+	Status string `form:"status"` // in_progress | complete
+	DAW    string `form:"daw"`
+	Plugin string `form:"plugin"` // plugin name or any catalog alias of it
+	// From and To bound DateField (created, updated or completed); RFC 3339 or YYYY-MM-DD,
+	// where a bare To date includes that whole day.
+	From      string `form:"from"`
+	To        string `form:"to"`
+	DateField string `form:"dateField"`
+	Public    *bool  `form:"public"`
+	Sort      string `form:"sort"`  // created (default) | updated | title | duration
+	Order     string `form:"order"` // asc | desc; defaults to asc for title, desc otherwise
+	// Plugins=false skips loading each project's plugins, which is much cheaper for long lists.
+	Plugins *bool `form:"plugins"`
+	Deleted bool  `form:"deleted"` // list the trash instead
+}
+
+var projectDateColumns = map[string]string{
+	"":          "projects.created_at",
+	"created":   "projects.created_at",
+	"updated":   "projects.updated_at",
+	"completed": "projects.completed_at",
+}
+
+// projectKeyset maps ?sort= and ?order= to a keyset over projects.
+func projectKeyset(sort, order string) (keyset[models.Project], error) {
+	k := keyset[models.Project]{IDColumn: "projects.id", id: func(p *models.Project) uint { return p.ID }}
+	switch sort {
+	case "", "created":
+		sort = "created"
+		k.Column, k.Desc = "projects.created_at", true
+		k.value, k.parse = timeKey(func(p *models.Project) time.Time { return p.CreatedAt })
+	case "updated":
+		k.Column, k.Desc = "projects.updated_at", true
+		k.value, k.parse = timeKey(func(p *models.Project) time.Time { return p.UpdatedAt })
+	case "deleted":
+		k.Column, k.Desc = "projects.deleted_at", true
+		k.value, k.parse = timeKey(func(p *models.Project) time.Time { return p.DeletedAt.Time })
+	case "title":
+		k.Column = "projects.title"
+		k.value, k.parse = stringKey(func(p *models.Project) string { return p.Title })
+	case "duration":
+		k.Column, k.Desc = "projects.duration_seconds", true
+		k.value, k.parse = intKey(func(p *models.Project) int { return p.DurationSeconds })
+	default:
+		return k, errors.New("sort must be created, updated, title or duration")
+	}
+	switch order {
+	case "":
+	case "asc":
+		k.Desc = false
+	case "desc":
+		k.Desc = true
+	default:
+		return k, errors.New("order must be asc or desc")
+	}
+	k.Name = sort + ":" + k.direction()
+	return k, nil
+}
+
+// parseDateBound reads a from/to value. A bare date as upper bound means the end of that day.
+func parseDateBound(s string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q", s)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// listProjects applies f on top of base and loads one page.
+func (s *ProjectService) listProjects(base *gorm.DB, f ProjectFilter) (*Page[models.Project], error) {
+	q := base.Model(&models.Project{})
+	if f.Deleted {
+		q = q.Unscoped().Where("projects.deleted_at IS NOT NULL")
+		if f.Sort == "" {
+			f.Sort = "deleted"
+		}
+	}
+	switch f.Status {
+	case "":
+	case string(models.StatusInProgress), string(models.StatusComplete):
+		q = q.Where("projects.status = ?", f.Status)
+	default:
+		return nil, errors.New("status must be in_progress or complete")
+	}
+	if f.DAW != "" {
+		q = q.Where("LOWER(projects.daw) = ?", strings.ToLower(f.DAW))
+	}
+	if f.Plugin != "" {
+		q = q.Where(`EXISTS (SELECT 1 FROM plugins pl WHERE pl.project_id = projects.id AND pl.removed_at IS NULL
+			AND (LOWER(pl.name) = ? OR pl.catalog_id IN (SELECT catalog_id FROM plugin_aliases WHERE key = ?)))`,
+			strings.ToLower(f.Plugin), pluginKey(f.Plugin))
+	}
+	if f.Public != nil {
+		q = q.Where("projects.public = ?", *f.Public)
+	}
+	dateCol, ok := projectDateColumns[f.DateField]
+	if !ok {
+		return nil, errors.New("dateField must be created, updated or completed")
+	}
+	if f.From != "" {
+		from, err := parseDateBound(f.From, false)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(dateCol+" >= ?", from)
+	}
+	if f.To != "" {
+		to, err := parseDateBound(f.To, true)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(dateCol+" < ?", to)
+	}
+	k, err := projectKeyset(f.Sort, f.Order)
+	if err != nil {
 		return nil, err
 	}
-	return ps, nil
+	if f.Plugins == nil || *f.Plugins {
+		q = q.Preload("Plugins", activePlugins)
+	}
+	return paginate(q.Preload("AudioFiles", orderAudioFiles), f.CursorPage, k)
+}
+
+// ListPublicByUser pages through a producer's public projects; f.Public and f.Deleted are ignored.
+func (s *ProjectService) ListPublicByUser(userID uint, f ProjectFilter) (*Page[models.Project], error) {
+	f.Public, f.Deleted = nil, false
+	return s.listProjects(s.DB.Where("projects.user_id = ? AND projects.public = ?", userID, true), f)
+}
+
+// ListByUser pages through the user's own projects.
+func (s *ProjectService) ListByUser(userID uint, f ProjectFilter) (*Page[models.Project], error) {
+	return s.listProjects(s.DB.Where("projects.user_id = ?", userID), f)
 }
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const (
+	// DefaultSessionIdleGap splits heartbeats into separate sessions.
+	DefaultSessionIdleGap = 15 * time.Minute
+	// maxHeartbeatSkew tolerates client clocks running slightly ahead of ours.
+	maxHeartbeatSkew = 5 * time.Minute
+
+	maxSessionPageSize = 500
+)
+
+// ProjectSessionService keeps the heartbeat history of projects and groups it into sessions.
+type ProjectSessionService struct {
+	DB      *gorm.DB
+	IdleGap time.Duration
+}
+
+func NewProjectSessionService(db *gorm.DB) *ProjectSessionService {
+	return &ProjectSessionService{DB: db, IdleGap: DefaultSessionIdleGap}
+}
+
+// heartbeat is what one ingest call contributes to the timeline.
+type heartbeat struct {
+	At                    time.Time
+	DAW                   string
+	PluginVersion         string
+	ClientDurationSeconds int
+	Metadata              datatypes.JSON
+}
+
+// heartbeatTime picks the moment a heartbeat happened. Clients that buffered heartbeats
+// offline send their own timestamp; one from the future is clamped to now.
+func heartbeatTime(at *time.Time, now time.Time) time.Time {
+	if at == nil || at.IsZero() || at.After(now.Add(maxHeartbeatSkew)) {
+		return now
+	}
+	return *at
+}
+
+// record stores hb for p inside tx, extends or starts the session it falls in and refreshes
+// the project's total. A late heartbeat that bridges two sessions merges them.
+func (s *ProjectSessionService) record(tx *gorm.DB, p *models.Project, hb heartbeat) error {
+	gap := s.IdleGap
+	if gap <= 0 {
+		gap = DefaultSessionIdleGap
+	}
+	var near []models.ProjectSession
+	if err := tx.Where("project_id = ? AND started_at <= ? AND ended_at >= ?", p.ID, hb.At.Add(gap), hb.At.Add(-gap)).
+		Order("started_at asc").Find(&near).Error; err != nil {
+		return err
+	}
+	var sess models.ProjectSession
+	if len(near) == 0 {
+		sess = models.ProjectSession{ProjectID: p.ID, UserID: p.UserID, StartedAt: hb.At, EndedAt: hb.At}
+	} else {
+		sess = near[0]
+		for _, other := range near[1:] {
+			if other.StartedAt.Before(sess.StartedAt) {
+				sess.StartedAt = other.StartedAt
+			}
+			if other.EndedAt.After(sess.EndedAt) {
+				sess.EndedAt = other.EndedAt
+			}
+			sess.HeartbeatCount += other.HeartbeatCount
+			if err := tx.Model(&models.ProjectHeartbeat{}).Where("session_id = ?", other.ID).Update("session_id", sess.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.ProjectSession{}, other.ID).Error; err != nil {
+				return err
+			}
+		}
+		if hb.At.Before(sess.StartedAt) {
+			sess.StartedAt = hb.At
+		}
+		if hb.At.After(sess.EndedAt) {
+			sess.EndedAt = hb.At
+		}
+	}
+	sess.HeartbeatCount++
+	sess.DurationSeconds = int(sess.EndedAt.Sub(sess.StartedAt) / time.Second)
+	if hb.DAW != "" {
+		sess.DAW = hb.DAW
+	}
+	if hb.PluginVersion != "" {
+		sess.PluginVersion = hb.PluginVersion
+	}
+	if err := tx.Save(&sess).Error; err != nil {
+		return err
+	}
+	row := models.ProjectHeartbeat{
+		ProjectID:             p.ID,
+		SessionID:             sess.ID,
+		At:                    hb.At,
+		DAW:                   hb.DAW,
+		PluginVersion:         hb.PluginVersion,
+		ClientDurationSeconds: hb.ClientDurationSeconds,
+		Metadata:              hb.Metadata,
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return err
+	}
+	var total int64
+	if err := tx.Model(&models.ProjectSession{}).Where("project_id = ?", p.ID).
+		Select("COALESCE(SUM(duration_seconds), 0)").Scan(&total).Error; err != nil {
+		return err
+	}
+	p.DurationSeconds = int(total)
+	return tx.Model(p).UpdateColumn("duration_seconds", p.DurationSeconds).Error
+}
+
+// ListByProject returns the project's most recent sessions, newest first, and its total
+// tracked time in seconds.
+func (s *ProjectSessionService) ListByProject(userID, projectID uint, limit int) ([]models.ProjectSession, int, error) {
+	p, err := findOwnedProject(s.DB, userID, projectID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 || limit > maxSessionPageSize {
+		limit = maxSessionPageSize
+	}
+	var items []models.ProjectSession
+	err = s.DB.Where("project_id = ?", projectID).Order("started_at desc").Limit(limit).Find(&items).Error
+	return items, p.DurationSeconds, err
+}
+
+// ListHeartbeats returns the raw heartbeats of one session in order.
+func (s *ProjectSessionService) ListHeartbeats(userID, projectID, sessionID uint) ([]models.ProjectHeartbeat, error) {
+	if _, err := findOwnedProject(s.DB, userID, projectID); err != nil {
+		return nil, err
+	}
+	var items []models.ProjectHeartbeat
+	err := s.DB.Where("project_id = ? AND session_id = ?", projectID, sessionID).Order("at asc").Find(&items).Error
+	return items, err
+}
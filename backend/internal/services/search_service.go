@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchProjects  = "projects"
+	SearchProducers = "producers"
+	SearchPlugins   = "plugins"
+
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	maxSearchTerms     = 8
+)
+
+// SearchService finds public projects, producers and catalog plugins. On Postgres it uses
+// the search_vector columns from migrations/020_search.sql; elsewhere (SQLite in tests) it
+// falls back to substring matching over the same fields.
+type SearchService struct {
+	DB *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService { return &SearchService{DB: db} }
+
+type ProjectHit struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	DAW         string    `json:"daw"`
+	Status      string    `json:"status"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	UserID      uint      `json:"userId"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName"`
+}
+
+type ProducerHit struct {
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	Bio         string `json:"bio"`
+}
+
+type PluginHit struct {
+	ID     uint   `json:"id"`
+	Slug   string `json:"slug"`
+	Name   string `json:"name"`
+	Vendor string `json:"vendor"`
+}
+
+// SearchResults holds the best matches of each requested kind; kinds not asked for are omitted.
+type SearchResults struct {
+	Query     string        `json:"query"`
+	Projects  []ProjectHit  `json:"projects,omitempty"`
+	Producers []ProducerHit `json:"producers,omitempty"`
+	Plugins   []PluginHit   `json:"plugins,omitempty"`
+}
+
+// searchTerms splits a query into lowercase words of letters and digits, which are safe to
+// splice into a tsquery.
+func searchTerms(q string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// prefixQuery matches documents containing every term, the last ones as prefixes, so
+// "serum bas" finds "Serum bass".
+func prefixQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// Search runs q against the kinds listed (all when empty), returning up to limit hits each.
+func (s *SearchService) Search(q string, kinds []string, limit int) (*SearchResults, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, errors.New("q must contain at least one word")
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	want := map[string]bool{}
+	for _, k := range kinds {
+		switch k {
+		case SearchProjects, SearchProducers, SearchPlugins:
+			want[k] = true
+		case "":
+		default:
+			return nil, errors.New("type must be projects, producers or plugins")
+		}
+	}
+	if len(want) == 0 {
+		want = map[string]bool{SearchProjects: true, SearchProducers: true, SearchPlugins: true}
+	}
+
+	res := &SearchResults{Query: strings.Join(terms, " ")}
+	if want[SearchProjects] {
+		res.Projects = []ProjectHit{}
+		q := s.DB.Table("projects AS p").
+			Select("p.id, p.title, p.daw, p.status, p.updated_at, p.user_id, u.username, u.display_name").
+			Joins("JOIN users u ON u.id = p.user_id").
+			Where("p.public = ? AND u.public = ? AND p.deleted_at IS NULL", true, true)
+		if err := s.match(q, "p", terms, "p.title", "COALESCE(json_extract(p.metadata, '$.tags'), '')").
+			Limit(limit).Scan(&res.Projects).Error; err != nil {
+			return nil, err
+		}
+	}
+	if want[SearchProducers] {
+		res.Producers = []ProducerHit{}
+		q := s.DB.Table("users AS u").Select("u.id, u.username, u.display_name, u.bio").Where("u.public = ?", true)
+		if err := s.match(q, "u", terms, "u.username", "COALESCE(u.display_name, '')", "COALESCE(u.bio, '')").
+			Limit(limit).Scan(&res.Producers).Error; err != nil {
+			return nil, err
+		}
+	}
+	if want[SearchPlugins] {
+		res.Plugins = []PluginHit{}
+		q := s.DB.Table("plugin_catalogs AS c").Select("c.id, c.slug, c.name, c.vendor")
+		if err := s.match(q, "c", terms, "c.name", "c.vendor").
+			Limit(limit).Scan(&res.Plugins).Error; err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// match filters and ranks q. On Postgres it uses alias.search_vector; otherwise every term
+// must appear in one of the fallback columns, and newer rows come first.
+func (s *SearchService) match(q *gorm.DB, alias string, terms []string, fallback ...string) *gorm.DB {
+	if s.DB.Dialector.Name() == "postgres" {
+		tsq := prefixQuery(terms)
+		return q.Where(alias+".search_vector @@ to_tsquery('simple', ?)", tsq).
+			Order(gorm.Expr("ts_rank("+alias+".search_vector, to_tsquery('simple', ?)) DESC", tsq)).
+			Order(alias + ".id DESC")
+	}
+	for _, t := range terms {
+		like := likePattern(t)
+		ors := make([]string, len(fallback))
+		args := make([]interface{}, len(fallback))
+		for i, col := range fallback {
+			ors[i] = "LOWER(" + col + ") LIKE ?"
+			args[i] = like
+		}
+		q = q.Where("("+strings.Join(ors, " OR ")+")", args...)
+	}
+	return q.Order(alias + ".id DESC")
+}
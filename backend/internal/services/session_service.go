@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected; all sessions in this chain were revoked")
+)
+
+// SessionService issues legacy access/refresh token pairs and tracks their revocation.
+type SessionService struct {
+	DB         *gorm.DB
+	Secret     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewSessionService(db *gorm.DB, secret string, accessTTL, refreshTTL time.Duration) *SessionService {
+	return &SessionService{DB: db, Secret: secret, AccessTTL: accessTTL, RefreshTTL: refreshTTL}
+}
+
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	AccessExpiresAt  time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// ClientInfo records where a session was created, for the user's own review.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// issue creates a session row in familyID together with its access token.
+func (s *SessionService) issue(tx *gorm.DB, userID uint, familyID string, client ClientInfo, now time.Time) (*models.Session, *TokenPair, error) {
+	refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	jti := uuid.NewString()
+	accessExp := now.Add(s.AccessTTL)
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"jti": jti,
+		"sid": familyID,
+		"iat": now.Unix(),
+		"exp": accessExp.Unix(),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Secret))
+	if err != nil {
+		return nil, nil, err
+	}
+	sess := models.Session{
+		UserID:          userID,
+		FamilyID:        familyID,
+		TokenHash:       hashRefreshToken(refresh),
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExp,
+		ExpiresAt:       now.Add(s.RefreshTTL),
+		UserAgent:       truncate(client.UserAgent, 255),
+		IPAddress:       truncate(client.IPAddress, 45),
+	}
+	if err := tx.Create(&sess).Error; err != nil {
+		return nil, nil, err
+	}
+	return &sess, &TokenPair{AccessToken: signed, AccessExpiresAt: accessExp, RefreshToken: refresh, RefreshExpiresAt: sess.ExpiresAt}, nil
+}
+
+// Issue starts a new session family after a successful login.
+func (s *SessionService) Issue(userID uint, client ClientInfo) (*TokenPair, error) {
+	_, pair, err := s.issue(s.DB, userID, uuid.NewString(), client, time.Now())
+	return pair, err
+}
+
+// Refresh rotates a refresh token. Each token works once: presenting one that was already
+// rotated means it leaked, so the whole family and its access tokens are revoked.
+func (s *SessionService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	now := time.Now()
+	var pair *TokenPair
+	var reused *models.Session
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var sess models.Session
+		if err := tx.Where("token_hash = ?", hashRefreshToken(refreshToken)).First(&sess).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if sess.RevokedAt != nil {
+			if sess.ReplacedByID != nil {
+				reused = &sess
+				return ErrRefreshTokenReused
+			}
+			return ErrInvalidRefreshToken
+		}
+		if now.After(sess.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		// Claim the row; a concurrent refresh with the same token loses here.
+		res := tx.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sess.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "last_used_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			reused = &sess
+			return ErrRefreshTokenReused
+		}
+		next, p, err := s.issue(tx, sess.UserID, sess.FamilyID, client, now)
+		if err != nil {
+			return err
+		}
+		pair = p
+		return tx.Model(&models.Session{}).Where("id = ?", sess.ID).Update("replaced_by_id", next.ID).Error
+	})
+	if reused != nil {
+		if rerr := s.revokeWhere(s.DB.Where("family_id = ?", reused.FamilyID), now); rerr != nil {
+			log.Printf("[sessions] revoking family %s after reuse failed: %v", reused.FamilyID, rerr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// revokeWhere ends every session matched by scope and blocklists their live access tokens.
+func (s *SessionService) revokeWhere(scope *gorm.DB, now time.Time) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var sessions []models.Session
+		if err := tx.Model(&models.Session{}).Where(scope).Find(&sessions).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		var revoked []models.RevokedToken
+		for _, sess := range sessions {
+			ids = append(ids, sess.ID)
+			if sess.AccessJTI != "" && sess.AccessExpiresAt.After(now) {
+				revoked = append(revoked, models.RevokedToken{JTI: sess.AccessJTI, UserID: sess.UserID, ExpiresAt: sess.AccessExpiresAt})
+			}
+		}
+		if len(ids) > 0 {
+			if err := tx.Model(&models.Session{}).Where("id IN ? AND revoked_at IS NULL", ids).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if len(revoked) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Logout ends the session family an access token belongs to and revokes that token.
+func (s *SessionService) Logout(userID uint, familyID, jti string, accessExpiresAt time.Time) error {
+	now := time.Now()
+	if familyID != "" {
+		if err := s.revokeWhere(s.DB.Where("user_id = ? AND family_id = ?", userID, familyID), now); err != nil {
+			return err
+		}
+	}
+	if jti == "" || !accessExpiresAt.After(now) {
+		return nil
+	}
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: accessExpiresAt}).Error
+}
+
+// LogoutAll ends every session of the user ("log out everywhere").
+func (s *SessionService) LogoutAll(userID uint) error {
+	return s.revokeWhere(s.DB.Where("user_id = ?", userID), time.Now())
+}
+
+// IsRevoked reports whether an access token id is on the revocation list.
+func (s *SessionService) IsRevoked(jti string) (bool, error) {
+	var n int64
+	err := s.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&n).Error
+	return n > 0, err
+}
+
+// Cleanup drops expired sessions and revocation entries on an interval. It never returns.
+func (s *SessionService) Cleanup(every time.Duration) {
+	for {
+		now := time.Now()
+		if err := s.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+			log.Printf("[sessions] revocation cleanup failed: %v", err)
+		}
+		if err := s.DB.Where("expires_at < ?", now).Delete(&models.Session{}).Error; err != nil {
+			log.Printf("[sessions] session cleanup failed: %v", err)
+		}
+		time.Sleep(every)
+	}
+}
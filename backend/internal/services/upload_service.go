@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+const (
+	// uploadSessionTTL is how long an idle session keeps its chunks before cleanup.
+	uploadSessionTTL = 24 * time.Hour
+	// staleAssembleAfter lets finalize be retried if a previous attempt died mid-assembly.
+	staleAssembleAfter = 15 * time.Minute
+)
+
+var (
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match received bytes")
+	ErrUploadIncomplete     = errors.New("upload is missing bytes")
+	ErrUploadClosed         = errors.New("upload session is closed")
+	ErrUploadBusy           = errors.New("upload is already being finalized")
+	ErrChunkTooLarge        = errors.New("chunk exceeds size limit")
+)
+
+type UploadService struct {
+	DB            *gorm.DB
+	Store         storage.BlobStore
+	Audio         *AudioService
+	MaxTotalBytes int64
+	MaxChunkBytes int64
+}
+
+func NewUploadService(db *gorm.DB, store storage.BlobStore, audio *AudioService, maxTotal, maxChunk int64) *UploadService {
+	return &UploadService{DB: db, Store: store, Audio: audio, MaxTotalBytes: maxTotal, MaxChunkBytes: maxChunk}
+}
+
+type InitUploadInput struct {
+	ProjectID  uint   `json:"projectId"`
+	Filename   string `json:"filename"`
+	TotalBytes int64  `json:"totalBytes"`
+}
+
+// chunkKey names the blob for one attempt at writing chunk index. Every attempt gets its own
+// key, so a client that loses a race for an offset cannot overwrite the chunk that won.
+func chunkKey(sessionID string, index int) string {
+	return fmt.Sprintf("uploads/%s/%06d-%s", sessionID, index, uuid.NewString())
+}
+
+func chunkKeys(sess *models.UploadSession) []string {
+	if len(sess.ChunkKeys) == sess.ChunkCount {
+		return sess.ChunkKeys
+	}
+	// Sessions started before keys were recorded used one fixed key per index.
+	keys := make([]string, sess.ChunkCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("uploads/%s/%06d", sess.ID, i)
+	}
+	return keys
+}
+
+func (s *UploadService) Init(userID uint, in InitUploadInput) (*models.UploadSession, error) {
+	if in.TotalBytes <= 0 {
+		return nil, errors.New("totalBytes must be positive")
+	}
+	if in.TotalBytes > s.MaxTotalBytes {
+		return nil, ErrAudioTooLarge
+	}
+	if _, err := findOwnedProject(s.DB, userID, in.ProjectID); err != nil {
+		return nil, err
+	}
+	sess := models.UploadSession{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		ProjectID:  in.ProjectID,
+		Filename:   cleanFilename(in.Filename),
+		TotalBytes: in.TotalBytes,
+		Status:     models.UploadPending,
+		ExpiresAt:  time.Now().Add(uploadSessionTTL),
+	}
+	if err := s.DB.Create(&sess).Error; err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (s *UploadService) Get(userID uint, id string) (*models.UploadSession, error) {
+	var sess models.UploadSession
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&sess).Error; err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// AppendChunk stores body as the next chunk. offset must equal the bytes already received,
+// which makes retries of an acknowledged chunk fail loudly instead of duplicating data.
+func (s *UploadService) AppendChunk(ctx context.Context, userID uint, id string, offset int64, body io.Reader) (*models.UploadSession, error) {
+	sess, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Status != models.UploadPending || time.Now().After(sess.ExpiresAt) {
+		return nil, ErrUploadClosed
+	}
+	if offset != sess.ReceivedBytes {
+		return sess, ErrUploadOffsetMismatch
+	}
+	limit := s.MaxChunkBytes
+	if remaining := sess.TotalBytes - sess.ReceivedBytes; remaining < limit {
+		limit = remaining
+	}
+	key := chunkKey(sess.ID, sess.ChunkCount)
+	n, err := s.Store.Put(ctx, key, &cappedReader{r: body, left: limit}, "application/octet-stream")
+	if err != nil {
+		s.deleteChunk(ctx, key)
+		if errors.Is(err, ErrAudioTooLarge) {
+			return nil, ErrChunkTooLarge
+		}
+		return nil, err
+	}
+	if n == 0 {
+		s.deleteChunk(ctx, key)
+		return sess, nil
+	}
+	// Conditional update guards against two clients appending to the same session at once.
+	res := s.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND received_bytes = ? AND status = ?", sess.ID, sess.ReceivedBytes, models.UploadPending).
+		Updates(map[string]interface{}{
+			"received_bytes": sess.ReceivedBytes + n,
+			"chunk_count":    sess.ChunkCount + 1,
+			"chunk_keys":     datatypes.NewJSONSlice(append(chunkKeys(sess), key)),
+			"expires_at":     time.Now().Add(uploadSessionTTL),
+		})
+	if res.Error != nil {
+		s.deleteChunk(ctx, key)
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Another append took this offset first; its chunk lives under a different key.
+		s.deleteChunk(ctx, key)
+		return nil, ErrUploadOffsetMismatch
+	}
+	return s.Get(userID, id)
+}
+
+// Finalize assembles the chunks into a project AudioFile. Calling it again after success
+// returns the same AudioFile, so clients can safely retry.
+func (s *UploadService) Finalize(ctx context.Context, userID uint, id string) (*models.AudioFile, error) {
+	sess, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Status == models.UploadComplete && sess.AudioFileID != nil {
+		var af models.AudioFile
+		if err := s.DB.First(&af, *sess.AudioFileID).Error; err != nil {
+			return nil, err
+		}
+		return &af, nil
+	}
+	if sess.ReceivedBytes != sess.TotalBytes {
+		return nil, ErrUploadIncomplete
+	}
+	if _, err := findOwnedProject(s.DB, userID, sess.ProjectID); err != nil {
+		return nil, err
+	}
+	res := s.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))", sess.ID, models.UploadPending, models.UploadAssembling, time.Now().Add(-staleAssembleAfter)).
+		Update("status", models.UploadAssembling)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrUploadBusy
+	}
+
+	rc := storage.ConcatReader(ctx, s.Store, chunkKeys(sess))
+	af, err := s.Audio.store(ctx, userID, sess.ProjectID, sess.Filename, rc, sess.TotalBytes)
+	rc.Close()
+	if err != nil {
+		s.DB.Model(&models.UploadSession{}).Where("id = ?", sess.ID).Update("status", models.UploadPending)
+		return nil, err
+	}
+	if err := s.DB.Model(&models.UploadSession{}).Where("id = ?", sess.ID).
+		Updates(map[string]interface{}{"status": models.UploadComplete, "audio_file_id": af.ID}).Error; err != nil {
+		return nil, err
+	}
+	s.deleteChunks(ctx, sess)
+	return af, nil
+}
+
+// Abort discards a pending session and its chunks.
+func (s *UploadService) Abort(ctx context.Context, userID uint, id string) error {
+	sess, err := s.Get(userID, id)
+	if err != nil {
+		return err
+	}
+	if sess.Status == models.UploadAssembling {
+		return ErrUploadBusy
+	}
+	if err := s.DB.Delete(sess).Error; err != nil {
+		return err
+	}
+	s.deleteChunks(ctx, sess)
+	return nil
+}
+
+func (s *UploadService) deleteChunks(ctx context.Context, sess *models.UploadSession) {
+	for _, key := range chunkKeys(sess) {
+		s.deleteChunk(ctx, key)
+	}
+}
+
+func (s *UploadService) deleteChunk(ctx context.Context, key string) {
+	if err := s.Store.Delete(ctx, key); err != nil {
+		log.Printf("[uploads] failed to delete chunk %s: %v", key, err)
+	}
+}
+
+// Cleanup periodically removes expired, unfinished sessions and their chunks.
+func (s *UploadService) Cleanup(every time.Duration) {
+	for {
+		time.Sleep(every)
+		var expired []models.UploadSession
+		if err := s.DB.Where("status <> ? AND expires_at < ?", models.UploadComplete, time.Now()).Limit(100).Find(&expired).Error; err != nil {
+			log.Printf("[uploads] cleanup query failed: %v", err)
+			continue
+		}
+		for i := range expired {
+			s.deleteChunks(context.Background(), &expired[i])
+			s.DB.Delete(&expired[i])
+		}
+	}
+}
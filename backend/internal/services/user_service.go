@@ -2,26 +2,37 @@ package services
 
 import (
 	"errors"
+	"log"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/uploadparty/app/internal/emails"
 	"github.com/uploadparty/app/internal/models"
 )
 
+// WelcomeMailer greets new users; EmailService implements it.
+type WelcomeMailer interface {
+	SendWelcomeEmail(to, name, locale string) error
+}
+
 type UserService struct {
 	DB        *gorm.DB
 	JWTSecret string
+	// Mailer, when set, sends new users a welcome email in the background.
+	Mailer WelcomeMailer
 }
 
 func NewUserService(db *gorm.DB, secret string) *UserService {
 	return &UserService{DB: db, JWTSecret: secret}
 }
 
-func (s *UserService) Register(email, username, password string) (*models.User, error) {
+// Register creates a legacy account. The username must be an allowed handle that no one holds
+// (see NormalizeHandle and changeHandle). locale is the language the user's emails are sent in
+// and may be empty.
+func (s *UserService) Register(email, username, password, locale string) (*models.User, error) {
 	email = strings.ToLower(strings.TrimSpace(email))
 	username = strings.ToLower(strings.TrimSpace(username))
 	if email == "" || username == "" || len(password) < 6 {
@@ -31,32 +42,38 @@ func (s *UserService) Register(email, username, password string) (*models.User,
 	if err != nil {
 		return nil, err
 	}
-	u := &models.User{Email: email, Username: username, PasswordHash: string(hash), DisplayName: username, Public: true}
-	if err := s.DB.Create(u).Error; err != nil {
+	locale, _ = emails.NormalizeLocale(locale)
+	u := &models.User{Email: email, Username: username, PasswordHash: string(hash), DisplayName: username, Public: true, Locale: locale}
+	if err := createWithHandle(s.DB, u, time.Now()); err != nil {
 		return nil, err
 	}
+	s.welcome(u)
 	return u, nil
 }
 
-func (s *UserService) Authenticate(emailOrUsername, password string) (string, *models.User, error) {
+func (s *UserService) welcome(u *models.User) {
+	if s.Mailer == nil {
+		return
+	}
+	id, to, name, locale := u.ID, u.Email, displayNameOf(u), u.Locale
+	go func() {
+		if err := s.Mailer.SendWelcomeEmail(to, name, locale); err != nil {
+			log.Printf("[users] welcome email to user %d: %v", id, err)
+		}
+	}()
+}
+
+// Authenticate checks a legacy username/email and password. Tokens come from SessionService.
+func (s *UserService) Authenticate(emailOrUsername, password string) (*models.User, error) {
 	var u models.User
 	q := s.DB.Where("email = ?", strings.ToLower(emailOrUsername)).Or("username = ?", strings.ToLower(emailOrUsername))
 	if err := q.First(&u).Error; err != nil {
-		return "", nil, err
+		return nil, err
 	}
 	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
-		return "", nil, errors.New("invalid credentials")
+		return nil, errors.New("invalid credentials")
 	}
-	claims := jwt.MapClaims{
-		"sub": u.ID,
-		"exp": time.Now().Add(24 * time.Hour).Unix(),
-	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := t.SignedString([]byte(s.JWTSecret))
-	if err != nil {
-		return "", nil, err
-	}
-	return signed, &u, nil
+	return &u, nil
 }
 
 func (s *UserService) FindPublicByHandle(handle string) (*models.User, error) {
@@ -67,8 +84,26 @@ func (s *UserService) FindPublicByHandle(handle string) (*models.User, error) {
 	return &u, nil
 }
 
-// SyncAuth0User creates or updates a user from Auth0 data
-func (s *UserService) SyncAuth0User(auth0ID, email, username, displayName, picture string) (*models.User, error) {
+// ResolvePublicHandle finds a public profile by its handle or by a handle its owner has
+// since changed; moved reports the latter, so callers can redirect to the current one.
+func (s *UserService) ResolvePublicHandle(handle string) (u *models.User, moved bool, err error) {
+	u, err = s.FindPublicByHandle(handle)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return u, false, err
+	}
+	var r models.HandleRedirect
+	if err := s.DB.Where("handle = ?", strings.ToLower(handle)).First(&r).Error; err != nil {
+		return nil, false, err
+	}
+	var owner models.User
+	if err := s.DB.Where("id = ? AND public = ?", r.UserID, true).First(&owner).Error; err != nil {
+		return nil, false, err
+	}
+	return &owner, true, nil
+}
+
+// SyncAuth0User creates or updates a user from Auth0 data. locale only applies to new users.
+func (s *UserService) SyncAuth0User(auth0ID, email, username, displayName, picture, locale string) (*models.User, error) {
 	email = strings.ToLower(strings.TrimSpace(email))
 	username = strings.ToLower(strings.TrimSpace(username))
 
@@ -91,20 +126,26 @@ func (s *UserService) SyncAuth0User(auth0ID, email, username, displayName, pictu
 			Picture:     picture,
 			Public:      true,
 		}
+		user.Locale, _ = emails.NormalizeLocale(locale)
 
-		if err := s.DB.Create(&user).Error; err != nil {
+		if err := createWithHandle(s.DB, &user, time.Now()); err != nil {
 			return nil, err
 		}
+		s.welcome(&user)
 		return &user, nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	// User exists, update profile info
-	updates := map[string]interface{}{
-		"email":        email,
-		"display_name": displayName,
-		"picture":      picture,
+	// User exists: keep the email current, but never undo the user's own profile edits.
+	// The Auth0 picture is only used until they upload an avatar, and the Auth0 name only
+	// while they have none.
+	updates := map[string]interface{}{"email": email}
+	if user.AvatarKey == "" {
+		updates["picture"] = picture
+	}
+	if user.DisplayName == "" {
+		updates["display_name"] = displayName
 	}
 
 	if err := s.DB.Model(&user).Updates(updates).Error; err != nil {
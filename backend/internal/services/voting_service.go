@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+)
+
+const (
+	ResultsCount    = "count"
+	ResultsBayesian = "bayesian"
+)
+
+var (
+	ErrVotingClosed    = errors.New("voting is not open for this challenge")
+	ErrVotingNotClosed = errors.New("results are available once voting closes")
+	ErrSelfVote        = errors.New("you cannot vote for your own entry")
+)
+
+type VotingService struct{ DB *gorm.DB }
+
+func NewVotingService(db *gorm.DB) *VotingService { return &VotingService{DB: db} }
+
+// BallotInput lists entry ids in order of preference. Single-vote challenges take exactly one.
+type BallotInput struct {
+	EntryIDs []uint `json:"entryIds" binding:"required,min=1"`
+}
+
+// EntryResult is one row of a challenge leaderboard.
+type EntryResult struct {
+	Rank         int       `json:"rank"`
+	EntryID      uint      `json:"entryId"`
+	ProjectID    uint      `json:"projectId"`
+	ProjectTitle string    `json:"projectTitle"`
+	UserID       uint      `json:"userId"`
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"displayName"`
+	Votes        int64     `json:"votes"`
+	Points       int64     `json:"points"`
+	Score        float64   `json:"score"`
+	CreatedAt    time.Time `json:"-"`
+}
+
+type ChallengeResults struct {
+	ChallengeID uint          `json:"challengeId"`
+	Mode        string        `json:"mode"`
+	Final       bool          `json:"final"`
+	Ballots     int64         `json:"ballots"`
+	Entries     []EntryResult `json:"entries"`
+}
+
+// ballotPoints is a Borda count: first choice earns BallotSize points, last earns 1.
+func ballotPoints(ch *models.Challenge, rank int) int {
+	if ch.VotingMode != models.VotingRanked {
+		return 1
+	}
+	return ch.BallotSize - rank + 1
+}
+
+// CastBallot replaces the user's ballot for a challenge in one transaction.
+func (s *VotingService) CastBallot(userID, challengeID uint, in BallotInput) ([]models.ChallengeVote, error) {
+	var votes []models.ChallengeVote
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var ch models.Challenge
+		if err := tx.First(&ch, challengeID).Error; err != nil {
+			return err
+		}
+		if ch.AnnouncedAt != nil || !VotingOpen(&ch, time.Now()) {
+			return ErrVotingClosed
+		}
+		if len(in.EntryIDs) == 0 || len(in.EntryIDs) > ch.BallotSize {
+			return fmt.Errorf("ballot must list between 1 and %d entries", ch.BallotSize)
+		}
+		seen := make(map[uint]bool, len(in.EntryIDs))
+		for _, id := range in.EntryIDs {
+			if seen[id] {
+				return fmt.Errorf("entry %d appears more than once", id)
+			}
+			seen[id] = true
+		}
+
+		var entries []models.ChallengeEntry
+		if err := tx.Where("challenge_id = ? AND id IN ?", ch.ID, in.EntryIDs).Find(&entries).Error; err != nil {
+			return err
+		}
+		owners := make(map[uint]uint, len(entries))
+		for _, e := range entries {
+			owners[e.ID] = e.UserID
+		}
+		for _, id := range in.EntryIDs {
+			owner, ok := owners[id]
+			if !ok {
+				return fmt.Errorf("entry %d is not part of this challenge", id)
+			}
+			if owner == userID {
+				return ErrSelfVote
+			}
+		}
+
+		if err := tx.Where("challenge_id = ? AND user_id = ?", ch.ID, userID).Delete(&models.ChallengeVote{}).Error; err != nil {
+			return err
+		}
+		votes = make([]models.ChallengeVote, 0, len(in.EntryIDs))
+		for i, id := range in.EntryIDs {
+			votes = append(votes, models.ChallengeVote{
+				ChallengeID: ch.ID,
+				UserID:      userID,
+				EntryID:     id,
+				Rank:        i + 1,
+				Points:      ballotPoints(&ch, i+1),
+			})
+		}
+		return tx.Create(&votes).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return votes, nil
+}
+
+// Ballot returns the user's current ballot, best-ranked first.
+func (s *VotingService) Ballot(userID, challengeID uint) ([]models.ChallengeVote, error) {
+	var votes []models.ChallengeVote
+	err := s.DB.Where("challenge_id = ? AND user_id = ?", challengeID, userID).Order("rank asc").Find(&votes).Error
+	return votes, err
+}
+
+// Retract removes the user's ballot while voting is still open.
+func (s *VotingService) Retract(userID, challengeID uint) error {
+	var ch models.Challenge
+	if err := s.DB.First(&ch, challengeID).Error; err != nil {
+		return err
+	}
+	if ch.AnnouncedAt != nil || !VotingOpen(&ch, time.Now()) {
+		return ErrVotingClosed
+	}
+	return s.DB.Where("challenge_id = ? AND user_id = ?", challengeID, userID).Delete(&models.ChallengeVote{}).Error
+}
+
+// Results tallies a challenge. Unless live is set, they are only available after voting ends.
+//
+// Count mode ranks by total points (votes in single-vote challenges). Bayesian mode ranks
+// by mean points per vote, shrunk toward the challenge-wide mean with a prior weight equal
+// to the average number of votes per entry, so a handful of enthusiastic votes cannot
+// outrank broad support. In single-vote challenges every vote is worth the same, so the
+// Bayesian score is flat and entries fall back to ordering by votes.
+func (s *VotingService) Results(challengeID uint, mode string, live bool) (*ChallengeResults, error) {
+	if mode == "" {
+		mode = ResultsCount
+	}
+	if mode != ResultsCount && mode != ResultsBayesian {
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+	var ch models.Challenge
+	if err := s.DB.First(&ch, challengeID).Error; err != nil {
+		return nil, err
+	}
+	final := ch.VotingEndsAt != nil && !time.Now().Before(*ch.VotingEndsAt)
+	if !live && !final {
+		return nil, ErrVotingNotClosed
+	}
+
+	// One grouped scan over the vote index; entries without votes still get a row.
+	var rows []EntryResult
+	err := s.DB.Table("challenge_entries AS e").
+		Select(`e.id AS entry_id, e.project_id, p.title AS project_title, e.user_id,
+			u.username, u.display_name, e.created_at,
+			COUNT(v.id) AS votes, COALESCE(SUM(v.points), 0) AS points`).
+		Joins("LEFT JOIN challenge_votes v ON v.entry_id = e.id AND v.challenge_id = e.challenge_id").
+		Joins("LEFT JOIN projects p ON p.id = e.project_id").
+		Joins("LEFT JOIN users u ON u.id = e.user_id").
+		Where("e.challenge_id = ?", ch.ID).
+		Group("e.id, e.project_id, p.title, e.user_id, u.username, u.display_name, e.created_at").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	var ballots int64
+	if err := s.DB.Model(&models.ChallengeVote{}).Where("challenge_id = ?", ch.ID).Distinct("user_id").Count(&ballots).Error; err != nil {
+		return nil, err
+	}
+
+	scoreEntries(rows, mode)
+	return &ChallengeResults{ChallengeID: ch.ID, Mode: mode, Final: final, Ballots: ballots, Entries: rows}, nil
+}
+
+// scoreEntries fills Score and Rank. Ties on score and votes share a rank (1, 2, 2, 4);
+// earlier entries are listed first within a tie.
+func scoreEntries(rows []EntryResult, mode string) {
+	var totalVotes, totalPoints int64
+	for _, r := range rows {
+		totalVotes += r.Votes
+		totalPoints += r.Points
+	}
+	var prior, mean float64
+	if len(rows) > 0 && totalVotes > 0 {
+		prior = float64(totalVotes) / float64(len(rows))
+		mean = float64(totalPoints) / float64(totalVotes)
+	}
+	for i := range rows {
+		r := &rows[i]
+		switch {
+		case mode == ResultsCount:
+			r.Score = float64(r.Points)
+		case prior+float64(r.Votes) > 0:
+			r.Score = (prior*mean + float64(r.Points)) / (prior + float64(r.Votes))
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.EntryID < b.EntryID
+	})
+	for i := range rows {
+		if i > 0 && rows[i].Score == rows[i-1].Score && rows[i].Votes == rows[i-1].Votes {
+			rows[i].Rank = rows[i-1].Rank
+		} else {
+			rows[i].Rank = i + 1
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+// GCSStore stores objects in a Google Cloud Storage bucket.
+type GCSStore struct {
+	client *gcs.Client
+	bucket string
+}
+
+// NewGCSStore creates a client using Application Default Credentials, which
+// honours GOOGLE_APPLICATION_CREDENTIALS when it is set.
+func NewGCSStore(ctx context.Context, bucket string) (*GCSStore, error) {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSStore{client: client, bucket: bucket}, nil
+}
+
+// Put aborts the upload by cancelling the writer's context when r fails, so a
+// partially streamed body is never committed as an object.
+func (s *GCSStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := s.client.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	n, err := io.Copy(w, r)
+	if err != nil {
+		cancel()
+		w.Close()
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *GCSStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	rd, err := s.client.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	err := s.client.Bucket(s.bucket).Object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+		return err
+	}
+	return nil
+}
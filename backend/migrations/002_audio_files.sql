@@ -0,0 +1,31 @@
+-- Audio uploads attached to projects. Bytes live in object storage; this table holds metadata.
+
+CREATE TABLE IF NOT EXISTS audio_files (
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    project_id    BIGINT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id       BIGINT       NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    storage_key   VARCHAR(500) NOT NULL,
+    filename      VARCHAR(255),
+    content_type  VARCHAR(100),
+    size_bytes    BIGINT       NOT NULL DEFAULT 0,
+    checksum      VARCHAR(64)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audio_files_storage_key ON audio_files (storage_key);
+CREATE INDEX IF NOT EXISTS idx_audio_files_project_id ON audio_files (project_id);
+CREATE INDEX IF NOT EXISTS idx_audio_files_user_id ON audio_files (user_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_audio_files_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_audio_files_set_updated_at
+        BEFORE UPDATE ON audio_files
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
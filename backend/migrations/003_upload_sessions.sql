@@ -0,0 +1,34 @@
+-- Resumable upload sessions. Chunks live in object storage under uploads/<id>/.
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id              VARCHAR(36) PRIMARY KEY,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    user_id         BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id      BIGINT      NOT NULL,
+
+    filename        VARCHAR(255),
+    total_bytes     BIGINT      NOT NULL,
+    received_bytes  BIGINT      NOT NULL DEFAULT 0,
+    chunk_count     INTEGER     NOT NULL DEFAULT 0,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',
+    expires_at      TIMESTAMPTZ NOT NULL,
+    audio_file_id   BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id ON upload_sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_project_id ON upload_sessions (project_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_status ON upload_sessions (status);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions (expires_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_upload_sessions_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_upload_sessions_set_updated_at
+        BEFORE UPDATE ON upload_sessions
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
@@ -0,0 +1,12 @@
+-- Server-side audio analysis results stored on each audio file.
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS analysis_status  VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS analysis_error   VARCHAR(255);
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS duration_seconds DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS sample_rate      INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS channels         INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS loudness_lufs    DOUBLE PRECISION;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS peaks            JSONB;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS analyzed_at      TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_audio_files_analysis_status ON audio_files (analysis_status);
@@ -0,0 +1,63 @@
+-- Community challenges and their entries.
+
+CREATE TABLE IF NOT EXISTS challenges (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    created_by_id    BIGINT,
+
+    slug             VARCHAR(80)  NOT NULL,
+    title            VARCHAR(200) NOT NULL,
+    description      TEXT,
+    rules            TEXT,
+    starts_at        TIMESTAMPTZ  NOT NULL,
+    ends_at          TIMESTAMPTZ  NOT NULL,
+
+    required_plugin  VARCHAR(120),
+    required_daw     VARCHAR(100),
+
+    locked_at        TIMESTAMPTZ,
+    announced_at     TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_challenges_slug ON challenges (slug);
+CREATE INDEX IF NOT EXISTS idx_challenges_starts_at ON challenges (starts_at);
+CREATE INDEX IF NOT EXISTS idx_challenges_ends_at ON challenges (ends_at);
+
+CREATE TABLE IF NOT EXISTS challenge_entries (
+    id             BIGSERIAL PRIMARY KEY,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    challenge_id   BIGINT  NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id        BIGINT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    project_id     BIGINT  NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    audio_file_id  BIGINT  REFERENCES audio_files(id) ON DELETE SET NULL,
+
+    locked         BOOLEAN NOT NULL DEFAULT FALSE,
+    placement      INTEGER
+);
+
+-- One entry per user per challenge
+CREATE UNIQUE INDEX IF NOT EXISTS idx_challenge_user ON challenge_entries (challenge_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_challenge_entries_project_id ON challenge_entries (project_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_challenges_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_challenges_set_updated_at
+        BEFORE UPDATE ON challenges
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_challenge_entries_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_challenge_entries_set_updated_at
+        BEFORE UPDATE ON challenge_entries
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
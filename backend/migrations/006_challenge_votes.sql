@@ -0,0 +1,24 @@
+-- Community voting on challenge entries.
+
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS voting_starts_at TIMESTAMPTZ;
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS voting_ends_at   TIMESTAMPTZ;
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS voting_mode      VARCHAR(20) NOT NULL DEFAULT 'single';
+ALTER TABLE challenges ADD COLUMN IF NOT EXISTS ballot_size      INTEGER     NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS challenge_votes (
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    challenge_id  BIGINT  NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id       BIGINT  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    entry_id      BIGINT  NOT NULL REFERENCES challenge_entries(id) ON DELETE CASCADE,
+
+    rank          INTEGER NOT NULL DEFAULT 1,
+    points        INTEGER NOT NULL DEFAULT 1
+);
+
+-- One ballot per user: each rank and each entry at most once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vote_rank ON challenge_votes (challenge_id, user_id, rank);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vote_entry ON challenge_votes (challenge_id, user_id, entry_id);
+-- Tallies group by entry within a challenge
+CREATE INDEX IF NOT EXISTS idx_vote_tally ON challenge_votes (challenge_id, entry_id) INCLUDE (points);
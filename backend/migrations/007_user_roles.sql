@@ -0,0 +1,4 @@
+-- Staff roles: user, moderator or admin.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+CREATE INDEX IF NOT EXISTS idx_users_role ON users (role);
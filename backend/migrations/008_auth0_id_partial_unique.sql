@@ -0,0 +1,9 @@
+-- Legacy (username/password) accounts have an empty auth0_id, so uniqueness must only
+-- apply to Auth0-linked rows for both kinds of account to coexist.
+
+-- 001_init.sql predates Auth0 logins; add the columns SyncAuth0User writes.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth0_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS picture VARCHAR(500) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_users_auth0_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_auth0_id ON users (auth0_id) WHERE auth0_id <> '';
@@ -0,0 +1,49 @@
+-- Refresh-token sessions and the access-token revocation list.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id                 BIGSERIAL PRIMARY KEY,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    user_id            BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id          VARCHAR(36) NOT NULL,
+
+    token_hash         VARCHAR(64) NOT NULL, -- sha256 hex of the refresh token; the token itself is never stored
+    replaced_by_id     BIGINT,
+
+    access_jti         VARCHAR(36),
+    access_expires_at  TIMESTAMPTZ,
+
+    expires_at         TIMESTAMPTZ NOT NULL,
+    revoked_at         TIMESTAMPTZ,
+    last_used_at       TIMESTAMPTZ,
+    user_agent         VARCHAR(255),
+    ip_address         VARCHAR(45)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_token_hash ON sessions (token_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions (family_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_access_jti ON sessions (access_jti);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti         VARCHAR(36) PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id     BIGINT,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_user_id ON revoked_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_sessions_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_sessions_set_updated_at
+        BEFORE UPDATE ON sessions
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
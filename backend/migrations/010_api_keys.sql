@@ -0,0 +1,31 @@
+-- Per-user API keys for the ingest routes. Only the sha256 of the key is stored.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    user_id       BIGINT       NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name          VARCHAR(100),
+    prefix        VARCHAR(16)  NOT NULL, -- "up_" + 8 chars, shown in lists and used for lookup
+    hash          VARCHAR(64)  NOT NULL,
+    scopes        JSONB        NOT NULL DEFAULT '[]',
+
+    expires_at    TIMESTAMPTZ,
+    last_used_at  TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_api_keys_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_api_keys_set_updated_at
+        BEFORE UPDATE ON api_keys
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
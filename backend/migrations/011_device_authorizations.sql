@@ -0,0 +1,36 @@
+-- Device login for the VST plugin (OAuth 2.0 device grant style).
+
+CREATE TABLE IF NOT EXISTS device_authorizations (
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    device_code_hash  VARCHAR(64)  NOT NULL, -- sha256 of the device code the plugin polls with
+    user_code         VARCHAR(9)   NOT NULL, -- XXXX-XXXX shown to the user
+    client_name       VARCHAR(100),
+    status            VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    ip_address        VARCHAR(45),
+
+    user_id           BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    api_key_id        BIGINT REFERENCES api_keys(id) ON DELETE SET NULL,
+
+    interval_seconds  INTEGER NOT NULL DEFAULT 5,
+    last_polled_at    TIMESTAMPTZ,
+    expires_at        TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_device_authorizations_device_code_hash ON device_authorizations (device_code_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_device_authorizations_user_code ON device_authorizations (user_code);
+CREATE INDEX IF NOT EXISTS idx_device_authorizations_user_id ON device_authorizations (user_id);
+CREATE INDEX IF NOT EXISTS idx_device_authorizations_expires_at ON device_authorizations (expires_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_device_authorizations_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_device_authorizations_set_updated_at
+        BEFORE UPDATE ON device_authorizations
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
@@ -0,0 +1,56 @@
+-- Heartbeat history for VST ingest and the work sessions inferred from it.
+
+CREATE TABLE IF NOT EXISTS project_sessions (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    project_id       BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id          BIGINT NOT NULL,
+
+    started_at       TIMESTAMPTZ NOT NULL,
+    ended_at         TIMESTAMPTZ NOT NULL,
+    duration_seconds INTEGER NOT NULL DEFAULT 0,
+    heartbeat_count  INTEGER NOT NULL DEFAULT 0,
+    daw              VARCHAR(100),
+    plugin_version   VARCHAR(50)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_sessions_span ON project_sessions (project_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_project_sessions_user_id ON project_sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS project_heartbeats (
+    id                      BIGSERIAL PRIMARY KEY,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    project_id              BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    session_id              BIGINT NOT NULL REFERENCES project_sessions(id) ON DELETE CASCADE,
+
+    at                      TIMESTAMPTZ NOT NULL,
+    daw                     VARCHAR(100),
+    plugin_version          VARCHAR(50),
+    client_duration_seconds INTEGER NOT NULL DEFAULT 0,
+    metadata                JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_heartbeats_at ON project_heartbeats (project_id, at);
+CREATE INDEX IF NOT EXISTS idx_project_heartbeats_session_id ON project_heartbeats (session_id);
+
+-- Durations used to be reported by the client. Keep existing totals as one session ending at
+-- the last heartbeat so the server-side sum starts from them.
+INSERT INTO project_sessions (project_id, user_id, started_at, ended_at, duration_seconds, heartbeat_count, daw, plugin_version)
+SELECT p.id, p.user_id, p.updated_at - make_interval(secs => p.duration_seconds), p.updated_at, p.duration_seconds, 0, p.daw, p.plugin_version
+FROM projects p
+WHERE p.duration_seconds > 0
+  AND NOT EXISTS (SELECT 1 FROM project_sessions s WHERE s.project_id = p.id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_project_sessions_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_project_sessions_set_updated_at
+        BEFORE UPDATE ON project_sessions
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
@@ -0,0 +1,27 @@
+-- Append-only plugin change log per project, and soft removal of plugins dropped by a sync.
+
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS removed_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS plugin_events (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    project_id       BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    plugin_id        BIGINT NOT NULL REFERENCES plugins(id) ON DELETE CASCADE,
+
+    kind             VARCHAR(20)  NOT NULL, -- added | removed | version_changed
+    name             VARCHAR(120) NOT NULL,
+    vendor           VARCHAR(120),
+    format           VARCHAR(20),
+    version          VARCHAR(50),
+    previous_version VARCHAR(50)
+);
+
+CREATE INDEX IF NOT EXISTS idx_plugin_events_project ON plugin_events (project_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_plugin_events_plugin_id ON plugin_events (plugin_id);
+
+-- Plugins recorded before the log existed start with an "added" entry at their creation time.
+INSERT INTO plugin_events (created_at, project_id, plugin_id, kind, name, vendor, format, version)
+SELECT pl.created_at, pl.project_id, pl.id, 'added', pl.name, pl.vendor, pl.format, pl.version
+FROM plugins pl
+WHERE NOT EXISTS (SELECT 1 FROM plugin_events e WHERE e.plugin_id = pl.id);
@@ -0,0 +1,39 @@
+-- Canonical plugin catalog. Existing plugin rows are linked by the server at startup, since
+-- name normalization lives in Go.
+
+CREATE TABLE IF NOT EXISTS plugin_catalogs (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    slug        VARCHAR(140) NOT NULL,
+    name        VARCHAR(120) NOT NULL,
+    vendor      VARCHAR(120)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plugin_catalogs_slug ON plugin_catalogs (slug);
+
+CREATE TABLE IF NOT EXISTS plugin_aliases (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    catalog_id  BIGINT NOT NULL REFERENCES plugin_catalogs(id) ON DELETE CASCADE,
+    key         VARCHAR(140) NOT NULL -- normalized name, e.g. "serum" for "Serum x64"
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plugin_aliases_key ON plugin_aliases (key);
+CREATE INDEX IF NOT EXISTS idx_plugin_aliases_catalog_id ON plugin_aliases (catalog_id);
+
+ALTER TABLE plugins ADD COLUMN IF NOT EXISTS catalog_id BIGINT REFERENCES plugin_catalogs(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_plugins_catalog_id ON plugins (catalog_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger WHERE tgname = 'tr_plugin_catalogs_set_updated_at'
+    ) THEN
+        CREATE TRIGGER tr_plugin_catalogs_set_updated_at
+        BEFORE UPDATE ON plugin_catalogs
+        FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+    END IF;
+END$$;
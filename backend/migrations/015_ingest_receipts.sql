@@ -0,0 +1,15 @@
+-- Idempotency keys of batch ingest items, so re-sent offline queues apply once.
+
+CREATE TABLE IF NOT EXISTS ingest_receipts (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    user_id     BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key         VARCHAR(100) NOT NULL,
+    type        VARCHAR(20)  NOT NULL, -- project | plugin | complete
+    project_id  BIGINT,
+    plugin_id   BIGINT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingest_receipts_key ON ingest_receipts (user_id, key);
+CREATE INDEX IF NOT EXISTS idx_ingest_receipts_created_at ON ingest_receipts (created_at);
@@ -0,0 +1,14 @@
+-- Stored responses for requests sent with an Idempotency-Key header.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key           VARCHAR(64) PRIMARY KEY, -- sha256 of caller scope + client key
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMPTZ NOT NULL,
+
+    fingerprint   VARCHAR(64) NOT NULL,
+    status        INTEGER NOT NULL DEFAULT 0, -- 0 while the first request is running
+    content_type  VARCHAR(100),
+    body          BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
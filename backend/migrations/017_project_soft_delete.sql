@@ -0,0 +1,5 @@
+-- Projects deleted by their owner go to a trash and can be restored.
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_projects_deleted_at ON projects (deleted_at);
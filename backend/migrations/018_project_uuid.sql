@@ -0,0 +1,15 @@
+-- Plugins key projects on a UUID from the DAW session instead of the title.
+-- Existing projects get a server UUID; the first client UUID sent with the same title adopts them.
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto; -- gen_random_uuid() before PostgreSQL 13
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS uuid VARCHAR(36) NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS uuid_from_client BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE projects SET uuid = gen_random_uuid()::text WHERE uuid = '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_user_uuid ON projects (user_id, uuid) WHERE uuid <> '';
+
+-- Titles are no longer a key: two sessions may share one, and a trashed project keeps its row.
+-- Lookups by title use the plain idx_projects_user_title from 019.
+DROP INDEX IF EXISTS ux_projects_user_title;
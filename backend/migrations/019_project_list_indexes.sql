@@ -0,0 +1,6 @@
+-- Keyset pagination of project lists: one index per sort, all scoped to the owner.
+
+CREATE INDEX IF NOT EXISTS idx_projects_user_created ON projects (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_projects_user_updated ON projects (user_id, updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_projects_user_title ON projects (user_id, title, id);
+CREATE INDEX IF NOT EXISTS idx_projects_user_duration ON projects (user_id, duration_seconds DESC, id DESC);
@@ -0,0 +1,24 @@
+-- Full-text search for /search. The 'simple' configuration keeps names, handles and tags
+-- as typed (no stemming), and prefix queries make it work while typing.
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+        setweight(jsonb_to_tsvector('simple', COALESCE(metadata -> 'tags', '[]'::jsonb), '["string"]'), 'B')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_projects_search ON projects USING GIN (search_vector);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(username, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(display_name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(bio, '')), 'C')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN (search_vector);
+
+ALTER TABLE plugin_catalogs ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(vendor, '')), 'B')
+    ) STORED;
+CREATE INDEX IF NOT EXISTS idx_plugin_catalogs_search ON plugin_catalogs USING GIN (search_vector);
@@ -0,0 +1,17 @@
+-- Editable profiles: social links, uploaded avatars, and redirects from changed handles.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS link_sound_cloud VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS link_you_tube VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS link_instagram VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(200) NOT NULL DEFAULT '';
+
+-- An old handle keeps pointing at its owner; others may claim it 90 days after the change.
+CREATE TABLE IF NOT EXISTS handle_redirects (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    handle VARCHAR(50) NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_handle_redirects_handle ON handle_redirects (handle);
+CREATE INDEX IF NOT EXISTS idx_handle_redirects_user_id ON handle_redirects (user_id);
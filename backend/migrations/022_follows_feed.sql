@@ -0,0 +1,41 @@
+-- Follow graph and the activities feeds are assembled from when read.
+
+CREATE TABLE IF NOT EXISTS follows (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    follower_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_follows_pair ON follows (follower_id, followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
+
+CREATE TABLE IF NOT EXISTS activities (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL,
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    challenge_id BIGINT REFERENCES challenges(id) ON DELETE CASCADE
+);
+
+-- The feed reads each followed user's newest activities through this index.
+CREATE INDEX IF NOT EXISTS idx_activities_user_created ON activities (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_activities_project_id ON activities (project_id);
+CREATE INDEX IF NOT EXISTS idx_activities_challenge_id ON activities (challenge_id);
+
+-- Projects that were already public or complete become activities, so feeds do not start empty.
+INSERT INTO activities (created_at, user_id, kind, project_id)
+SELECT p.created_at, p.user_id, 'project_published', p.id FROM projects p
+WHERE p.public AND NOT EXISTS (
+    SELECT 1 FROM activities a WHERE a.project_id = p.id AND a.kind = 'project_published');
+
+INSERT INTO activities (created_at, user_id, kind, project_id)
+SELECT p.completed_at, p.user_id, 'project_completed', p.id FROM projects p
+WHERE p.status = 'complete' AND p.completed_at IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM activities a WHERE a.project_id = p.id AND a.kind = 'project_completed');
+
+INSERT INTO activities (created_at, user_id, kind, project_id, challenge_id)
+SELECT e.created_at, e.user_id, 'challenge_entered', e.project_id, e.challenge_id FROM challenge_entries e
+WHERE NOT EXISTS (
+    SELECT 1 FROM activities a WHERE a.challenge_id = e.challenge_id AND a.user_id = e.user_id AND a.kind = 'challenge_entered');
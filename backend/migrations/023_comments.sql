@@ -0,0 +1,24 @@
+-- Threaded comments on public projects, optionally pinned to a moment in the audio.
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    body VARCHAR(2000) NOT NULL,
+    timestamp_seconds DOUBLE PRECISION,
+    audio_file_id BIGINT REFERENCES audio_files(id) ON DELETE SET NULL,
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    removed_at TIMESTAMPTZ,
+    removed_by_id BIGINT,
+    removed_reason VARCHAR(200) NOT NULL DEFAULT ''
+);
+
+-- Threads are listed per project by time; replies are loaded by parent.
+CREATE INDEX IF NOT EXISTS idx_comments_project_created ON comments (project_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
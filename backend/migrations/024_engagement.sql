@@ -0,0 +1,36 @@
+-- Likes, play counts and profile views. Plays and views are deduplicated per viewer and time
+-- window in engagement_hits; every counter change is queued in counter_deltas and folded into
+-- the counter columns by the background aggregator.
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS like_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS play_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_views BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS project_likes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_likes_pair ON project_likes (project_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_project_likes_user_id ON project_likes (user_id);
+
+CREATE TABLE IF NOT EXISTS engagement_hits (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    kind VARCHAR(20) NOT NULL,
+    target_id BIGINT NOT NULL,
+    viewer_key VARCHAR(40) NOT NULL, -- u:<user id> or ip:<salted hash>
+    bucket BIGINT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_engagement_hits_key ON engagement_hits (kind, target_id, viewer_key, bucket);
+CREATE INDEX IF NOT EXISTS idx_engagement_hits_created_at ON engagement_hits (created_at);
+
+CREATE TABLE IF NOT EXISTS counter_deltas (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    target_id BIGINT NOT NULL,
+    delta INTEGER NOT NULL
+);
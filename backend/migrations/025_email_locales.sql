@@ -0,0 +1,5 @@
+-- Language of each person's emails: a lowercase tag such as "es" or "pt-br", empty for the
+-- default. RSVPs take it from Accept-Language; users may also change it on their profile.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE rsvps ADD COLUMN IF NOT EXISTS locale VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Each chunk attempt is written to its own blob; the attempt that wins the offset records its
+-- key here, so a losing concurrent append cannot overwrite accepted bytes.
+
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS chunk_keys JSONB NOT NULL DEFAULT '[]';
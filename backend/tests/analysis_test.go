@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/audio"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// sineWAV renders a 16-bit PCM sine with the same signal on every channel.
+func sineWAV(sampleRate, channels int, seconds, freq, amplitude float64) []byte {
+	frames := int(seconds * float64(sampleRate))
+	b := bytes.NewBuffer(wavBytes(sampleRate, channels, 0))
+	out := b.Bytes()
+	dataLen := frames * channels * 2
+	binary.LittleEndian.PutUint32(out[4:8], uint32(36+dataLen))
+	binary.LittleEndian.PutUint32(out[40:44], uint32(dataLen))
+	for i := 0; i < frames; i++ {
+		v := int16(math.Round(amplitude * 32767 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))))
+		for c := 0; c < channels; c++ {
+			binary.Write(b, binary.LittleEndian, v)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestAnalyze_WAV(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		amp      float64
+		wantLUFS float64
+	}{
+		// BS.1770: a 0 dBFS 997 Hz sine on one channel reads -3.01 LUFS
+		{name: "mono full scale", rate: 48000, channels: 1, amp: 1, wantLUFS: -3.01},
+		{name: "stereo half scale", rate: 48000, channels: 2, amp: 0.5, wantLUFS: -6.02},
+		{name: "mono 44.1k half scale", rate: 44100, channels: 1, amp: 0.5, wantLUFS: -9.03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := audio.Analyze(bytes.NewReader(sineWAV(tt.rate, tt.channels, 3, 997, tt.amp)), "audio/wav")
+			require.NoError(t, err)
+			assert.Equal(t, tt.rate, a.SampleRate)
+			assert.Equal(t, tt.channels, a.Channels)
+			assert.InDelta(t, 3.0, a.DurationSeconds, 1e-9)
+			require.NotNil(t, a.LoudnessLUFS)
+			assert.InDelta(t, tt.wantLUFS, *a.LoudnessLUFS, 0.1)
+			assert.Len(t, a.Peaks, audio.DefaultPeakCount)
+			assert.InDelta(t, tt.amp, a.Peaks[len(a.Peaks)/2], 0.01)
+		})
+	}
+
+	t.Run("silence has no loudness", func(t *testing.T) {
+		a, err := audio.Analyze(bytes.NewReader(wavBytes(48000, 2, 48000)), "audio/wav")
+		require.NoError(t, err)
+		assert.Nil(t, a.LoudnessLUFS)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := audio.Analyze(bytes.NewReader([]byte("OggS")), "audio/ogg")
+		assert.ErrorIs(t, err, audio.ErrUnsupportedFormat)
+	})
+}
+
+func TestAnalysisService_Process(t *testing.T) {
+	db := setupMigratedDB(t)
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	user := models.User{Auth0ID: "test|a", Email: "a@example.com", Username: "a"}
+	require.NoError(t, db.Create(&user).Error)
+	proj := models.Project{UserID: user.ID, Title: "beat"}
+	require.NoError(t, db.Create(&proj).Error)
+
+	audioSvc := services.NewAudioService(db, store, 10<<20)
+	af, err := audioSvc.Upload(ctx, user.ID, proj.ID, "tone.wav", bytes.NewReader(sineWAV(44100, 2, 2, 440, 0.25)))
+	require.NoError(t, err)
+	assert.Equal(t, models.AnalysisPending, af.AnalysisStatus)
+
+	svc := services.NewAnalysisService(db, store)
+	require.NoError(t, svc.Process(ctx, af.ID))
+
+	var got models.AudioFile
+	require.NoError(t, db.First(&got, af.ID).Error)
+	assert.Equal(t, models.AnalysisDone, got.AnalysisStatus)
+	assert.Equal(t, 44100, got.SampleRate)
+	assert.Equal(t, 2, got.Channels)
+	assert.InDelta(t, 2.0, got.DurationSeconds, 1e-6)
+	require.NotNil(t, got.LoudnessLUFS)
+	var peaks []float64
+	require.NoError(t, json.Unmarshal(got.Peaks, &peaks))
+	assert.NotEmpty(t, peaks)
+
+	// A second run is a no-op because the row is no longer pending.
+	require.NoError(t, svc.Process(ctx, af.ID))
+}
+
+// stereoFLAC encodes a few frames of 16-bit stereo ramp with verbatim subframes.
+func stereoFLAC(t *testing.T, frames, blockSize int) []byte {
+	var b bytes.Buffer
+	info := &meta.StreamInfo{BlockSizeMin: uint16(blockSize), BlockSizeMax: uint16(blockSize), SampleRate: 44100, NChannels: 2, BitsPerSample: 16}
+	enc, err := flac.NewEncoder(&b, info)
+	require.NoError(t, err)
+	for i := 0; i < frames; i++ {
+		subs := make([]*frame.Subframe, 2)
+		for c := range subs {
+			samples := make([]int32, blockSize)
+			for j := range samples {
+				samples[j] = int32(j * 16)
+			}
+			subs[c] = &frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: samples, NSamples: blockSize}
+		}
+		f := &frame.Frame{
+			Header:    frame.Header{HasFixedBlockSize: true, BlockSize: uint16(blockSize), SampleRate: 44100, Channels: frame.ChannelsLR, BitsPerSample: 16},
+			Subframes: subs,
+		}
+		require.NoError(t, enc.WriteFrame(f))
+	}
+	require.NoError(t, enc.Close())
+	return b.Bytes()
+}
+
+func TestAnalyze_MalformedInput(t *testing.T) {
+	good := stereoFLAC(t, 4, 4096)
+	a, err := audio.Analyze(bytes.NewReader(good), "audio/flac")
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.Channels)
+
+	// STREAMINFO claims three channels while every frame carries two. Bits 3..1 of byte 20
+	// (after "fLaC", the block header and 10 bytes of sizes and rate) hold channels-1.
+	threeChans := bytes.Clone(good)
+	threeChans[20] = threeChans[20]&^0x0E | 2<<1
+	manyChans := bytes.Clone(good)
+	manyChans[20] |= 0x0E // eight channels is fine, but no frame has them
+
+	hugeFmt := wavBytes(44100, 2, 10)
+	binary.LittleEndian.PutUint32(hugeFmt[16:20], 0xFFFFFFF0)
+	tooManyChans := wavBytes(44100, 2, 10)
+	binary.LittleEndian.PutUint16(tooManyChans[22:24], 64)
+	binary.LittleEndian.PutUint16(tooManyChans[32:34], 128)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"flac channel mismatch", threeChans, "audio/flac"},
+		{"flac with more channels than frames", manyChans, "audio/flac"},
+		{"truncated flac", good[:len(good)/2], "audio/flac"},
+		{"flac header only", good[:42], "audio/flac"},
+		{"wav fmt chunk size past the file", hugeFmt, "audio/wav"},
+		{"wav with too many channels", tooManyChans, "audio/wav"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			assert.NotPanics(t, func() { _, err = audio.Analyze(bytes.NewReader(tt.data), tt.contentType) })
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAnalysisService_MalformedUploadFails(t *testing.T) {
+	db := setupMigratedDB(t)
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+	user := models.User{Auth0ID: "test|a", Email: "a@example.com", Username: "a"}
+	require.NoError(t, db.Create(&user).Error)
+	proj := models.Project{UserID: user.ID, Title: "beat"}
+	require.NoError(t, db.Create(&proj).Error)
+
+	bad := stereoFLAC(t, 2, 1024)
+	bad[20] = bad[20]&^0x0E | 2<<1
+	af, err := services.NewAudioService(db, store, 10<<20).Upload(ctx, user.ID, proj.ID, "bad.flac", bytes.NewReader(bad))
+	require.NoError(t, err)
+
+	svc := services.NewAnalysisService(db, store)
+	assert.Error(t, svc.Process(ctx, af.ID))
+	var got models.AudioFile
+	require.NoError(t, db.First(&got, af.ID).Error)
+	assert.Equal(t, models.AnalysisFailed, got.AnalysisStatus)
+	assert.NotEmpty(t, got.AnalysisError)
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestAPIKeys_IngestWithScopedKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|keys", Email: "keys@example.com", Username: "keys"}
+	require.NoError(t, db.Create(&u).Error)
+	sessions := services.NewSessionService(db, "secret", time.Hour, time.Hour)
+	pair, err := sessions.Issue(u.ID, services.ClientInfo{})
+	require.NoError(t, err)
+
+	authn := middlewares.NewAuthenticator(db, middlewares.NewJWT("secret"), nil)
+	keyCtl := controllers.NewAPIKeyController(db)
+	projCtl := controllers.NewProjectController(db, nil)
+	pluginCtl := controllers.NewPluginController(db)
+	r := gin.New()
+	ingest := r.Group("/ingest", authn.RequireUserOrAPIKey())
+	ingest.POST("/projects", middlewares.RequireScope(models.ScopeProjectsWrite), projCtl.Upsert)
+	ingest.POST("/projects/:id/plugins", middlewares.RequireScope(models.ScopePluginsWrite), pluginCtl.UpsertForProject)
+	app := r.Group("/app", authn.RequireUser())
+	app.POST("/api-keys", keyCtl.Create)
+	app.GET("/api-keys", keyCtl.List)
+	app.DELETE("/api-keys/:id", keyCtl.Revoke)
+
+	call := func(method, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header = header.Clone()
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	bearer := func(tok string) http.Header { return http.Header{"Authorization": {"Bearer " + tok}} }
+	createKey := func(body gin.H) (string, models.APIKey) {
+		w := call(http.MethodPost, "/app/api-keys", bearer(pair.AccessToken), body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp struct {
+			Key    string        `json:"key"`
+			APIKey models.APIKey `json:"apiKey"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Key, resp.APIKey
+	}
+
+	full, fullRec := createKey(gin.H{"name": "Studio PC"})
+	assert.True(t, strings.HasPrefix(full, fullRec.Prefix+"_"))
+	tampered := full[:len(full)-1] + "x"
+	if strings.HasSuffix(full, "x") {
+		tampered = full[:len(full)-1] + "y"
+	}
+	assert.ElementsMatch(t, []string{models.ScopeProjectsWrite, models.ScopePluginsWrite}, fullRec.Scopes)
+	projectsOnly, _ := createKey(gin.H{"name": "Heartbeat only", "scopes": []string{"projects:write"}})
+
+	w := call(http.MethodPost, "/app/api-keys", bearer(pair.AccessToken), gin.H{"name": "bad", "scopes": []string{"admin"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	cases := []struct {
+		name   string
+		header http.Header
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"bearer key upserts project", bearer(full), "/ingest/projects", gin.H{"title": "Night Drive"}, http.StatusOK},
+		{"x-api-key header", http.Header{"X-Api-Key": {full}}, "/ingest/projects", gin.H{"title": "Night Drive"}, http.StatusOK},
+		{"key with plugin scope", bearer(full), "/ingest/projects/1/plugins", gin.H{"name": "Serum"}, http.StatusOK},
+		{"key without plugin scope", bearer(projectsOnly), "/ingest/projects/1/plugins", gin.H{"name": "Serum"}, http.StatusForbidden},
+		{"user token has every scope", bearer(pair.AccessToken), "/ingest/projects/1/plugins", gin.H{"name": "Vital"}, http.StatusOK},
+		{"tampered secret", bearer(tampered), "/ingest/projects", gin.H{"title": "x"}, http.StatusUnauthorized},
+		{"keys cannot reach app routes", bearer(full), "/app/api-keys", nil, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := http.MethodPost
+			if tc.path == "/app/api-keys" {
+				method = http.MethodGet
+			}
+			w := call(method, tc.path, tc.header, tc.body)
+			assert.Equal(t, tc.want, w.Code, w.Body.String())
+		})
+	}
+
+	var stored models.APIKey
+	require.NoError(t, db.First(&stored, fullRec.ID).Error)
+	assert.NotNil(t, stored.LastUsedAt)
+	assert.NotContains(t, stored.Hash, full)
+	var projects int64
+	db.Model(&models.Project{}).Where("user_id = ?", u.ID).Count(&projects)
+	assert.EqualValues(t, 1, projects, "both key transports upserted the same project")
+
+	assert.Equal(t, http.StatusNoContent, call(http.MethodDelete, "/app/api-keys/"+itoa(fullRec.ID), bearer(pair.AccessToken), nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/ingest/projects", bearer(full), gin.H{"title": "x"}).Code)
+	w = call(http.MethodGet, "/app/api-keys", bearer(pair.AccessToken), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), full)
+	assert.Contains(t, w.Body.String(), `"revokedAt"`)
+}
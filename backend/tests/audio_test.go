@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+// setupMigratedDB opens a file-backed SQLite database so every pooled connection sees the same schema.
+func setupMigratedDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.RSVP{}, &models.User{}, &models.Project{}, &models.Plugin{}, &models.AudioFile{}, &models.UploadSession{}, &models.Challenge{}, &models.ChallengeEntry{}, &models.ChallengeVote{}, &models.Session{}, &models.RevokedToken{}, &models.APIKey{}, &models.DeviceAuthorization{}, &models.ProjectSession{}, &models.ProjectHeartbeat{}, &models.PluginEvent{}, &models.PluginCatalog{}, &models.PluginAlias{}, &models.IngestReceipt{}, &models.IdempotencyKey{}, &models.HandleRedirect{}, &models.Follow{}, &models.Activity{}, &models.Comment{}, &models.ProjectLike{}, &models.EngagementHit{}, &models.CounterDelta{}))
+	return db
+}
+
+// asUser stands in for the JWT middleware in controller tests.
+func asUser(id uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", id)
+		c.Next()
+	}
+}
+
+// wavBytes builds a minimal 16-bit PCM WAV file with the given number of silent frames.
+func wavBytes(sampleRate, channels, frames int) []byte {
+	dataLen := frames * channels * 2
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+dataLen))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(channels))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate*channels*2))
+	binary.Write(&b, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&b, binary.LittleEndian, uint16(16))
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(dataLen))
+	b.Write(make([]byte, dataLen))
+	return b.Bytes()
+}
+
+func itoa(id uint) string { return strconv.FormatUint(uint64(id), 10) }
+
+func multipartBody(t *testing.T, field, filename string, content []byte) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile(field, filename)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return &buf, w.FormDataContentType()
+}
+
+func TestLocalStore_KeysStayInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	store, err := storage.NewLocalStore(root)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = store.Put(ctx, "../../escape.txt", bytes.NewReader([]byte("x")), "text/plain")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(root, "escape.txt"))
+
+	rc, err := store.Open(ctx, "escape.txt")
+	require.NoError(t, err)
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "x", string(b))
+
+	require.NoError(t, store.Delete(ctx, "escape.txt"))
+	require.NoError(t, store.Delete(ctx, "escape.txt"))
+	_, err = store.Open(ctx, "escape.txt")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestAudioController_Upload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	owner := models.User{Auth0ID: "test|a", Email: "a@example.com", Username: "a"}
+	other := models.User{Auth0ID: "test|b", Email: "b@example.com", Username: "b"}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&other).Error)
+	proj := models.Project{UserID: owner.ID, Title: "beat"}
+	require.NoError(t, db.Create(&proj).Error)
+
+	ctl := controllers.NewAudioController(db, store, 64<<10, nil, nil)
+	newRouter := func(uid uint) *gin.Engine {
+		r := gin.New()
+		r.Use(asUser(uid))
+		r.POST("/projects/:id/audio", ctl.Upload)
+		return r
+	}
+
+	tests := []struct {
+		name           string
+		uid            uint
+		content        []byte
+		expectedStatus int
+	}{
+		{name: "valid wav", uid: owner.ID, content: wavBytes(44100, 2, 1000), expectedStatus: http.StatusCreated},
+		{name: "not audio", uid: owner.ID, content: []byte("<html>nope</html>"), expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "too large", uid: owner.ID, content: wavBytes(44100, 2, 20000), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "not owner", uid: other.ID, content: wavBytes(44100, 2, 10), expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ctype := multipartBody(t, "file", "beat.wav", tt.content)
+			req, _ := http.NewRequest("POST", "/projects/"+itoa(proj.ID)+"/audio", body)
+			req.Header.Set("Content-Type", ctype)
+			w := httptest.NewRecorder()
+			newRouter(tt.uid).ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	var files []models.AudioFile
+	require.NoError(t, db.Find(&files).Error)
+	require.Len(t, files, 1)
+	assert.Equal(t, "audio/wav", files[0].ContentType)
+	assert.Equal(t, "beat.wav", files[0].Filename)
+	assert.EqualValues(t, len(wavBytes(44100, 2, 1000)), files[0].SizeBytes)
+
+	rc, err := store.Open(context.Background(), files[0].StorageKey)
+	require.NoError(t, err)
+	rc.Close()
+}
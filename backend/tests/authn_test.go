@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+// fakeAuth0 serves a JWKS for a freshly generated RSA key and signs tokens with it.
+func fakeAuth0(t *testing.T) (*httptest.Server, func(claims jwt.MapClaims) string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test-key",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(srv.Close)
+	sign := func(claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "test-key"
+		s, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+	return srv, sign
+}
+
+func TestAuthenticator_AcceptsLegacyAndAuth0Tokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	users := services.NewUserService(db, "secret")
+
+	// Two legacy accounts share the empty auth0_id without tripping its unique index.
+	_, err := users.Register("legacy@example.com", "legacy", "password1", "")
+	require.NoError(t, err)
+	_, err = users.Register("other@example.com", "other", "password1", "")
+	require.NoError(t, err)
+	legacy, err := users.Authenticate("legacy", "password1")
+	require.NoError(t, err)
+	pair, err := services.NewSessionService(db, "secret", time.Hour, time.Hour).Issue(legacy.ID, services.ClientInfo{})
+	require.NoError(t, err)
+	legacyToken := pair.AccessToken
+	social, err := users.SyncAuth0User("google-oauth2|42", "social@example.com", "social", "Social", "", "")
+	require.NoError(t, err)
+
+	srv, sign := fakeAuth0(t)
+	authn := middlewares.NewAuthenticator(db, middlewares.NewJWT("secret"), middlewares.NewAuth0(srv.URL, "https://api.uploadparty.test"))
+	r := gin.New()
+	r.GET("/whoami", authn.RequireUser(), func(c *gin.Context) {
+		p, _ := middlewares.CurrentPrincipal(c)
+		c.JSON(http.StatusOK, gin.H{"userId": c.GetUint("user_id"), "method": p.Method, "auth0Id": c.GetString("auth0_id"), "verifiedEmail": p.VerifiedEmail})
+	})
+
+	auth0Claims := func(sub string, aud interface{}) jwt.MapClaims {
+		return jwt.MapClaims{"sub": sub, "iss": srv.URL + "/", "aud": aud, "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": 1, "exp": time.Now().Add(time.Hour).Unix()}).SignedString([]byte("wrong"))
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		token  string
+		status int
+		userID uint
+		method string
+	}{
+		{"legacy jwt", legacyToken, http.StatusOK, legacy.ID, middlewares.AuthMethodJWT},
+		{"auth0 token", sign(auth0Claims(social.Auth0ID, "https://api.uploadparty.test")), http.StatusOK, social.ID, middlewares.AuthMethodAuth0},
+		{"auth0 audience array", sign(auth0Claims(social.Auth0ID, []string{"https://api.uploadparty.test", srv.URL + "/userinfo"})), http.StatusOK, social.ID, middlewares.AuthMethodAuth0},
+		{"auth0 wrong audience", sign(auth0Claims(social.Auth0ID, "https://elsewhere")), http.StatusUnauthorized, 0, ""},
+		{"auth0 unsynced user", sign(auth0Claims("github|7", "https://api.uploadparty.test")), http.StatusForbidden, 0, ""},
+		{"forged legacy jwt", forged, http.StatusUnauthorized, 0, ""},
+		{"garbage", "not-a-token", http.StatusUnauthorized, 0, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+			if tc.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				UserID  uint   `json:"userId"`
+				Method  string `json:"method"`
+				Auth0ID string `json:"auth0Id"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.userID, body.UserID)
+			assert.Equal(t, tc.method, body.Method)
+			if tc.method == middlewares.AuthMethodAuth0 {
+				assert.Equal(t, social.Auth0ID, body.Auth0ID)
+			}
+		})
+	}
+
+	// Only an email Auth0 marks verified is trusted.
+	for _, tc := range []struct {
+		verified interface{}
+		want     string
+	}{{true, "social@example.com"}, {false, ""}, {"true", ""}, {nil, ""}} {
+		claims := auth0Claims(social.Auth0ID, "https://api.uploadparty.test")
+		claims["email"] = "Social@example.com"
+		if tc.verified != nil {
+			claims["email_verified"] = tc.verified
+		}
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		req.Header.Set("Authorization", "Bearer "+sign(claims))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			VerifiedEmail string `json:"verifiedEmail"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, tc.want, body.VerifiedEmail, "email_verified=%v", tc.verified)
+	}
+
+	// Without Auth0 configured, RS256 tokens are refused rather than misparsed.
+	noAuth0 := middlewares.NewAuthenticator(db, middlewares.NewJWT("secret"), nil)
+	r2 := gin.New()
+	r2.GET("/whoami", noAuth0.RequireUser(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(auth0Claims(social.Auth0ID, "https://api.uploadparty.test")))
+	w := httptest.NewRecorder()
+	r2.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Where("auth0_id = ''").Count(&count).Error)
+	assert.EqualValues(t, 2, count)
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+// seedProducer creates a user with one public project using the given DAW and plugin.
+func seedProducer(t *testing.T, db *gorm.DB, handle, daw, plugin string) (models.User, models.Project) {
+	u := models.User{Auth0ID: "test|" + handle, Email: handle + "@example.com", Username: handle, Public: true}
+	require.NoError(t, db.Create(&u).Error)
+	p := models.Project{UserID: u.ID, Title: handle + " beat", DAW: daw, Public: true}
+	require.NoError(t, db.Create(&p).Error)
+	if plugin != "" {
+		require.NoError(t, db.Create(&models.Plugin{ProjectID: p.ID, Name: plugin}).Error)
+	}
+	return u, p
+}
+
+func strPtr(s string) *string        { return &s }
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestChallengeService_Lifecycle(t *testing.T) {
+	db := setupMigratedDB(t)
+	svc := services.NewChallengeService(db)
+	now := time.Now()
+
+	ch, err := svc.Create(1, services.ChallengeInput{
+		Title:          strPtr("Flip This Sample!"),
+		Rules:          strPtr("Use Serum in FL Studio"),
+		StartsAt:       timePtr(now.Add(-time.Hour)),
+		EndsAt:         timePtr(now.Add(time.Hour)),
+		RequiredPlugin: strPtr("Serum"),
+		RequiredDAW:    strPtr("FL Studio"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "flip-this-sample", ch.Slug)
+	assert.Equal(t, services.PhaseOpen, ch.Phase)
+
+	alice, aliceProj := seedProducer(t, db, "alice", "fl studio", "serum")
+	bob, bobProj := seedProducer(t, db, "bob", "Ableton Live", "Serum")
+	carol, carolProj := seedProducer(t, db, "carol", "FL Studio", "Vital")
+
+	_, err = svc.Submit(alice.ID, ch.ID, services.SubmitEntryInput{ProjectID: aliceProj.ID})
+	require.NoError(t, err)
+	_, err = svc.Submit(bob.ID, ch.ID, services.SubmitEntryInput{ProjectID: bobProj.ID})
+	assert.ErrorContains(t, err, "FL Studio")
+	_, err = svc.Submit(carol.ID, ch.ID, services.SubmitEntryInput{ProjectID: carolProj.ID})
+	assert.ErrorContains(t, err, "Serum")
+	_, err = svc.Submit(bob.ID, ch.ID, services.SubmitEntryInput{ProjectID: aliceProj.ID})
+	assert.Error(t, err, "cannot enter someone else's project")
+
+	// Winners cannot be announced before the deadline.
+	_, err = svc.AnnounceWinners(ch.ID, []services.Placement{{EntryID: 1, Placement: 1}})
+	assert.ErrorIs(t, err, services.ErrChallengeNotClosed)
+
+	// Move the deadline into the past and let the locker run.
+	require.NoError(t, db.Model(&models.Challenge{}).Where("id = ?", ch.ID).Update("ends_at", now.Add(-time.Minute)).Error)
+	n, err := svc.LockDue(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.ErrorIs(t, svc.Withdraw(alice.ID, ch.ID), services.ErrEntryLocked)
+	_, err = svc.Submit(alice.ID, ch.ID, services.SubmitEntryInput{ProjectID: aliceProj.ID})
+	assert.ErrorIs(t, err, services.ErrChallengeNotOpen)
+
+	got, err := svc.Get(ch.Slug)
+	require.NoError(t, err)
+	require.Len(t, got.Entries, 1)
+	assert.True(t, got.Entries[0].Locked)
+	assert.Equal(t, services.PhaseClosed, got.Phase)
+
+	judged, err := svc.AnnounceWinners(ch.ID, []services.Placement{{EntryID: got.Entries[0].ID, Placement: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, services.PhaseJudged, judged.Phase)
+	require.NotNil(t, judged.Entries[0].Placement)
+	assert.Equal(t, 1, *judged.Entries[0].Placement)
+}
+
+func TestChallengeService_SubmitRacingTheLocker(t *testing.T) {
+	db := setupMigratedDB(t)
+	svc := services.NewChallengeService(db)
+	now := time.Now()
+	ch, err := svc.Create(1, services.ChallengeInput{
+		Title:    strPtr("Last Minute"),
+		StartsAt: timePtr(now.Add(-time.Hour)),
+		EndsAt:   timePtr(now.Add(time.Hour)),
+	})
+	require.NoError(t, err)
+	alice, first := seedProducer(t, db, "alice", "FL Studio", "")
+	second := models.Project{UserID: alice.ID, Title: "second", Public: true}
+	require.NoError(t, db.Create(&second).Error)
+	entry, err := svc.Submit(alice.ID, ch.ID, services.SubmitEntryInput{ProjectID: first.ID})
+	require.NoError(t, err)
+
+	// A locked entry is not replaced, even while the challenge still reads as open.
+	require.NoError(t, db.Model(&models.ChallengeEntry{}).Where("id = ?", entry.ID).Update("locked", true).Error)
+	_, err = svc.Submit(alice.ID, ch.ID, services.SubmitEntryInput{ProjectID: second.ID})
+	assert.ErrorIs(t, err, services.ErrEntryLocked)
+	require.NoError(t, db.Model(&models.ChallengeEntry{}).Where("id = ?", entry.ID).Update("locked", false).Error)
+
+	// The deadline passes and the locker runs after Submit checked the phase but before it writes.
+	armed := true
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:lock_mid_submit", func(tx *gorm.DB) {
+		if !armed || tx.Statement.Table != "projects" {
+			return
+		}
+		armed = false
+		require.NoError(t, db.Model(&models.Challenge{}).Where("id = ?", ch.ID).Update("ends_at", now.Add(-time.Minute)).Error)
+		n, err := svc.LockDue(now)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	}))
+	_, err = svc.Submit(alice.ID, ch.ID, services.SubmitEntryInput{ProjectID: second.ID})
+	assert.ErrorIs(t, err, services.ErrChallengeNotOpen)
+	assert.False(t, armed)
+
+	var entries []models.ChallengeEntry
+	require.NoError(t, db.Where("challenge_id = ?", ch.ID).Find(&entries).Error)
+	require.Len(t, entries, 1)
+	assert.Equal(t, first.ID, entries[0].ProjectID)
+	assert.True(t, entries[0].Locked)
+}
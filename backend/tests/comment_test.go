@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+type sentComment struct {
+	to string
+	n  services.CommentNotification
+}
+
+// fakeCommentMailer records notifications instead of sending them.
+type fakeCommentMailer chan sentComment
+
+func (f fakeCommentMailer) SendCommentNotification(to, name string, n services.CommentNotification) error {
+	f <- sentComment{to: to, n: n}
+	return nil
+}
+
+func (f fakeCommentMailer) next(t *testing.T) sentComment {
+	select {
+	case s := <-f:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification sent")
+		return sentComment{}
+	}
+}
+
+func TestCommentController_Threads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	alice, beat := seedProducer(t, db, "alice", "FL Studio", "")
+	bob, other := seedProducer(t, db, "bob", "FL Studio", "")
+	carol, _ := seedProducer(t, db, "carol", "FL Studio", "")
+	mod, _ := seedProducer(t, db, "mod", "FL Studio", "")
+	private := models.Project{UserID: alice.ID, Title: "secret", Public: false}
+	require.NoError(t, db.Create(&private).Error)
+	bounce := models.AudioFile{ProjectID: beat.ID, UserID: alice.ID, StorageKey: "a/bounce.wav", DurationSeconds: 120}
+	require.NoError(t, db.Create(&bounce).Error)
+	elsewhere := models.AudioFile{ProjectID: other.ID, UserID: bob.ID, StorageKey: "b/bounce.wav"}
+	require.NoError(t, db.Create(&elsewhere).Error)
+
+	mailer := make(fakeCommentMailer, 10)
+	ctl := controllers.NewCommentController(db, mailer, "https://uploadparty.example")
+	as := func(u models.User) *gin.Engine {
+		r := gin.New()
+		r.Use(asUser(u.ID))
+		r.POST("/projects/:id/comments", ctl.Create)
+		r.PATCH("/comments/:id", ctl.Update)
+		r.DELETE("/comments/:id", ctl.Delete)
+		r.DELETE("/admin/comments/:id", ctl.Remove)
+		r.GET("/projects/:id/comments", ctl.List)
+		return r
+	}
+	do := func(u models.User, method, path, body string) (int, services.CommentView) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		as(u).ServeHTTP(w, req)
+		var v services.CommentView
+		if w.Code < 300 && w.Body.Len() > 0 {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+		}
+		return w.Code, v
+	}
+	beatPath := "/projects/" + itoa(beat.ID) + "/comments"
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"empty body", beatPath, `{"body":"   "}`, http.StatusBadRequest},
+		{"too long", beatPath, `{"body":"` + strings.Repeat("a", 2001) + `"}`, http.StatusBadRequest},
+		{"negative timestamp", beatPath, `{"body":"hm","timestampSeconds":-1}`, http.StatusBadRequest},
+		{"past the end", beatPath, `{"body":"hm","timestampSeconds":121}`, http.StatusBadRequest},
+		{"audio of another project", beatPath, `{"body":"hm","timestampSeconds":1,"audioFileId":` + itoa(elsewhere.ID) + `}`, http.StatusBadRequest},
+		{"audio without timestamp", beatPath, `{"body":"hm","audioFileId":` + itoa(bounce.ID) + `}`, http.StatusBadRequest},
+		{"private project", "/projects/" + itoa(private.ID) + "/comments", `{"body":"hm"}`, http.StatusNotFound},
+		{"unknown parent", beatPath, `{"body":"hm","parentId":9999}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := do(bob, http.MethodPost, tt.path, tt.body)
+			assert.Equal(t, tt.status, status)
+		})
+	}
+
+	status, root := do(bob, http.MethodPost, beatPath, `{"body":"the drop at 1:32 is muddy","timestampSeconds":92}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.NotNil(t, root.AudioFileID, "pinned to the latest upload")
+	assert.Equal(t, bounce.ID, *root.AudioFileID)
+	assert.Equal(t, "bob", root.Author.Username)
+	sent := mailer.next(t)
+	assert.Equal(t, "alice@example.com", sent.to)
+	assert.False(t, sent.n.Reply)
+	assert.Equal(t, "the drop at 1:32 is muddy", sent.n.Body)
+	assert.Equal(t, "https://uploadparty.example/projects/"+itoa(beat.ID)+"#comment-"+itoa(root.ID), sent.n.URL)
+
+	// The owner replying notifies the commenter only.
+	status, reply := do(alice, http.MethodPost, beatPath, `{"body":"fixed in v2","parentId":`+itoa(root.ID)+`}`)
+	require.Equal(t, http.StatusCreated, status)
+	sent = mailer.next(t)
+	assert.Equal(t, "bob@example.com", sent.to)
+	assert.True(t, sent.n.Reply)
+	// A reply to a reply joins the thread.
+	status, nested := do(carol, http.MethodPost, beatPath, `{"body":"agreed","parentId":`+itoa(reply.ID)+`}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.NotNil(t, nested.ParentID)
+	assert.Equal(t, root.ID, *nested.ParentID)
+	assert.Equal(t, "alice@example.com", mailer.next(t).to)
+	select {
+	case extra := <-mailer:
+		t.Fatalf("unexpected notification to %s", extra.to)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	status, _ = do(carol, http.MethodPatch, "/comments/"+itoa(root.ID), `{"body":"mine now"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, edited := do(bob, http.MethodPatch, "/comments/"+itoa(root.ID), `{"body":"the drop at 1:35 is muddy","timestampSeconds":95}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "the drop at 1:35 is muddy", edited.Body)
+	assert.Equal(t, 95.0, *edited.TimestampSeconds)
+	assert.NotNil(t, edited.EditedAt)
+
+	list := func(query string) services.Page[services.CommentView] {
+		w := httptest.NewRecorder()
+		as(carol).ServeHTTP(w, httptest.NewRequest(http.MethodGet, beatPath+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page services.Page[services.CommentView]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+	status, second := do(carol, http.MethodPost, beatPath, `{"body":"love the chords"}`)
+	require.Equal(t, http.StatusCreated, status)
+	mailer.next(t)
+
+	page := list("?limit=1")
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, second.ID, page.Items[0].ID, "newest thread first")
+	page = list("?limit=1&cursor=" + page.NextCursor)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, root.ID, page.Items[0].ID)
+	require.Len(t, page.Items[0].Replies, 2)
+	assert.Equal(t, []uint{reply.ID, nested.ID}, []uint{page.Items[0].Replies[0].ID, page.Items[0].Replies[1].ID})
+	assert.Empty(t, page.NextCursor)
+	assert.Equal(t, root.ID, list("?sort=oldest&limit=1").Items[0].ID)
+
+	// Deleted threads stay as placeholders while they have replies; removed replies disappear.
+	status, _ = do(bob, http.MethodDelete, "/comments/"+itoa(root.ID), "")
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = do(bob, http.MethodPatch, "/comments/"+itoa(root.ID), `{"body":"again"}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(carol, http.MethodPost, beatPath, `{"body":"late","parentId":`+itoa(root.ID)+`}`)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = do(mod, http.MethodDelete, "/admin/comments/"+itoa(nested.ID), `{"reason":"spam"}`)
+	require.Equal(t, http.StatusNoContent, status)
+
+	page = list("?sort=oldest")
+	require.Len(t, page.Items, 2)
+	placeholder := page.Items[0]
+	assert.NotNil(t, placeholder.DeletedAt)
+	assert.Empty(t, placeholder.Body)
+	assert.Nil(t, placeholder.Author)
+	assert.Zero(t, placeholder.UserID)
+	require.Len(t, placeholder.Replies, 1)
+	assert.Equal(t, reply.ID, placeholder.Replies[0].ID)
+	assert.Equal(t, alice.ID, placeholder.Replies[0].UserID, "replies keep their authors")
+
+	var removed models.Comment
+	require.NoError(t, db.First(&removed, nested.ID).Error)
+	assert.Equal(t, mod.ID, *removed.RemovedByID)
+	assert.Equal(t, "spam", removed.RemovedReason)
+
+	status, _ = do(alice, http.MethodDelete, "/comments/"+itoa(reply.ID), "")
+	require.Equal(t, http.StatusNoContent, status)
+	page = list("")
+	require.Len(t, page.Items, 1, "an empty deleted thread is gone")
+	assert.Equal(t, second.ID, page.Items[0].ID)
+
+	// Comments go away with the project's visibility.
+	require.NoError(t, db.Model(&beat).Update("public", false).Error)
+	w := httptest.NewRecorder()
+	as(carol).ServeHTTP(w, httptest.NewRequest(http.MethodGet, beatPath, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
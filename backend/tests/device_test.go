@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestDeviceLogin_PluginReceivesIngestKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|device", Email: "device@example.com", Username: "device"}
+	require.NoError(t, db.Create(&u).Error)
+
+	ctl := controllers.NewDeviceController(db, "https://uploadparty.test/")
+	authn := middlewares.NewAuthenticator(db, middlewares.NewJWT("secret"), nil)
+	projCtl := controllers.NewProjectController(db, nil)
+	r := gin.New()
+	r.POST("/auth/device/code", ctl.Start)
+	r.POST("/auth/device/token", ctl.Token)
+	app := r.Group("/app", asUser(u.ID))
+	app.GET("/device", ctl.Lookup)
+	app.POST("/device/approve", ctl.Approve)
+	app.POST("/device/deny", ctl.Deny)
+	r.POST("/ingest/projects", authn.RequireUserOrAPIKey(), middlewares.RequireScope(models.ScopeProjectsWrite), projCtl.Upsert)
+
+	call := func(method, path string, body interface{}, header ...string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	start := func() services.DeviceCodeResponse {
+		w := call(http.MethodPost, "/auth/device/code", gin.H{"clientName": "UploadParty VST on Studio-PC"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp services.DeviceCodeResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	poll := func(deviceCode string) (int, map[string]interface{}) {
+		w := call(http.MethodPost, "/auth/device/token", gin.H{"deviceCode": deviceCode})
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+	// Polls are spaced by the interval; pretend the device waited.
+	waited := func() {
+		require.NoError(t, db.Model(&models.DeviceAuthorization{}).Where("1 = 1").Update("last_polled_at", time.Now().Add(-time.Minute)).Error)
+	}
+
+	dev := start()
+	assert.Regexp(t, `^[B-DF-HJ-NP-TV-XZ]{4}-[B-DF-HJ-NP-TV-XZ]{4}$`, dev.UserCode)
+	assert.Equal(t, "https://uploadparty.test/device", dev.VerificationURI)
+	assert.Equal(t, 5, dev.Interval)
+
+	code, body := poll(dev.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "authorization_pending", body["error"])
+	_, body = poll(dev.DeviceCode)
+	assert.Equal(t, "slow_down", body["error"], "polling without waiting backs off")
+
+	// The user types the code loosely; it still matches.
+	typed := strings.ToLower(strings.ReplaceAll(dev.UserCode, "-", " "))
+	w := call(http.MethodGet, "/app/device?code="+strings.ReplaceAll(typed, " ", "%20"), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Studio-PC")
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/app/device/approve", gin.H{"userCode": typed}).Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/app/device/deny", gin.H{"userCode": dev.UserCode}).Code, "already decided")
+
+	waited()
+	code, body = poll(dev.DeviceCode)
+	require.Equal(t, http.StatusOK, code, body)
+	key, _ := body["apiKey"].(string)
+	require.True(t, strings.HasPrefix(key, services.APIKeyPrefix))
+
+	w = call(http.MethodPost, "/ingest/projects", gin.H{"title": "From the plugin"}, "Authorization", "Bearer "+key)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var stored models.APIKey
+	require.NoError(t, db.Where("user_id = ?", u.ID).First(&stored).Error)
+	assert.Equal(t, "UploadParty VST on Studio-PC", stored.Name)
+
+	waited()
+	_, body = poll(dev.DeviceCode)
+	assert.Equal(t, "invalid_grant", body["error"], "device codes are single use")
+
+	denied := start()
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/app/device/deny", gin.H{"userCode": denied.UserCode}).Code)
+	_, body = poll(denied.DeviceCode)
+	assert.Equal(t, "access_denied", body["error"])
+
+	expired := start()
+	require.NoError(t, db.Model(&models.DeviceAuthorization{}).Where("user_code = ?", expired.UserCode).Update("expires_at", time.Now().Add(-time.Second)).Error)
+	_, body = poll(expired.DeviceCode)
+	assert.Equal(t, "expired_token", body["error"])
+	assert.Equal(t, http.StatusNotFound, call(http.MethodPost, "/app/device/approve", gin.H{"userCode": expired.UserCode}).Code)
+
+	_, body = poll("bogus")
+	assert.Equal(t, "invalid_grant", body["error"])
+}
+
+func TestDeviceLogin_APIKeyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|full", Email: "full@example.com", Username: "full"}
+	require.NoError(t, db.Create(&u).Error)
+	keys := services.NewAPIKeyService(db)
+	var last *models.APIKey
+	for i := 0; i < 24; i++ {
+		k, _, err := keys.Create(u.ID, services.CreateAPIKeyInput{Name: "key " + itoa(uint(i))})
+		require.NoError(t, err)
+		last = k
+	}
+
+	ctl := controllers.NewDeviceController(db, "https://uploadparty.test")
+	r := gin.New()
+	r.POST("/auth/device/code", ctl.Start)
+	r.POST("/auth/device/token", ctl.Token)
+	r.POST("/app/device/approve", asUser(u.ID), ctl.Approve)
+	call := func(path string, body gin.H) (int, map[string]interface{}) {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(body)
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var out map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+	start := func() services.DeviceCodeResponse {
+		_, body := call("/auth/device/code", gin.H{"clientName": "VST"})
+		return services.DeviceCodeResponse{DeviceCode: body["deviceCode"].(string), UserCode: body["userCode"].(string)}
+	}
+
+	// Approved with room for one more key, but another key is made before the plugin polls.
+	dev := start()
+	code, _ := call("/app/device/approve", gin.H{"userCode": dev.UserCode})
+	require.Equal(t, http.StatusOK, code)
+	_, _, err := keys.Create(u.ID, services.CreateAPIKeyInput{Name: "manual"})
+	require.NoError(t, err)
+	code, body := call("/auth/device/token", gin.H{"deviceCode": dev.DeviceCode})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "too_many_api_keys", body["error"])
+	assert.Contains(t, body["error_description"], "revoke")
+
+	// With every key in use, approving is refused up front.
+	full := start()
+	code, body = call("/app/device/approve", gin.H{"userCode": full.UserCode})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Contains(t, body["error"], "revoke")
+
+	// Revoking a key lets the approved device finish.
+	require.NoError(t, keys.Revoke(u.ID, last.ID))
+	require.NoError(t, db.Model(&models.DeviceAuthorization{}).Where("1 = 1").Update("last_polled_at", time.Now().Add(-time.Minute)).Error)
+	code, body = call("/auth/device/token", gin.H{"deviceCode": dev.DeviceCode})
+	require.Equal(t, http.StatusOK, code, body)
+	assert.NotEmpty(t, body["apiKey"])
+}
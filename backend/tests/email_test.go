@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/emails"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestEmails_RenderEscapesAndLocalizes(t *testing.T) {
+	r := emails.New("")
+	require.NoError(t, r.Check())
+
+	infos, err := r.Templates()
+	require.NoError(t, err)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+		assert.Contains(t, info.Locales, emails.DefaultLocale, info.Name)
+	}
+	assert.Equal(t, []string{emails.CommentNotification, emails.ReferralNotification, emails.RSVPConfirmation, emails.Welcome}, names)
+
+	data := emails.CommentData{
+		Name:          `Alex"><script>`,
+		CommenterName: "Sam <sam@example.com>",
+		ProjectTitle:  "Beats & Bass",
+		At:            "1:32",
+		Body:          "<b>muddy</b>",
+		URL:           "javascript:alert(1)",
+	}
+	msg, err := r.Render(emails.CommentNotification, "en", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Sam <sam@example.com> commented on your project Beats & Bass", msg.Subject)
+	assert.Contains(t, msg.HTML, "&lt;b&gt;muddy&lt;/b&gt;")
+	assert.Contains(t, msg.HTML, "Sam &lt;sam@example.com&gt;")
+	assert.Contains(t, msg.HTML, "Alex&#34;&gt;&lt;script&gt;")
+	assert.NotContains(t, msg.HTML, "<b>muddy")
+	assert.NotContains(t, msg.HTML, "javascript:")
+	assert.Contains(t, msg.HTML, "The UploadParty Team", "wrapped in the layout")
+	assert.Contains(t, msg.Text, "<b>muddy</b>", "plain text is not escaped")
+	assert.Contains(t, msg.Text, "at 1:32")
+
+	tests := []struct {
+		name    string
+		locale  string
+		want    string
+		subject string
+	}{
+		{"default", "", "en", "Welcome to UploadParty, Alex!"},
+		{"exact", "es", "es", "¡Te damos la bienvenida a UploadParty, Alex!"},
+		{"regional", "es-MX", "es", "¡Te damos la bienvenida a UploadParty, Alex!"},
+		{"missing", "fr", "en", "Welcome to UploadParty, Alex!"},
+		{"malformed", "not a locale", "en", "Welcome to UploadParty, Alex!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := r.Render(emails.Welcome, tt.locale, emails.WelcomeData{Name: "Alex", URL: "https://uploadparty.example"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, msg.Locale)
+			assert.Equal(t, tt.subject, msg.Subject)
+			assert.Contains(t, msg.HTML, `href="https://uploadparty.example"`)
+			assert.Contains(t, msg.HTML, `lang="`+tt.want+`"`)
+		})
+	}
+
+	_, err = r.Render("nope", "en", nil)
+	assert.ErrorIs(t, err, emails.ErrUnknownTemplate)
+}
+
+func TestEmails_OverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("welcome.en.txt", `{{define "subject"}}Hey {{.Name}}{{end}}{{define "body"}}Edited copy.{{end}}`)
+	write("welcome.fr.txt", `{{define "subject"}}Bienvenue {{.Name}}{{end}}{{define "body"}}Bonjour !{{end}}`)
+	r := emails.New(dir)
+
+	msg, err := r.Render(emails.Welcome, "en", emails.WelcomeData{Name: "Alex"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hey Alex", msg.Subject)
+	assert.True(t, strings.HasPrefix(msg.Text, "Edited copy."), msg.Text)
+	assert.Contains(t, msg.HTML, "Welcome to UploadParty!", "files that are not overridden stay embedded")
+
+	msg, err = r.Render(emails.Welcome, "fr", emails.WelcomeData{Name: "Alex"})
+	require.NoError(t, err)
+	assert.Equal(t, "fr", msg.Locale)
+	assert.Equal(t, "Bienvenue Alex", msg.Subject)
+
+	infos, err := r.Templates()
+	require.NoError(t, err)
+	for _, info := range infos {
+		if info.Name == emails.Welcome {
+			assert.Equal(t, []string{"en", "es", "fr"}, info.Locales)
+		}
+	}
+	require.NoError(t, r.Check())
+
+	// Edits apply without a restart, and broken ones are reported.
+	write("welcome.en.txt", `{{define "subject"}}{{.Nope}}{{end}}`)
+	_, err = r.Render(emails.Welcome, "en", emails.WelcomeData{Name: "Alex"})
+	assert.Error(t, err)
+	assert.Error(t, r.Check())
+}
+
+func TestEmails_PreferredLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"es", "es"},
+		{"es-MX,es;q=0.9,en;q=0.8", "es-mx"},
+		{"en;q=0.5, de", "de"},
+		{"pt_BR", "pt-br"},
+		{"*", ""},
+		{"*, fr;q=0.1", "fr"},
+		{"de;q=abc, it;q=0.3", "it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, emails.PreferredLocale(tt.header))
+		})
+	}
+}
+
+func TestEmailController_Preview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctl := controllers.NewEmailController(emails.New(""))
+	r := gin.New()
+	r.GET("/admin/emails", ctl.List)
+	r.GET("/admin/emails/:name/preview", ctl.Preview)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"json", "/admin/emails/rsvp_confirmation/preview", http.StatusOK, "application/json", `"subject":"RSVP Confirmed - Thanks for RSVPing"`},
+		{"html", "/admin/emails/referral_notification/preview?format=html", http.StatusOK, "text/html", "<strong>Sam &lt;sam@example.com&gt;</strong>"},
+		{"text in spanish", "/admin/emails/comment_notification/preview?format=text&locale=es", http.StatusOK, "text/plain", "Lee y responde"},
+		{"unknown template", "/admin/emails/nope/preview", http.StatusNotFound, "application/json", "not found"},
+		{"invalid locale", "/admin/emails/welcome/preview?locale=!!", http.StatusBadRequest, "application/json", "invalid locale"},
+		{"invalid format", "/admin/emails/welcome/preview?format=pdf", http.StatusBadRequest, "application/json", "format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.path)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Contains(t, w.Header().Get("Content-Type"), tt.contentType)
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+
+	w := get("/admin/emails")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Templates []emails.TemplateInfo `json:"templates"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Templates, 4)
+}
+
+type sentWelcome struct{ to, name, locale string }
+
+// fakeWelcomeMailer records welcome emails instead of sending them.
+type fakeWelcomeMailer chan sentWelcome
+
+func (f fakeWelcomeMailer) SendWelcomeEmail(to, name, locale string) error {
+	f <- sentWelcome{to, name, locale}
+	return nil
+}
+
+func TestUserService_WelcomesNewUsersInTheirLocale(t *testing.T) {
+	db := setupMigratedDB(t)
+	mailer := make(fakeWelcomeMailer, 10)
+	users := services.NewUserService(db, "secret")
+	users.Mailer = mailer
+	next := func() sentWelcome {
+		select {
+		case s := <-mailer:
+			return s
+		case <-time.After(2 * time.Second):
+			t.Fatal("no welcome email sent")
+			return sentWelcome{}
+		}
+	}
+
+	u, err := users.SyncAuth0User("google-oauth2|7", "maria@example.com", "maria", "María", "", "pt_BR")
+	require.NoError(t, err)
+	assert.Equal(t, "pt-br", u.Locale)
+	assert.Equal(t, sentWelcome{"maria@example.com", "María", "pt-br"}, next())
+	_, err = users.SyncAuth0User("google-oauth2|7", "maria@example.com", "maria", "María", "", "en")
+	require.NoError(t, err)
+
+	_, err = users.Register("legacy@example.com", "legacy", "password1", "")
+	require.NoError(t, err)
+	assert.Equal(t, sentWelcome{"legacy@example.com", "legacy", ""}, next())
+	select {
+	case extra := <-mailer:
+		t.Fatalf("unexpected welcome email to %s", extra.to)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Users can change their language later.
+	profiles := services.NewProfileService(db)
+	updated, err := profiles.Update(u.ID, services.ProfileUpdate{Locale: strPtr("es-ES")})
+	require.NoError(t, err)
+	assert.Equal(t, "es-es", updated.Locale)
+	_, err = profiles.Update(u.ID, services.ProfileUpdate{Locale: strPtr("español")})
+	assert.Error(t, err)
+	updated, err = profiles.Update(u.ID, services.ProfileUpdate{Locale: strPtr("")})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Locale)
+}
+
+func TestRSVPController_StoresLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	ctl := controllers.NewRSVPController(db, nil)
+	r := gin.New()
+	r.POST("/rsvp", ctl.Create)
+
+	req := httptest.NewRequest(http.MethodPost, "/rsvp", strings.NewReader(`{"email":"ana@example.com","firstName":"Ana"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es-AR,es;q=0.9")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var rsvp models.RSVP
+	require.NoError(t, db.Where("email = ?", "ana@example.com").First(&rsvp).Error)
+	assert.Equal(t, "es-ar", rsvp.Locale)
+}
@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestEngagementService_PlaysAreDeduplicated(t *testing.T) {
+	db := setupMigratedDB(t)
+	alice, beat := seedProducer(t, db, "alice", "FL Studio", "")
+	bob, _ := seedProducer(t, db, "bob", "FL Studio", "")
+	private := models.Project{UserID: alice.ID, Title: "secret"}
+	require.NoError(t, db.Create(&private).Error)
+	svc := services.NewEngagementService(db)
+	svc.Salt = "pepper"
+	var before models.Project
+	require.NoError(t, db.First(&before, beat.ID).Error)
+
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) // the start of a window
+	tests := []struct {
+		name    string
+		project uint
+		viewer  services.Viewer
+		at      time.Duration
+		counted bool
+		wantErr error
+	}{
+		{"first play", beat.ID, services.Viewer{UserID: bob.ID, IP: "10.0.0.1"}, 0, true, nil},
+		{"replay in window", beat.ID, services.Viewer{UserID: bob.ID, IP: "10.0.0.2"}, 29 * time.Minute, false, nil},
+		{"next window", beat.ID, services.Viewer{UserID: bob.ID}, 31 * time.Minute, true, nil},
+		{"signed out", beat.ID, services.Viewer{IP: "10.0.0.1"}, 0, true, nil},
+		{"signed out again", beat.ID, services.Viewer{IP: "10.0.0.1"}, 10 * time.Minute, false, nil},
+		{"another ip", beat.ID, services.Viewer{IP: "10.0.0.3"}, 10 * time.Minute, true, nil},
+		{"owner", beat.ID, services.Viewer{UserID: alice.ID}, 0, false, nil},
+		{"private project", private.ID, services.Viewer{UserID: bob.ID}, 0, false, gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted, err := svc.RecordPlay(tt.project, tt.viewer, t0.Add(tt.at))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.counted, counted)
+		})
+	}
+
+	// Counters only move when the aggregator runs.
+	var p models.Project
+	require.NoError(t, db.First(&p, beat.ID).Error)
+	assert.Zero(t, p.PlayCount)
+	n, err := svc.Aggregate()
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	require.NoError(t, db.First(&p, beat.ID).Error)
+	assert.Equal(t, int64(4), p.PlayCount)
+	assert.True(t, before.UpdatedAt.Equal(p.UpdatedAt), "plays are not edits")
+	n, err = svc.Aggregate()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Stored keys never contain the address.
+	var keys []string
+	require.NoError(t, db.Model(&models.EngagementHit{}).Pluck("viewer_key", &keys).Error)
+	assert.Contains(t, keys, "u:"+itoa(bob.ID))
+	assert.NotContains(t, keys, "ip:10.0.0.1")
+
+	// Once their windows are over, hits are no longer needed.
+	require.NoError(t, svc.Cleanup(t0.Add(2*time.Hour)))
+	var left int64
+	require.NoError(t, db.Model(&models.EngagementHit{}).Count(&left).Error)
+	assert.Zero(t, left)
+}
+
+func TestEngagementController_LikesAndProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	alice, beat := seedProducer(t, db, "alice", "FL Studio", "")
+	bob, _ := seedProducer(t, db, "bob", "FL Studio", "")
+	carol, _ := seedProducer(t, db, "carol", "FL Studio", "")
+
+	ctl := controllers.NewEngagementController(db)
+	prof := controllers.NewProfileController(db, "secret", nil)
+	prof.Engagement = ctl.Svc
+	as := func(u models.User) *gin.Engine {
+		r := gin.New()
+		if u.ID != 0 {
+			r.Use(asUser(u.ID))
+		}
+		r.GET("/projects/:id/like", ctl.LikeState)
+		r.POST("/projects/:id/like", ctl.Like)
+		r.DELETE("/projects/:id/like", ctl.Unlike)
+		r.POST("/projects/:id/plays", ctl.RecordPlay)
+		r.GET("/profiles/:handle", prof.GetPublicProfile)
+		r.GET("/profiles/:handle/projects", prof.GetPublicProjects)
+		return r
+	}
+	likePath := "/projects/" + itoa(beat.ID) + "/like"
+
+	tests := []struct {
+		name   string
+		user   models.User
+		method string
+		path   string
+		status int
+		want   services.LikeState
+	}{
+		{"like", bob, http.MethodPost, likePath, http.StatusOK, services.LikeState{Liked: true, LikeCount: 1}},
+		{"like again", bob, http.MethodPost, likePath, http.StatusOK, services.LikeState{Liked: true, LikeCount: 1}},
+		{"state of someone else", carol, http.MethodGet, likePath, http.StatusOK, services.LikeState{Liked: false, LikeCount: 1}},
+		{"unlike without like", carol, http.MethodDelete, likePath, http.StatusOK, services.LikeState{Liked: false, LikeCount: 1}},
+		{"second like", carol, http.MethodPost, likePath, http.StatusOK, services.LikeState{Liked: true, LikeCount: 2}},
+		{"own project", alice, http.MethodPost, likePath, http.StatusOK, services.LikeState{Liked: true, LikeCount: 3}},
+		{"unlike", alice, http.MethodDelete, likePath, http.StatusOK, services.LikeState{Liked: false, LikeCount: 2}},
+		{"unknown project", bob, http.MethodPost, "/projects/9999/like", http.StatusNotFound, services.LikeState{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			as(tt.user).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusOK {
+				var got services.LikeState
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+
+	request := func(u models.User, method, path, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":4321"
+		as(u).ServeHTTP(w, req)
+		return w
+	}
+	w := request(bob, http.MethodPost, "/projects/"+itoa(beat.ID)+"/plays", "10.0.0.1")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"counted":true}`, w.Body.String())
+	w = request(bob, http.MethodPost, "/projects/"+itoa(beat.ID)+"/plays", "10.0.0.1")
+	assert.JSONEq(t, `{"counted":false}`, w.Body.String())
+
+	// Profile views: the owner does not count, repeat visitors count once.
+	for _, v := range []struct {
+		user models.User
+		ip   string
+	}{{alice, "10.0.0.9"}, {bob, "10.0.0.1"}, {bob, "10.0.0.1"}, {models.User{}, "10.0.0.1"}, {models.User{}, "10.0.0.1"}} {
+		require.Equal(t, http.StatusOK, request(v.user, http.MethodGet, "/profiles/alice", v.ip).Code)
+	}
+	_, err := ctl.Svc.Aggregate()
+	require.NoError(t, err)
+
+	w = request(models.User{}, http.MethodGet, "/profiles/alice/projects", "10.0.0.5")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page services.Page[models.Project]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, int64(2), page.Items[0].LikeCount)
+	assert.Equal(t, int64(1), page.Items[0].PlayCount)
+
+	w = request(alice, http.MethodGet, "/profiles/alice", "10.0.0.9")
+	require.Equal(t, http.StatusOK, w.Code)
+	var profile struct {
+		User struct {
+			ProfileViews int64 `json:"profileViews"`
+		} `json:"user"`
+		Projects []models.Project `json:"projects"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &profile))
+	assert.Equal(t, int64(2), profile.User.ProfileViews)
+	require.Len(t, profile.Projects, 1)
+	assert.Equal(t, int64(2), profile.Projects[0].LikeCount)
+}
+
+func TestEngagementController_ForgedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	_, beat := seedProducer(t, db, "alice", "FL Studio", "")
+	ctl := controllers.NewEngagementController(db)
+	ctl.Svc.Salt = "pepper"
+	path := "/projects/" + itoa(beat.ID) + "/plays"
+
+	// Configured as main does: TRUSTED_PROXIES lists only the load balancer.
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies([]string{"10.0.0.254"}))
+	r.POST("/projects/:id/plays", ctl.RecordPlay)
+	play := func(peer, forwarded string) bool {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = peer + ":4321"
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var res struct {
+			Counted bool `json:"counted"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Counted
+	}
+
+	assert.True(t, play("203.0.113.7", ""))
+	assert.False(t, play("203.0.113.7", "198.51.100.1"), "a forged header is not a new viewer")
+	assert.False(t, play("203.0.113.7", "198.51.100.2, 10.0.0.254"))
+	assert.True(t, play("10.0.0.254", "198.51.100.3"), "the proxy reports the real client")
+	assert.False(t, play("10.0.0.254", "198.51.100.3"))
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestFollowController_FollowAndLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	me, _ := seedProducer(t, db, "listener", "FL Studio", "")
+	alice, _ := seedProducer(t, db, "alice", "FL Studio", "")
+	seedProducer(t, db, "bob", "Ableton Live", "")
+	hidden, _ := seedProducer(t, db, "hidden", "FL Studio", "")
+	require.NoError(t, db.Model(&hidden).Update("public", false).Error)
+
+	ctl := controllers.NewProfileController(db, "secret", nil)
+	r := gin.New()
+	r.POST("/app/profiles/:handle/follow", asUser(me.ID), ctl.Follow)
+	r.DELETE("/app/profiles/:handle/follow", asUser(me.ID), ctl.Unfollow)
+	r.GET("/app/profiles/:handle/follow", asUser(me.ID), ctl.FollowState)
+	r.GET("/profiles/:handle", ctl.GetPublicProfile)
+	r.GET("/profiles/:handle/followers", ctl.GetFollowers)
+	r.GET("/profiles/:handle/following", ctl.GetFollowing)
+
+	do := func(method, path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var out map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		handle    string
+		status    int
+		following bool
+		followers float64
+	}{
+		{"follow", http.MethodPost, "alice", http.StatusOK, true, 1},
+		{"follow again", http.MethodPost, "alice", http.StatusOK, true, 1},
+		{"state", http.MethodGet, "alice", http.StatusOK, true, 1},
+		{"follow another", http.MethodPost, "bob", http.StatusOK, true, 1},
+		{"unfollow", http.MethodDelete, "bob", http.StatusOK, false, 0},
+		{"unfollow again", http.MethodDelete, "bob", http.StatusOK, false, 0},
+		{"follow back", http.MethodPost, "bob", http.StatusOK, true, 1},
+		{"self", http.MethodPost, "listener", http.StatusBadRequest, false, 0},
+		{"private profile", http.MethodPost, "hidden", http.StatusNotFound, false, 0},
+		{"unknown", http.MethodPost, "nobody", http.StatusNotFound, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out := do(tt.method, "/app/profiles/"+tt.handle+"/follow")
+			require.Equal(t, tt.status, status, out)
+			if status == http.StatusOK {
+				assert.Equal(t, tt.following, out["following"])
+				assert.Equal(t, tt.followers, out["followers"])
+			}
+		})
+	}
+
+	// A private follower is not listed, and does not count.
+	require.NoError(t, db.Create(&models.Follow{FollowerID: hidden.ID, FolloweeID: me.ID}).Error)
+	require.NoError(t, db.Create(&models.Follow{FollowerID: alice.ID, FolloweeID: me.ID}).Error)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/listener/following?limit=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var page services.Page[services.FollowedUser]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "bob", page.Items[0].Username, "most recent first")
+	require.NotEmpty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/listener/following?limit=1&cursor="+page.NextCursor, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	page = services.Page[services.FollowedUser]{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "alice", page.Items[0].Username)
+	assert.Empty(t, page.NextCursor)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/listener/followers", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	page = services.Page[services.FollowedUser]{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "alice", page.Items[0].Username)
+
+	_, out := do(http.MethodGet, "/profiles/listener")
+	user := out["user"].(map[string]interface{})
+	assert.Equal(t, float64(1), user["followers"])
+	assert.Equal(t, float64(2), user["following"])
+}
+
+func TestFeedService_FanOutOnRead(t *testing.T) {
+	db := setupMigratedDB(t)
+	me, _ := seedProducer(t, db, "listener", "FL Studio", "")
+	alice, _ := seedProducer(t, db, "alice", "FL Studio", "")
+	bob, _ := seedProducer(t, db, "bob", "Ableton Live", "")
+	dave, _ := seedProducer(t, db, "dave", "FL Studio", "")
+	follows := services.NewFollowService(db)
+	require.NoError(t, follows.Follow(me.ID, alice.ID))
+	require.NoError(t, follows.Follow(me.ID, bob.ID))
+
+	projects := services.NewProjectService(db)
+	challenges := services.NewChallengeService(db)
+	yes, no := true, false
+	complete := models.StatusComplete
+
+	sunset, err := projects.Upsert(alice.ID, services.UpsertProjectInput{Title: "Sunset", DAW: "FL Studio", Public: &yes})
+	require.NoError(t, err)
+	_, err = projects.Update(alice.ID, sunset.ID, services.UpdateProjectInput{Status: &complete})
+	require.NoError(t, err)
+	night, err := projects.Upsert(bob.ID, services.UpsertProjectInput{Title: "Night", DAW: "Ableton Live"})
+	require.NoError(t, err)
+	_, err = projects.Update(bob.ID, night.ID, services.UpdateProjectInput{Public: &yes})
+	require.NoError(t, err)
+	// Toggling visibility again does not repeat the activity.
+	_, err = projects.Update(bob.ID, night.ID, services.UpdateProjectInput{Public: &no})
+	require.NoError(t, err)
+	_, err = projects.Update(bob.ID, night.ID, services.UpdateProjectInput{Public: &yes})
+	require.NoError(t, err)
+	_, err = projects.Upsert(dave.ID, services.UpsertProjectInput{Title: "Unfollowed", DAW: "FL Studio", Public: &yes})
+	require.NoError(t, err)
+
+	now := time.Now()
+	ch, err := challenges.Create(me.ID, services.ChallengeInput{
+		Title:    strPtr("Night Drive"),
+		StartsAt: timePtr(now.Add(-time.Hour)),
+		EndsAt:   timePtr(now.Add(time.Hour)),
+	})
+	require.NoError(t, err)
+	_, err = challenges.Submit(bob.ID, ch.ID, services.SubmitEntryInput{ProjectID: night.ID})
+	require.NoError(t, err)
+	// Replacing the entry moves the activity to the new project instead of adding one; it
+	// keeps the time of the first entry.
+	night2, err := projects.Upsert(bob.ID, services.UpsertProjectInput{Title: "Night (v2)", DAW: "Ableton Live", Public: &yes})
+	require.NoError(t, err)
+	_, err = challenges.Submit(bob.ID, ch.ID, services.SubmitEntryInput{ProjectID: night2.ID})
+	require.NoError(t, err)
+
+	feed := services.NewFeedService(db)
+	all := func() []string {
+		var got []string
+		page := services.CursorPage{Limit: 2}
+		for {
+			p, err := feed.Feed(me.ID, page)
+			require.NoError(t, err)
+			for _, it := range p.Items {
+				got = append(got, it.Username+" "+string(it.Kind)+" "+it.ProjectTitle+" "+it.ChallengeTitle)
+			}
+			if p.NextCursor == "" {
+				return got
+			}
+			page.Cursor = p.NextCursor
+		}
+	}
+	assert.Equal(t, []string{
+		"bob project_published Night (v2) ",
+		"bob challenge_entered Night (v2) Night Drive",
+		"bob project_published Night ",
+		"alice project_completed Sunset ",
+		"alice project_published Sunset ",
+	}, all())
+
+	// Feeds are read live: private projects, withdrawn entries and unfollows drop out at once.
+	_, err = projects.Update(alice.ID, sunset.ID, services.UpdateProjectInput{Public: &no})
+	require.NoError(t, err)
+	require.NoError(t, challenges.Withdraw(bob.ID, ch.ID))
+	assert.Equal(t, []string{"bob project_published Night (v2) ", "bob project_published Night "}, all())
+	require.NoError(t, follows.Unfollow(me.ID, bob.ID))
+	assert.Empty(t, all())
+
+	_, err = feed.Feed(me.ID, services.CursorPage{Cursor: "bogus"})
+	assert.ErrorIs(t, err, services.ErrInvalidCursor)
+}
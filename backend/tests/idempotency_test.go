@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/middlewares"
+)
+
+func TestIdempotency_Stores(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stores := map[string]func(t *testing.T) middlewares.IdempotencyStore{
+		"memory": func(t *testing.T) middlewares.IdempotencyStore { return middlewares.NewMemoryIdempotencyStore() },
+		"db": func(t *testing.T) middlewares.IdempotencyStore {
+			return middlewares.NewDBIdempotencyStore(setupMigratedDB(t))
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			idem := middlewares.NewIdempotency(store, time.Hour, "/secret")
+			calls := 0
+			handler := func(c *gin.Context) {
+				calls++
+				if c.Query("fail") == "1" {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+					return
+				}
+				c.JSON(http.StatusCreated, gin.H{"call": calls})
+			}
+			r := gin.New()
+			r.POST("/rsvp", idem.Middleware(), handler)
+			r.POST("/secret", idem.Middleware(), handler)
+			r.POST("/as/:uid", func(c *gin.Context) {
+				c.Set("user_id", uint(len(c.Param("uid"))))
+			}, idem.Middleware(), handler)
+			r.PUT("/upload", idem.Middleware(), handler)
+
+			send := func(method, path, key, contentType, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+				req.Header.Set("Content-Type", contentType)
+				if key != "" {
+					req.Header.Set("Idempotency-Key", key)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+			const js = "application/json"
+
+			steps := []struct {
+				name        string
+				method      string
+				path, key   string
+				contentType string
+				body        string
+				status      int
+				replayed    bool
+				calls       int
+			}{
+				{"first request runs", http.MethodPost, "/rsvp", "k1", js, `{"email":"a@example.com"}`, http.StatusCreated, false, 1},
+				{"retry replays", http.MethodPost, "/rsvp", "k1", js, `{"email":"a@example.com"}`, http.StatusCreated, true, 1},
+				{"different body is rejected", http.MethodPost, "/rsvp", "k1", js, `{"email":"b@example.com"}`, http.StatusUnprocessableEntity, false, 1},
+				{"excluded path runs", http.MethodPost, "/secret", "k1", js, `{"email":"a@example.com"}`, http.StatusCreated, false, 2},
+				{"and is never stored", http.MethodPost, "/secret", "k1", js, `{"email":"a@example.com"}`, http.StatusCreated, false, 3},
+				{"no key runs every time", http.MethodPost, "/rsvp", "", js, `{}`, http.StatusCreated, false, 4},
+				{"server errors are not stored", http.MethodPost, "/rsvp?fail=1", "k2", js, `{}`, http.StatusInternalServerError, false, 5},
+				{"so the retry runs", http.MethodPost, "/rsvp", "k3", js, `{}`, http.StatusCreated, false, 6},
+				{"user one", http.MethodPost, "/as/a", "shared", js, `{}`, http.StatusCreated, false, 7},
+				{"user two with the same key", http.MethodPost, "/as/bb", "shared", js, `{}`, http.StatusCreated, false, 8},
+				{"user one again", http.MethodPost, "/as/a", "shared", js, `{}`, http.StatusCreated, true, 8},
+				{"binary bodies pass through", http.MethodPut, "/upload", "k4", "application/octet-stream", "raw", http.StatusCreated, false, 9},
+				{"binary bodies pass through again", http.MethodPut, "/upload", "k4", "application/octet-stream", "raw", http.StatusCreated, false, 10},
+			}
+			var first string
+			for i, st := range steps {
+				w := send(st.method, st.path, st.key, st.contentType, st.body)
+				assert.Equal(t, st.status, w.Code, st.name)
+				assert.Equal(t, st.replayed, w.Header().Get("Idempotent-Replayed") == "true", st.name)
+				assert.Equal(t, st.calls, calls, st.name)
+				if i == 0 {
+					first = w.Body.String()
+				}
+				if i == 1 {
+					assert.Equal(t, first, w.Body.String())
+					assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+				}
+			}
+
+			// A concurrent duplicate sees the reservation of the running request.
+			_, created, err := store.Reserve("busy", "fp", time.Hour)
+			require.NoError(t, err)
+			require.True(t, created)
+			rec, created, err := store.Reserve("busy", "fp", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, created)
+			assert.Equal(t, 0, rec.Status)
+			// Expired keys can be claimed again.
+			_, created, err = store.Reserve("short", "fp", -time.Second)
+			require.NoError(t, err)
+			require.True(t, created)
+			_, created, err = store.Reserve("short", "other", time.Hour)
+			require.NoError(t, err)
+			assert.True(t, created)
+		})
+	}
+}
+
+func TestIdempotency_InProgressAndTooLong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	idem := middlewares.NewIdempotency(middlewares.NewMemoryIdempotencyStore(), time.Hour)
+	r := gin.New()
+	r.POST("/slow", idem.Middleware(), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/slow", nil)
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send("slow") }()
+	<-started
+	assert.Equal(t, http.StatusConflict, send("slow").Code)
+	close(release)
+	assert.Equal(t, http.StatusNoContent, (<-done).Code)
+	w := send("slow")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+
+	assert.Equal(t, http.StatusBadRequest, send(string(bytes.Repeat([]byte("k"), 256))).Code)
+}
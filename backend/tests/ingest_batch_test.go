@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestIngestBatch_OfflineQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|offline", Email: "offline@example.com", Username: "offline"}
+	require.NoError(t, db.Create(&u).Error)
+	_, otherProject := seedProducer(t, db, "someone", "Reason", "")
+
+	ctl := controllers.NewIngestController(db)
+	r := gin.New()
+	r.POST("/ingest/batch", asUser(u.ID), ctl.Batch)
+	post := func(body interface{}) (int, services.BatchResult) {
+		buf, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(buf)))
+		var res services.BatchResult
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		}
+		return w.Code, res
+	}
+
+	start := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
+	queue := []gin.H{
+		{"type": "project", "key": "k1", "at": start, "project": gin.H{"title": "Offline Jam", "daw": "Reaper"}},
+		{"type": "plugin", "key": "k2", "at": start, "projectTitle": "Offline Jam", "plugin": gin.H{"name": "ReaEQ", "version": "7.0"}},
+		{"type": "project", "key": "k3", "at": start.Add(10 * time.Minute), "project": gin.H{"title": "Offline Jam", "daw": "Reaper"}},
+		{"type": "plugin", "key": "k4", "projectTitle": "Never Created", "plugin": gin.H{"name": "ReaComp"}},
+		{"type": "complete", "key": "k5", "projectId": otherProject.ID},
+		{"type": "bogus", "key": "k6"},
+		{"type": "complete", "key": "k7", "at": start.Add(12 * time.Minute), "projectTitle": "Offline Jam"},
+	}
+	code, res := post(gin.H{"items": queue})
+	require.Equal(t, http.StatusOK, code)
+	statuses := []string{}
+	for _, it := range res.Results {
+		statuses = append(statuses, it.Status)
+	}
+	assert.Equal(t, []string{"applied", "applied", "applied", "failed", "failed", "failed", "applied"}, statuses)
+	assert.Equal(t, "project not found", res.Results[3].Error)
+	assert.Equal(t, "project not found", res.Results[4].Error, "other users' projects are not reachable")
+	assert.Equal(t, 4, res.Applied)
+	assert.Equal(t, 3, res.Failed)
+
+	var p models.Project
+	require.NoError(t, db.Where("user_id = ? AND title = ?", u.ID, "Offline Jam").First(&p).Error)
+	assert.Equal(t, res.Results[0].ProjectID, p.ID)
+	assert.Equal(t, 600, p.DurationSeconds, "buffered timestamps drive the session timeline")
+	assert.Equal(t, models.StatusComplete, p.Status)
+	require.NotNil(t, p.CompletedAt)
+	assert.True(t, p.CompletedAt.Equal(start.Add(12*time.Minute)))
+	var pl models.Plugin
+	require.NoError(t, db.Where("project_id = ?", p.ID).First(&pl).Error)
+	assert.Equal(t, res.Results[1].PluginID, pl.ID)
+
+	var foreign models.Project
+	require.NoError(t, db.First(&foreign, otherProject.ID).Error)
+	assert.Equal(t, models.StatusInProgress, foreign.Status)
+
+	// Re-sending the queue after a lost response applies nothing twice; failed items retry.
+	code, res = post(gin.H{"items": queue})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4, res.Duplicates)
+	assert.Equal(t, 3, res.Failed)
+	assert.Equal(t, p.ID, res.Results[0].ProjectID)
+	var beats int64
+	require.NoError(t, db.Model(&models.ProjectHeartbeat{}).Where("project_id = ?", p.ID).Count(&beats).Error)
+	assert.Equal(t, int64(2), beats)
+
+	// Keys are unique per user, also within one batch.
+	code, res = post(gin.H{"items": []gin.H{
+		{"type": "project", "key": "dup", "project": gin.H{"title": "Twice"}},
+		{"type": "project", "key": "dup", "project": gin.H{"title": "Twice"}},
+	}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, res.Applied)
+	assert.Equal(t, 1, res.Duplicates)
+
+	code, _ = post(gin.H{"items": []gin.H{}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post(gin.H{})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestIngestBatch_APIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|scoped", Email: "scoped@example.com", Username: "scoped"}
+	require.NoError(t, db.Create(&u).Error)
+	_, key, err := services.NewAPIKeyService(db).Create(u.ID, services.CreateAPIKeyInput{Name: "heartbeats", Scopes: []string{models.ScopeProjectsWrite}})
+	require.NoError(t, err)
+
+	authn := middlewares.NewAuthenticator(db, middlewares.NewJWT("secret"), nil)
+	ctl := controllers.NewIngestController(db)
+	r := gin.New()
+	r.POST("/ingest/batch", authn.RequireUserOrAPIKey(), ctl.Batch)
+
+	cases := []struct {
+		name   string
+		items  []gin.H
+		status int
+	}{
+		{"project items only", []gin.H{{"type": "project", "project": gin.H{"title": "Scoped"}}}, http.StatusOK},
+		{"plugin item needs plugins:write", []gin.H{
+			{"type": "project", "project": gin.H{"title": "Scoped"}},
+			{"type": "plugin", "projectTitle": "Scoped", "plugin": gin.H{"name": "Serum"}},
+		}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		buf, _ := json.Marshal(gin.H{"items": tc.items})
+		req := httptest.NewRequest(http.MethodPost, "/ingest/batch", bytes.NewReader(buf))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+	}
+	var plugins int64
+	require.NoError(t, db.Model(&models.Plugin{}).Count(&plugins).Error)
+	assert.Zero(t, plugins)
+}
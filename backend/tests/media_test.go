@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+func TestMediaController_SignedRangeRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	content := wavBytes(8000, 1, 500)
+	key := "projects/1/audio/track.wav"
+	_, err = store.Put(context.Background(), key, bytes.NewReader(content), "audio/wav")
+	require.NoError(t, err)
+
+	signer := storage.NewSigner(store, "http://api.test", []byte("secret"))
+	ctl := controllers.NewMediaController(store, signer)
+	router := gin.New()
+	router.GET("/media/*key", ctl.Stream)
+
+	get := func(rawURL string, header http.Header) *httptest.ResponseRecorder {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		req, _ := http.NewRequest("GET", u.RequestURI(), nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	signed, err := signer.SignURL(context.Background(), key, time.Minute)
+	require.NoError(t, err)
+
+	t.Run("full body", func(t *testing.T) {
+		w := get(signed, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "audio/wav", w.Header().Get("Content-Type"))
+		assert.Equal(t, content, w.Body.Bytes())
+	})
+
+	t.Run("byte range", func(t *testing.T) {
+		w := get(signed, http.Header{"Range": {"bytes=100-199"}})
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, content[100:200], w.Body.Bytes())
+	})
+
+	t.Run("tampered key", func(t *testing.T) {
+		u, _ := url.Parse(signed)
+		u.Path = "/media/projects/2/audio/track.wav"
+		w := get(u.String(), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired, err := signer.SignURL(context.Background(), key, -time.Minute)
+		require.NoError(t, err)
+		w := get(expired, nil)
+		assert.Equal(t, http.StatusGone, w.Code)
+	})
+}
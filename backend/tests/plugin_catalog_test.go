@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func catalogOf(t *testing.T, db *gorm.DB, projectID uint, name string) models.PluginCatalog {
+	var pl models.Plugin
+	require.NoError(t, db.Preload("Catalog").Where("project_id = ? AND LOWER(name) = LOWER(?)", projectID, name).First(&pl).Error)
+	require.NotNil(t, pl.Catalog, name)
+	return *pl.Catalog
+}
+
+func TestPluginCatalog_Normalization(t *testing.T) {
+	db := setupMigratedDB(t)
+	u, p := seedProducer(t, db, "normal", "Bitwig", "")
+	svc := services.NewPluginService(db)
+
+	cases := []struct {
+		name, vendor string
+		slug         string
+		display      string
+		catVendor    string
+	}{
+		{"Serum", "Xfer", "serum", "Serum", "Xfer Records"},
+		{"serum", "", "serum", "Serum", "Xfer Records"},
+		{"Serum x64", "Xfer Records, Inc.", "serum", "Serum", "Xfer Records"},
+		{"Serum (VST3)", "xfer records", "serum", "Serum", "Xfer Records"},
+		{"Pro-Q 3", "FabFilter", "pro-q-3", "Pro-Q 3", "FabFilter"},
+		{"FabFilter Pro-Q 3", "fabfilter", "pro-q-3", "Pro-Q 3", "FabFilter"},
+		{"Pro-Q 3 64-bit", "", "pro-q-3", "Pro-Q 3", "FabFilter"},
+		{"ValhallaRoom", "ValhallaDSP, LLC", "valhallaroom", "ValhallaRoom", "Valhalla DSP"},
+		{"Serum FX", "Xfer", "serum-fx", "Serum FX", "Xfer Records"},
+	}
+	for _, tc := range cases {
+		_, err := svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: tc.name, Vendor: tc.vendor})
+		require.NoError(t, err, tc.name)
+		c := catalogOf(t, db, p.ID, tc.name)
+		assert.Equal(t, tc.slug, c.Slug, tc.name)
+		assert.Equal(t, tc.display, c.Name, tc.name)
+		assert.Equal(t, tc.catVendor, c.Vendor, tc.name)
+	}
+	var entries int64
+	require.NoError(t, db.Model(&models.PluginCatalog{}).Count(&entries).Error)
+	assert.Equal(t, int64(4), entries)
+
+	assert.Equal(t, "Native Instruments", services.NormalizeVendor("  Native   Instruments GmbH "))
+	assert.Equal(t, "Some Small Dev", services.NormalizeVendor("Some Small Dev"))
+}
+
+func TestPluginCatalog_UsageStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	plugins := services.NewPluginService(db)
+	catalog := services.NewPluginCatalogService(db)
+
+	// Legacy rows recorded before the catalog existed get linked by LinkUnlinked.
+	alice, aliceBeat := seedProducer(t, db, "alice", "FL Studio", "Serum x64")
+	bob, bobBeat := seedProducer(t, db, "bob", "Ableton Live", "")
+	catalog.LinkUnlinked()
+	assert.Equal(t, "serum", catalogOf(t, db, aliceBeat.ID, "Serum x64").Slug)
+
+	for _, name := range []string{"serum", "OTT"} {
+		_, err := plugins.UpsertByName(bob.ID, bobBeat.ID, services.UpsertPluginInput{Name: name})
+		require.NoError(t, err)
+	}
+	second := models.Project{UserID: alice.ID, Title: "second", Public: true}
+	require.NoError(t, db.Create(&second).Error)
+	_, err := plugins.UpsertByName(alice.ID, second.ID, services.UpsertPluginInput{Name: "Serum"})
+	require.NoError(t, err)
+	hidden := models.Project{UserID: alice.ID, Title: "private", Public: false}
+	require.NoError(t, db.Create(&hidden).Error)
+	_, err = plugins.UpsertByName(alice.ID, hidden.ID, services.UpsertPluginInput{Name: "Decapitator"})
+	require.NoError(t, err)
+	// Bob's project went quiet last month, and he dropped OTT from it.
+	require.NoError(t, db.Model(&models.Project{}).Where("id = ?", bobBeat.ID).UpdateColumn("updated_at", services.MonthStart(time.Now()).Add(-time.Hour)).Error)
+	_, err = plugins.Sync(bob.ID, bobBeat.ID, []services.UpsertPluginInput{{Name: "serum"}})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&models.Project{}).Where("id = ?", bobBeat.ID).UpdateColumn("updated_at", services.MonthStart(time.Now()).Add(-time.Hour)).Error)
+
+	profCtl := controllers.NewProfileController(db, "secret", nil)
+	catCtl := controllers.NewPluginCatalogController(db)
+	r := gin.New()
+	r.GET("/profiles/:handle/plugins", profCtl.GetPublicPlugins)
+	r.GET("/plugins/top", catCtl.Top)
+	r.GET("/plugins/:slug", catCtl.Get)
+	get := func(path string, out interface{}) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if out != nil && w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+		}
+		return w.Code
+	}
+	usage := func(items []services.PluginUsage) map[string][2]int64 {
+		m := map[string][2]int64{}
+		for _, it := range items {
+			m[it.Slug] = [2]int64{it.Projects, it.Producers}
+		}
+		return m
+	}
+
+	var top []services.PluginUsage
+	require.Equal(t, http.StatusOK, get("/plugins/top", &top))
+	assert.Equal(t, map[string][2]int64{"serum": {2, 1}, "decapitator": {1, 1}}, usage(top))
+	assert.Equal(t, "serum", top[0].Slug)
+
+	require.Equal(t, http.StatusOK, get("/plugins/top?period=all", &top))
+	assert.Equal(t, map[string][2]int64{"serum": {3, 2}, "decapitator": {1, 1}}, usage(top))
+	assert.Equal(t, http.StatusBadRequest, get("/plugins/top?period=decade", nil))
+
+	var mine []services.PluginUsage
+	require.Equal(t, http.StatusOK, get("/profiles/alice/plugins", &mine))
+	assert.Equal(t, map[string][2]int64{"serum": {2, 1}}, usage(mine), "private projects stay private")
+	assert.Equal(t, http.StatusNotFound, get("/profiles/nobody/plugins", nil))
+
+	var st services.PluginStats
+	require.Equal(t, http.StatusOK, get("/plugins/Serum%20x64", &st))
+	assert.Equal(t, "serum", st.Catalog.Slug)
+	assert.Equal(t, int64(3), st.Projects)
+	assert.Equal(t, int64(2), st.Producers)
+	assert.Equal(t, int64(2), st.ProjectsThisMonth)
+	assert.Equal(t, http.StatusNotFound, get("/plugins/unknown-thing", nil))
+}
+
+func TestPluginCatalog_AliasesAndMerge(t *testing.T) {
+	db := setupMigratedDB(t)
+	u, p := seedProducer(t, db, "curator", "Logic Pro", "")
+	plugins := services.NewPluginService(db)
+	catalog := services.NewPluginCatalogService(db)
+	for _, name := range []string{"Kontakt 7", "Kontakt", "Massive X", "MassiveX"} {
+		_, err := plugins.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: name, Vendor: "NI"})
+		require.NoError(t, err)
+	}
+	kontakt := catalogOf(t, db, p.ID, "Kontakt")
+
+	// Mapping a spelling that had its own entry folds that entry in.
+	entry, err := catalog.AddAlias(kontakt.ID, "Kontakt 7")
+	require.NoError(t, err)
+	assert.Len(t, entry.Aliases, 2)
+	assert.Equal(t, kontakt.ID, catalogOf(t, db, p.ID, "Kontakt 7").ID)
+
+	// Future reports of a new alias resolve to the entry.
+	_, err = catalog.AddAlias(kontakt.ID, "Kontakt Player")
+	require.NoError(t, err)
+	_, err = plugins.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Kontakt Player"})
+	require.NoError(t, err)
+	assert.Equal(t, kontakt.ID, catalogOf(t, db, p.ID, "Kontakt Player").ID)
+
+	massive := catalogOf(t, db, p.ID, "Massive X")
+	massiveX := catalogOf(t, db, p.ID, "MassiveX")
+	require.NotEqual(t, massive.ID, massiveX.ID)
+	merged, err := catalog.Merge(massive.ID, massiveX.ID)
+	require.NoError(t, err)
+	assert.Len(t, merged.Aliases, 2)
+	assert.Equal(t, massive.ID, catalogOf(t, db, p.ID, "MassiveX").ID)
+	assert.Error(t, db.First(&models.PluginCatalog{}, massiveX.ID).Error)
+
+	_, err = catalog.Merge(massive.ID, massive.ID)
+	assert.Error(t, err)
+	_, err = catalog.AddAlias(kontakt.ID, "  ")
+	assert.Error(t, err)
+
+	items, total, err := catalog.List(services.CatalogFilter{Query: "kont"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "Native Instruments", items[0].Vendor)
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestPluginHistory_UpsertAndSync(t *testing.T) {
+	db := setupMigratedDB(t)
+	u, p := seedProducer(t, db, "mixer", "Ableton Live", "")
+	svc := services.NewPluginService(db)
+
+	_, err := svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Serum", Vendor: "Xfer", Version: "1.3"})
+	require.NoError(t, err)
+	_, err = svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Serum", Vendor: "Xfer", Version: "1.3"})
+	require.NoError(t, err)
+	_, err = svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Serum", Vendor: "Xfer", Version: "1.4"})
+	require.NoError(t, err)
+	_, err = svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Pro-Q 3", Vendor: "FabFilter", Version: "3.2"})
+	require.NoError(t, err)
+
+	res, err := svc.Sync(u.ID, p.ID, []services.UpsertPluginInput{
+		{Name: "Serum", Vendor: "Xfer", Version: "1.4"},
+		{Name: "Valhalla Room", Vendor: "Valhalla DSP", Version: "2.0"},
+	})
+	require.NoError(t, err)
+	names := []string{}
+	for _, pl := range res.Plugins {
+		names = append(names, pl.Name)
+	}
+	assert.Equal(t, []string{"Serum", "Valhalla Room"}, names)
+	require.Len(t, res.Events, 2)
+	assert.Equal(t, models.PluginAdded, res.Events[0].Kind)
+	assert.Equal(t, "Valhalla Room", res.Events[0].Name)
+	assert.Equal(t, models.PluginRemoved, res.Events[1].Kind)
+	assert.Equal(t, "Pro-Q 3", res.Events[1].Name)
+
+	// The removed plugin is hidden from listings but kept for history.
+	listed, err := svc.ListByProject(u.ID, p.ID)
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+	var removed models.Plugin
+	require.NoError(t, db.Where("name = ?", "Pro-Q 3").First(&removed).Error)
+	assert.NotNil(t, removed.RemovedAt)
+
+	// Coming back counts as added again.
+	_, err = svc.UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: "Pro-Q 3", Vendor: "FabFilter", Version: "3.2"})
+	require.NoError(t, err)
+	var revived models.Plugin
+	require.NoError(t, db.First(&revived, removed.ID).Error)
+	assert.Nil(t, revived.RemovedAt)
+
+	history, err := svc.History(u.ID, p.ID)
+	require.NoError(t, err)
+	type entry struct {
+		kind     models.PluginEventKind
+		name     string
+		version  string
+		previous string
+	}
+	var got []entry
+	for _, ev := range history {
+		got = append(got, entry{ev.Kind, ev.Name, ev.Version, ev.PreviousVersion})
+	}
+	assert.Equal(t, []entry{
+		{models.PluginAdded, "Pro-Q 3", "3.2", ""},
+		{models.PluginRemoved, "Pro-Q 3", "3.2", ""},
+		{models.PluginAdded, "Valhalla Room", "2.0", ""},
+		{models.PluginAdded, "Pro-Q 3", "3.2", ""},
+		{models.PluginVersionChanged, "Serum", "1.4", "1.3"},
+		{models.PluginAdded, "Serum", "1.3", ""},
+	}, got)
+
+	// A sync that changes nothing logs nothing.
+	res, err = svc.Sync(u.ID, p.ID, []services.UpsertPluginInput{
+		{Name: "Serum", Vendor: "Xfer", Version: "1.4"},
+		{Name: "Valhalla Room", Vendor: "Valhalla DSP", Version: "2.0"},
+		{Name: "Pro-Q 3", Vendor: "FabFilter", Version: "3.2"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, res.Events)
+
+	_, err = svc.Sync(u.ID, p.ID, []services.UpsertPluginInput{{Name: "Serum"}, {Name: "Serum"}})
+	assert.Error(t, err)
+	_, err = svc.Sync(u.ID+1, p.ID, nil)
+	assert.Error(t, err)
+}
+
+func TestPluginHistory_SyncEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	// Plugin names are unique per project ignoring case, as created by migrations/001_init.sql.
+	require.NoError(t, db.Exec("DROP INDEX idx_project_name").Error)
+	for _, stmt := range migrationStatements(t, "001_init.sql", "CREATE UNIQUE INDEX IF NOT EXISTS idx_project_name") {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+	u, p := seedProducer(t, db, "beatsmith", "FL Studio", "Serum")
+	ctl := controllers.NewPluginController(db)
+	r := gin.New()
+	r.PUT("/ingest/projects/:id/plugins", asUser(u.ID), ctl.SyncForProject)
+	r.GET("/app/projects/:id/plugins/history", asUser(u.ID), ctl.History)
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		events int
+	}{
+		{"missing list", `{}`, http.StatusBadRequest, 0},
+		{"nameless plugin", `{"plugins":[{"vendor":"Xfer"}]}`, http.StatusBadRequest, 0},
+		{"listed twice in another case", `{"plugins":[{"name":"Serum"},{"name":"serum"}]}`, http.StatusBadRequest, 0},
+		{"another case is the same plugin", `{"plugins":[{"name":"SERUM","version":"1.4"}]}`, http.StatusOK, 1},
+		{"replace the set", `{"plugins":[{"name":"Sylenth1","version":"3.0"}]}`, http.StatusOK, 2},
+		{"empty list removes everything", `{"plugins":[]}`, http.StatusOK, 1},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/ingest/projects/"+itoa(p.ID)+"/plugins", bytes.NewBufferString(tc.body)))
+		require.Equal(t, tc.status, w.Code, tc.name+": "+w.Body.String())
+		if tc.status != http.StatusOK {
+			continue
+		}
+		var res services.SyncResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Len(t, res.Events, tc.events, tc.name)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/projects/"+itoa(p.ID)+"/plugins/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []models.PluginEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	// Serum was seeded without a log entry, so only the sync events appear.
+	assert.Len(t, history, 4)
+	assert.Equal(t, models.PluginRemoved, history[0].Kind)
+	assert.Equal(t, "Sylenth1", history[0].Name)
+}
@@ -0,0 +1,318 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+func TestSocialLinks_Normalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		norm    func(string) (string, error)
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"soundcloud url", services.NormalizeSoundCloudLink, "https://soundcloud.com/beatsmith/", "https://soundcloud.com/beatsmith", false},
+		{"soundcloud without scheme", services.NormalizeSoundCloudLink, "m.soundcloud.com/beatsmith", "https://soundcloud.com/beatsmith", false},
+		{"soundcloud bare handle", services.NormalizeSoundCloudLink, "@beatsmith", "https://soundcloud.com/beatsmith", false},
+		{"soundcloud track is not a profile", services.NormalizeSoundCloudLink, "https://soundcloud.com/beatsmith/track-1", "", true},
+		{"soundcloud other host", services.NormalizeSoundCloudLink, "https://soundcloud.com.evil.example/beatsmith", "", true},
+		{"youtube handle url", services.NormalizeYouTubeLink, "youtube.com/@BeatSmith", "https://www.youtube.com/@BeatSmith", false},
+		{"youtube bare handle", services.NormalizeYouTubeLink, "@BeatSmith", "https://www.youtube.com/@BeatSmith", false},
+		{"youtube channel", services.NormalizeYouTubeLink, "https://m.youtube.com/channel/UC1234567890abcdefghijKL", "https://www.youtube.com/channel/UC1234567890abcdefghijKL", false},
+		{"youtube legacy user", services.NormalizeYouTubeLink, "http://www.youtube.com/user/beatsmith", "https://www.youtube.com/user/beatsmith", false},
+		{"youtube video is not a profile", services.NormalizeYouTubeLink, "https://www.youtube.com/watch?v=abc", "", true},
+		{"youtube name without @", services.NormalizeYouTubeLink, "beatsmith", "", true},
+		{"instagram url", services.NormalizeInstagramLink, "https://instagram.com/beat.smith", "https://www.instagram.com/beat.smith", false},
+		{"instagram javascript", services.NormalizeInstagramLink, "javascript://instagram.com/x", "", true},
+		{"empty removes", services.NormalizeInstagramLink, "  ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.norm(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProfileController_UpdateMe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	me, _ := seedProducer(t, db, "beatsmith", "FL Studio", "")
+	seedProducer(t, db, "taken", "FL Studio", "")
+
+	ctl := controllers.NewProfileController(db, "secret", nil)
+	r := gin.New()
+	r.GET("/me", asUser(me.ID), ctl.GetMe)
+	r.PATCH("/me", asUser(me.ID), ctl.UpdateMe)
+	r.GET("/profiles/:handle", ctl.GetPublicProfile)
+	r.GET("/profiles/:handle/projects", ctl.GetPublicProjects)
+
+	patch := func(body string) (int, models.User) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/me", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var u models.User
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &u))
+		}
+		return w.Code, u
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		check  func(t *testing.T, u models.User)
+	}{
+		{"fields", `{"displayName":" Beat Smith ","bio":"Lo-fi from Lisbon"}`, http.StatusOK, func(t *testing.T, u models.User) {
+			assert.Equal(t, "Beat Smith", u.DisplayName)
+			assert.Equal(t, "Lo-fi from Lisbon", u.Bio)
+			assert.Equal(t, "beatsmith", u.Username)
+		}},
+		{"bio too long", `{"bio":"` + strings.Repeat("é", 281) + `"}`, http.StatusBadRequest, nil},
+		{"links", `{"links":{"soundcloud":"soundcloud.com/beatsmith","youtube":"@beatsmith"}}`, http.StatusOK, func(t *testing.T, u models.User) {
+			assert.Equal(t, "https://soundcloud.com/beatsmith", u.Links.SoundCloud)
+			assert.Equal(t, "https://www.youtube.com/@beatsmith", u.Links.YouTube)
+		}},
+		{"invalid link changes nothing", `{"bio":"changed","links":{"instagram":"https://example.com/x"}}`, http.StatusBadRequest, nil},
+		{"remove one link", `{"links":{"youtube":""}}`, http.StatusOK, func(t *testing.T, u models.User) {
+			assert.Equal(t, "https://soundcloud.com/beatsmith", u.Links.SoundCloud)
+			assert.Empty(t, u.Links.YouTube)
+			assert.Equal(t, "Lo-fi from Lisbon", u.Bio)
+		}},
+		{"reserved handle", `{"username":"admin"}`, http.StatusBadRequest, nil},
+		{"invalid handle", `{"username":"no spaces"}`, http.StatusBadRequest, nil},
+		{"too short handle", `{"username":"ab"}`, http.StatusBadRequest, nil},
+		{"taken handle", `{"username":"Taken"}`, http.StatusConflict, nil},
+		{"change handle", `{"username":"@Smith_Beats"}`, http.StatusOK, func(t *testing.T, u models.User) {
+			assert.Equal(t, "smith_beats", u.Username)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, u := patch(tt.body)
+			require.Equal(t, tt.status, status)
+			if tt.check != nil {
+				tt.check(t, u)
+			}
+		})
+	}
+
+	// The link columns come from the embedded struct; migrations/021_profiles.sql names them the same.
+	var sc string
+	require.NoError(t, db.Raw("SELECT link_sound_cloud FROM users WHERE id = ?", me.ID).Scan(&sc).Error)
+	assert.Equal(t, "https://soundcloud.com/beatsmith", sc)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/beatsmith/projects?limit=5", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/profiles/smith_beats/projects?limit=5", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/smith_beats", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var prof struct {
+		User struct {
+			Username string             `json:"username"`
+			Links    models.SocialLinks `json:"links"`
+		} `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prof))
+	assert.Equal(t, "smith_beats", prof.User.Username)
+	assert.Equal(t, "https://soundcloud.com/beatsmith", prof.User.Links.SoundCloud)
+
+	// The old handle is held for its owner: others cannot claim it, the owner can go back.
+	other, _ := seedProducer(t, db, "other", "FL Studio", "")
+	otherSvc := services.NewProfileService(db)
+	_, err := otherSvc.Update(other.ID, services.ProfileUpdate{Username: strPtr("beatsmith")})
+	assert.ErrorIs(t, err, services.ErrHandleTaken)
+
+	status, u := patch(`{"username":"beatsmith"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "beatsmith", u.Username)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/smith_beats", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/profiles/beatsmith", w.Header().Get("Location"))
+
+	// Once the hold is over the handle is free and the redirect ends.
+	require.NoError(t, db.Model(&models.HandleRedirect{}).Where("handle = ?", "smith_beats").
+		Update("created_at", time.Now().Add(-91*24*time.Hour)).Error)
+	got, err := otherSvc.Update(other.ID, services.ProfileUpdate{Username: strPtr("smith_beats")})
+	require.NoError(t, err)
+	assert.Equal(t, "smith_beats", got.Username)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/smith_beats", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUserService_NewAccountsClaimHandles(t *testing.T) {
+	db := setupMigratedDB(t)
+	users := services.NewUserService(db, "secret")
+	owner, _ := seedProducer(t, db, "beatsmith", "FL Studio", "")
+	_, err := services.NewProfileService(db).Update(owner.ID, services.ProfileUpdate{Username: strPtr("smith_beats")})
+	require.NoError(t, err)
+
+	n := 0
+	create := map[string]func(handle string) (*models.User, error){
+		"register": func(handle string) (*models.User, error) {
+			n++
+			return users.Register("r"+itoa(uint(n))+"@example.com", handle, "password1", "")
+		},
+		"auth0": func(handle string) (*models.User, error) {
+			n++
+			return users.SyncAuth0User("auth0|"+itoa(uint(n)), "a"+itoa(uint(n))+"@example.com", handle, "", "", "")
+		},
+	}
+	tests := []struct {
+		name    string
+		handle  string
+		wantErr error
+	}{
+		{"reserved", "admin", services.ErrHandleReserved},
+		{"reserved in capitals", "Support", services.ErrHandleReserved},
+		{"invalid", "no spaces", services.ErrInvalidHandle},
+		{"held by a redirect", "beatsmith", services.ErrHandleTaken},
+		{"live", "smith_beats", services.ErrHandleTaken},
+	}
+	for via, fn := range create {
+		for _, tt := range tests {
+			t.Run(via+"/"+tt.name, func(t *testing.T) {
+				_, err := fn(tt.handle)
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	}
+
+	// The old handle still leads to its owner.
+	got, moved, err := users.ResolvePublicHandle("beatsmith")
+	require.NoError(t, err)
+	assert.True(t, moved)
+	assert.Equal(t, owner.ID, got.ID)
+
+	// Once the hold is over a new account may take it, and the redirect ends.
+	require.NoError(t, db.Model(&models.HandleRedirect{}).Where("handle = ?", "beatsmith").
+		Update("created_at", time.Now().Add(-91*24*time.Hour)).Error)
+	u, err := create["auth0"]("@BeatSmith")
+	require.NoError(t, err)
+	assert.Equal(t, "beatsmith", u.Username)
+	got, moved, err = users.ResolvePublicHandle("beatsmith")
+	require.NoError(t, err)
+	assert.False(t, moved)
+	assert.Equal(t, u.ID, got.ID)
+}
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestProfileController_Avatar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	me, _ := seedProducer(t, db, "beatsmith", "FL Studio", "")
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctl := controllers.NewProfileController(db, "secret", nil)
+	ctl.Me.Store = store
+	ctl.Me.AvatarBaseURL = "https://api.example.com/avatars"
+	r := gin.New()
+	r.POST("/me/avatar", asUser(me.ID), ctl.UploadAvatar)
+	r.DELETE("/me/avatar", asUser(me.ID), ctl.DeleteAvatar)
+	r.GET("/avatars/:name", ctl.Avatar)
+
+	upload := func(content []byte) (int, models.User) {
+		body, ctype := multipartBody(t, "file", "me.png", content)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/me/avatar", body)
+		req.Header.Set("Content-Type", ctype)
+		r.ServeHTTP(w, req)
+		var u models.User
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &u))
+		}
+		return w.Code, u
+	}
+
+	status, _ := upload([]byte("definitely not an image"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+
+	status, u := upload(pngBytes(t, 600, 400))
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, strings.HasPrefix(u.Picture, "https://api.example.com/avatars/"), u.Picture)
+	first := strings.TrimPrefix(u.Picture, "https://api.example.com/avatars/")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/avatars/"+first, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "immutable")
+	img, err := jpeg.Decode(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, services.AvatarSize, services.AvatarSize), img.Bounds())
+
+	// Auth0 logins no longer replace the uploaded picture or an edited display name.
+	_, err = services.NewProfileService(db).Update(me.ID, services.ProfileUpdate{DisplayName: strPtr("Beat Smith")})
+	require.NoError(t, err)
+	synced, err := services.NewUserService(db, "secret").SyncAuth0User(me.Auth0ID, "NEW@example.com", "beatsmith", "bs", "https://cdn.auth0.com/pic.png", "")
+	require.NoError(t, err)
+	var stored models.User
+	require.NoError(t, db.First(&stored, synced.ID).Error)
+	assert.Equal(t, u.Picture, stored.Picture)
+	assert.Equal(t, "Beat Smith", stored.DisplayName)
+	assert.Equal(t, "new@example.com", stored.Email)
+
+	// A new upload replaces the blob of the old one.
+	status, u = upload(pngBytes(t, 64, 64))
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, u.Picture, first)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/avatars/"+first, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/avatars/..%2Fsecret.jpg", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/me/avatar", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = services.NewUserService(db, "secret").SyncAuth0User(me.Auth0ID, "new@example.com", "beatsmith", "bs", "https://cdn.auth0.com/pic.png", "")
+	require.NoError(t, err)
+	require.NoError(t, db.First(&stored, me.ID).Error)
+	assert.Equal(t, "https://cdn.auth0.com/pic.png", stored.Picture)
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestProjectCRUD_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	withProjectTitleMigrations(t, db)
+	ctl := controllers.NewProjectController(db, nil)
+	r := gin.New()
+	r.POST("/ingest/projects", asUser(1), ctl.Upsert)
+	r.GET("/app/projects", asUser(1), ctl.ListMine)
+	r.GET("/app/projects/:id", asUser(1), ctl.Get)
+	r.PATCH("/app/projects/:id", asUser(1), ctl.Update)
+	r.DELETE("/app/projects/:id", asUser(1), ctl.Delete)
+	r.POST("/app/projects/:id/restore", asUser(1), ctl.Restore)
+	r.PATCH("/other/projects/:id", asUser(2), ctl.Update)
+	r.DELETE("/other/projects/:id", asUser(2), ctl.Delete)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, &buf))
+		return w
+	}
+	upsert := func(title string) models.Project {
+		w := send(http.MethodPost, "/ingest/projects", gin.H{"title": title, "daw": "Ableton Live"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var p models.Project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		return p
+	}
+	beat := upsert("untitled 3")
+	other := upsert("Other Song")
+	id := itoa(beat.ID)
+	require.NoError(t, db.Create(&models.Plugin{ProjectID: beat.ID, Name: "Serum"}).Error)
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+		check  func(p models.Project)
+	}{
+		{"get", http.MethodGet, "/app/projects/" + id, nil, http.StatusOK, func(p models.Project) {
+			assert.Equal(t, "untitled 3", p.Title)
+			assert.Len(t, p.Plugins, 1)
+		}},
+		{"rename", http.MethodPatch, "/app/projects/" + id, gin.H{"title": "  Night Drive "}, http.StatusOK, func(p models.Project) {
+			assert.Equal(t, beat.ID, p.ID)
+			assert.Equal(t, "Night Drive", p.Title)
+		}},
+		{"empty title", http.MethodPatch, "/app/projects/" + id, gin.H{"title": " "}, http.StatusBadRequest, nil},
+		{"publish and complete", http.MethodPatch, "/app/projects/" + id, gin.H{"public": true, "status": "complete"}, http.StatusOK, func(p models.Project) {
+			assert.True(t, p.Public)
+			assert.Equal(t, models.StatusComplete, p.Status)
+			assert.NotNil(t, p.CompletedAt)
+		}},
+		{"reopen", http.MethodPatch, "/app/projects/" + id, gin.H{"status": "in_progress"}, http.StatusOK, func(p models.Project) {
+			assert.Equal(t, models.StatusInProgress, p.Status)
+			assert.Nil(t, p.CompletedAt)
+			assert.True(t, p.Public, "untouched fields stay")
+		}},
+		{"bad status", http.MethodPatch, "/app/projects/" + id, gin.H{"status": "archived"}, http.StatusBadRequest, nil},
+		{"someone else edits", http.MethodPatch, "/other/projects/" + id, gin.H{"title": "Mine now"}, http.StatusNotFound, nil},
+		{"someone else deletes", http.MethodDelete, "/other/projects/" + id, nil, http.StatusNotFound, nil},
+		{"delete", http.MethodDelete, "/app/projects/" + id, nil, http.StatusNoContent, nil},
+		{"gone after delete", http.MethodGet, "/app/projects/" + id, nil, http.StatusNotFound, nil},
+		{"delete twice", http.MethodDelete, "/app/projects/" + id, nil, http.StatusNotFound, nil},
+		{"restore", http.MethodPost, "/app/projects/" + id + "/restore", nil, http.StatusOK, func(p models.Project) {
+			assert.Equal(t, "Night Drive", p.Title)
+			assert.Len(t, p.Plugins, 1, "plugins survive the trash")
+		}},
+		{"restore a live project", http.MethodPost, "/app/projects/" + id + "/restore", nil, http.StatusNotFound, nil},
+	}
+	for _, st := range steps {
+		w := send(st.method, st.path, st.body)
+		require.Equal(t, st.status, w.Code, "%s: %s", st.name, w.Body.String())
+		if st.check != nil {
+			var p models.Project
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			st.check(p)
+		}
+	}
+
+	// Title-only heartbeats under the new name land on the renamed project instead of forking it.
+	assert.Equal(t, beat.ID, upsert("Night Drive").ID)
+
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/app/projects/"+itoa(other.ID), nil).Code)
+	w := send(http.MethodGet, "/app/projects?deleted=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var trash services.Page[models.Project]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	require.Len(t, trash.Items, 1)
+	assert.Equal(t, other.ID, trash.Items[0].ID)
+	assert.True(t, trash.Items[0].DeletedAt.Valid)
+
+	// A title-only heartbeat for a trashed title starts a new project; titles may repeat, so
+	// the old one can still be restored next to it.
+	fresh := upsert("Other Song")
+	assert.NotEqual(t, other.ID, fresh.ID)
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/app/projects/"+itoa(other.ID)+"/restore", nil).Code)
+
+	w = send(http.MethodGet, "/app/projects", nil)
+	var live services.Page[models.Project]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &live))
+	assert.Len(t, live.Items, 3)
+
+	// Renaming onto a title the user already has is allowed too.
+	w = send(http.MethodPatch, "/app/projects/"+id, gin.H{"title": "other SONG"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var renamed models.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &renamed))
+	assert.Equal(t, "other SONG", renamed.Title)
+}
+
+func TestProjectDelete_HiddenFromPublicViews(t *testing.T) {
+	db := setupMigratedDB(t)
+	u, p := seedProducer(t, db, "ghost", "FL Studio", "Serum")
+	projects := services.NewProjectService(db)
+	catalog := services.NewPluginCatalogService(db)
+	catalog.LinkUnlinked()
+
+	used, err := catalog.ByProducer(u.ID)
+	require.NoError(t, err)
+	require.Len(t, used, 1)
+
+	require.NoError(t, projects.Delete(u.ID, p.ID))
+	public, err := projects.ListPublicByUser(u.ID, services.ProjectFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, public.Items)
+	used, err = catalog.ByProducer(u.ID)
+	require.NoError(t, err)
+	assert.Empty(t, used)
+
+	// Moderators still hard-delete, trashed or not.
+	require.NoError(t, services.NewAdminService(db).DeleteProject(p.ID))
+	var n int64
+	require.NoError(t, db.Unscoped().Model(&models.Project{}).Where("id = ?", p.ID).Count(&n).Error)
+	assert.Zero(t, n)
+}
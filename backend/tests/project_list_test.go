@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestProjectList_PagingFiltersAndSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	u := models.User{Auth0ID: "test|lister", Email: "lister@example.com", Username: "lister", Public: true}
+	require.NoError(t, db.Create(&u).Error)
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	seed := []struct {
+		title    string
+		day      int
+		daw      string
+		status   models.ProjectStatus
+		public   bool
+		duration int
+		plugin   string
+	}{
+		{"Alpha", 0, "Ableton Live", models.StatusComplete, true, 300, "Serum"},
+		{"bravo", 1, "FL Studio", models.StatusInProgress, false, 100, "Serum (x64)"},
+		{"Charlie", 2, "Ableton Live", models.StatusInProgress, true, 900, "Pro-Q 3"},
+		{"Delta", 2, "Logic Pro", models.StatusComplete, true, 50, ""}, // same created_at as Charlie
+		{"Echo", 3, "ableton live", models.StatusInProgress, false, 600, "Serum"},
+		{"Foxtrot", 5, "FL Studio", models.StatusInProgress, true, 0, ""},
+	}
+	ids := map[string]uint{}
+	for _, sp := range seed {
+		at := base.AddDate(0, 0, sp.day)
+		p := models.Project{UserID: u.ID, Title: sp.title, DAW: sp.daw, Status: sp.status, Public: sp.public,
+			DurationSeconds: sp.duration, CreatedAt: at, UpdatedAt: at}
+		require.NoError(t, db.Create(&p).Error)
+		if sp.plugin != "" {
+			_, err := services.NewPluginService(db).UpsertByName(u.ID, p.ID, services.UpsertPluginInput{Name: sp.plugin})
+			require.NoError(t, err)
+		}
+		ids[sp.title] = p.ID
+	}
+
+	ctl := controllers.NewProjectController(db, nil)
+	prof := controllers.NewProfileController(db, "secret", nil)
+	r := gin.New()
+	r.GET("/app/projects", asUser(u.ID), ctl.ListMine)
+	r.GET("/profiles/:handle/projects", prof.GetPublicProjects)
+	r.GET("/profiles/:handle", prof.GetPublicProfile)
+
+	get := func(path string, q url.Values) (int, services.Page[models.Project]) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?"+q.Encode(), nil))
+		var page services.Page[models.Project]
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		}
+		return w.Code, page
+	}
+	// all follows the cursors with pages of two and returns the titles in order.
+	all := func(path string, q url.Values) []string {
+		q.Set("limit", "2")
+		titles := []string{}
+		for i := 0; i < 10; i++ {
+			code, page := get(path, q)
+			require.Equal(t, http.StatusOK, code, q.Encode())
+			assert.LessOrEqual(t, len(page.Items), 2)
+			for _, p := range page.Items {
+				titles = append(titles, p.Title)
+			}
+			if page.NextCursor == "" {
+				return titles
+			}
+			q.Set("cursor", page.NextCursor)
+		}
+		t.Fatal("cursor never ran out")
+		return nil
+	}
+
+	cases := []struct {
+		name  string
+		query url.Values
+		want  []string
+	}{
+		{"newest first by default, id breaks ties", url.Values{}, []string{"Foxtrot", "Echo", "Delta", "Charlie", "bravo", "Alpha"}},
+		{"oldest first", url.Values{"order": {"asc"}}, []string{"Alpha", "bravo", "Charlie", "Delta", "Echo", "Foxtrot"}},
+		{"by title", url.Values{"sort": {"title"}}, []string{"Alpha", "Charlie", "Delta", "Echo", "Foxtrot", "bravo"}},
+		{"longest first", url.Values{"sort": {"duration"}}, []string{"Charlie", "Echo", "Alpha", "bravo", "Delta", "Foxtrot"}},
+		{"status", url.Values{"status": {"complete"}}, []string{"Delta", "Alpha"}},
+		{"daw ignores case", url.Values{"daw": {"Ableton Live"}}, []string{"Echo", "Charlie", "Alpha"}},
+		{"plugin matches catalog aliases", url.Values{"plugin": {"serum"}}, []string{"Echo", "bravo", "Alpha"}},
+		{"public", url.Values{"public": {"false"}}, []string{"Echo", "bravo"}},
+		{"date range includes the whole end day", url.Values{"from": {"2026-03-02"}, "to": {"2026-03-03"}}, []string{"Delta", "Charlie", "bravo"}},
+		{"RFC 3339 bounds", url.Values{"from": {"2026-03-03T12:00:00Z"}, "to": {"2026-03-07T00:00:00Z"}}, []string{"Foxtrot", "Echo", "Delta", "Charlie"}},
+		{"combined", url.Values{"public": {"true"}, "daw": {"ableton live"}, "sort": {"duration"}}, []string{"Charlie", "Alpha"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, all("/app/projects", tc.query))
+		})
+	}
+
+	// The public list only ever shows public projects, whatever the filter says.
+	assert.Equal(t, []string{"Foxtrot", "Delta", "Charlie", "Alpha"}, all("/profiles/lister/projects", url.Values{"public": {"false"}}))
+
+	code, page := get("/app/projects", url.Values{"limit": {"1"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Len(t, page.Items[0].Plugins, 0)
+	code, page = get("/app/projects", url.Values{"plugin": {"Pro-Q 3"}})
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Len(t, page.Items[0].Plugins, 1)
+	code, page = get("/app/projects", url.Values{"plugin": {"Pro-Q 3"}, "plugins": {"false"}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, page.Items[0].Plugins, "plugins=false skips the preload")
+
+	_, first := get("/app/projects", url.Values{"limit": {"2"}})
+	bad := []url.Values{
+		{"status": {"archived"}},
+		{"sort": {"popularity"}},
+		{"order": {"sideways"}},
+		{"dateField": {"birthday"}},
+		{"from": {"last tuesday"}},
+		{"cursor": {"not-a-cursor"}},
+		{"cursor": {first.NextCursor}, "sort": {"title"}}, // cursors only continue their own sort
+		{"limit": {"ten"}},
+	}
+	for _, q := range bad {
+		code, _ := get("/app/projects", q)
+		assert.Equal(t, http.StatusBadRequest, code, q.Encode())
+	}
+
+	// The profile embeds the first page and points at the rest.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profiles/lister", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var profile struct {
+		Projects           []models.Project `json:"projects"`
+		ProjectsNextCursor string           `json:"projectsNextCursor"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &profile))
+	assert.Len(t, profile.Projects, 4)
+	assert.Empty(t, profile.ProjectsNextCursor)
+	assert.Equal(t, ids["Foxtrot"], profile.Projects[0].ID)
+}
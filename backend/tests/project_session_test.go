@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestProjectSessions_InferredFromHeartbeats(t *testing.T) {
+	db := setupMigratedDB(t)
+	svc := services.NewProjectService(db)
+	svc.Sessions.IdleGap = 10 * time.Minute
+	base := time.Now().Add(-6 * time.Hour).Truncate(time.Second)
+
+	beat := func(offset time.Duration, clientDuration int) *models.Project {
+		at := base.Add(offset)
+		p, err := svc.Upsert(1, services.UpsertProjectInput{
+			Title: "Night Drive", DAW: "Ableton Live", PluginVersion: "1.2.0",
+			DurationSeconds: clientDuration, Metadata: json.RawMessage(`{"bpm":92}`), At: &at,
+		})
+		require.NoError(t, err)
+		return p
+	}
+
+	steps := []struct {
+		name     string
+		offset   time.Duration
+		sessions int
+		total    int
+	}{
+		{"first heartbeat opens a session", 0, 1, 0},
+		{"within the gap extends it", 5 * time.Minute, 1, 300},
+		{"still within the gap", 12 * time.Minute, 1, 720},
+		{"after the gap starts a new session", 30 * time.Minute, 2, 720},
+		{"extends the second session", 40 * time.Minute, 2, 1320},
+		{"late heartbeat bridging both merges them", 21 * time.Minute, 1, 2400},
+		{"much later starts another session", 3 * time.Hour, 2, 2400},
+	}
+	for _, st := range steps {
+		// The client claims a huge duration every time; the server must ignore it.
+		p := beat(st.offset, 999999)
+		var n int64
+		require.NoError(t, db.Model(&models.ProjectSession{}).Where("project_id = ?", p.ID).Count(&n).Error)
+		assert.Equal(t, int64(st.sessions), n, st.name)
+		assert.Equal(t, st.total, p.DurationSeconds, st.name)
+	}
+
+	var projects int64
+	require.NoError(t, db.Model(&models.Project{}).Count(&projects).Error)
+	assert.Equal(t, int64(1), projects)
+
+	var beats []models.ProjectHeartbeat
+	require.NoError(t, db.Order("at asc").Find(&beats).Error)
+	require.Len(t, beats, len(steps))
+	assert.Equal(t, 999999, beats[0].ClientDurationSeconds)
+	assert.JSONEq(t, `{"bpm":92}`, string(beats[0].Metadata))
+	for _, b := range beats[:6] {
+		assert.Equal(t, beats[0].SessionID, b.SessionID, "heartbeats of both sessions end up in the merged one")
+	}
+	assert.NotEqual(t, beats[0].SessionID, beats[6].SessionID)
+
+	var sess models.ProjectSession
+	require.NoError(t, db.First(&sess, beats[0].SessionID).Error)
+	assert.Equal(t, 6, sess.HeartbeatCount)
+	assert.True(t, sess.StartedAt.Equal(base))
+	assert.True(t, sess.EndedAt.Equal(base.Add(40*time.Minute)))
+
+	// Timestamps from the future are treated as now.
+	future := time.Now().Add(24 * time.Hour)
+	p, err := svc.Upsert(1, services.UpsertProjectInput{Title: "Night Drive", At: &future})
+	require.NoError(t, err)
+	var last models.ProjectHeartbeat
+	require.NoError(t, db.Where("project_id = ?", p.ID).Order("id desc").First(&last).Error)
+	assert.WithinDuration(t, time.Now(), last.At, time.Minute)
+}
+
+func TestProjectSessions_ListEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	ctl := controllers.NewProjectController(db, nil)
+	r := gin.New()
+	r.POST("/ingest/projects", asUser(1), ctl.Upsert)
+	r.GET("/app/projects/:id/sessions", asUser(1), ctl.Sessions)
+	r.GET("/app/projects/:id/sessions/:sessionId/heartbeats", asUser(1), ctl.Heartbeats)
+	r.GET("/other/projects/:id/sessions", asUser(2), ctl.Sessions)
+
+	start := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	var projectID uint
+	for _, offset := range []time.Duration{0, 3 * time.Minute, 90 * time.Minute} {
+		body, _ := json.Marshal(gin.H{"title": "Loop 7", "daw": "FL Studio", "durationSeconds": 5, "at": start.Add(offset)})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest/projects", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var p models.Project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		projectID = p.ID
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/projects/"+itoa(projectID)+"/sessions", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Items          []models.ProjectSession `json:"items"`
+		TotalSeconds   int                     `json:"totalSeconds"`
+		IdleGapSeconds int                     `json:"idleGapSeconds"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Items, 2)
+	assert.Equal(t, 180, resp.TotalSeconds)
+	assert.Equal(t, 900, resp.IdleGapSeconds)
+	assert.True(t, resp.Items[0].StartedAt.After(resp.Items[1].StartedAt), "newest first")
+	assert.Equal(t, 2, resp.Items[1].HeartbeatCount)
+	assert.Equal(t, "FL Studio", resp.Items[1].DAW)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app/projects/"+itoa(projectID)+"/sessions/"+itoa(resp.Items[1].ID)+"/heartbeats", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var beats []models.ProjectHeartbeat
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &beats))
+	assert.Len(t, beats, 2)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/other/projects/"+itoa(projectID)+"/sessions", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+// migrationStatements returns the statements of a file in migrations/ that start with prefix.
+func migrationStatements(t testing.TB, file, prefix string) []string {
+	b, err := os.ReadFile(filepath.Join("..", "migrations", file))
+	require.NoError(t, err)
+	var out []string
+	for _, stmt := range strings.Split(string(b), ";") {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		if stmt = strings.TrimSpace(strings.Join(lines, "\n")); strings.HasPrefix(stmt, prefix) {
+			out = append(out, stmt)
+		}
+	}
+	require.NotEmpty(t, out, "%s has no %q statement", file, prefix)
+	return out
+}
+
+// withProjectTitleMigrations replays what the SQL migrations do to the project title index:
+// 001 makes (user_id, LOWER(title)) unique and 018 drops it again. AutoMigrate alone never
+// creates it, which would hide a missing drop.
+func withProjectTitleMigrations(t testing.TB, db *gorm.DB) {
+	for _, stmt := range migrationStatements(t, "001_init.sql", "CREATE UNIQUE INDEX IF NOT EXISTS ux_projects_user_title") {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+	for _, stmt := range migrationStatements(t, "018_project_uuid.sql", "DROP INDEX") {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+}
+
+func TestProjectUpsert_ClientUUID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	withProjectTitleMigrations(t, db)
+	ctl := controllers.NewProjectController(db, nil)
+	r := gin.New()
+	r.POST("/ingest/projects", asUser(1), ctl.Upsert)
+	r.POST("/other/projects", asUser(2), ctl.Upsert)
+
+	const (
+		u1 = "0f8c3c0e-5c2b-4e0a-9a55-7b1f2f4b6a01"
+		u2 = "6d1e2f3a-4b5c-4d6e-8f70-8192a3b4c5d6"
+		u3 = "a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d"
+	)
+	ids := map[string]uint{}
+	steps := []struct {
+		name    string
+		path    string
+		body    gin.H
+		status  int
+		project string // ids key the response should match; a new key records the id
+		title   string
+		uuid    string
+	}{
+		{"older plugin creates by title", "/ingest/projects", gin.H{"title": "Beat A"}, http.StatusOK, "A", "Beat A", ""},
+		{"first UUID adopts the title match", "/ingest/projects", gin.H{"uuid": u1, "title": "Beat A"}, http.StatusOK, "A", "Beat A", u1},
+		{"renamed DAW file keeps the project", "/ingest/projects", gin.H{"uuid": u1, "title": "Beat A (final)"}, http.StatusOK, "A", "Beat A (final)", u1},
+		{"UUID is case-insensitive", "/ingest/projects", gin.H{"uuid": "0F8C3C0E-5C2B-4E0A-9A55-7B1F2F4B6A01", "title": "Beat A (final)"}, http.StatusOK, "A", "Beat A (final)", u1},
+		{"same title, other session is a new project", "/ingest/projects", gin.H{"uuid": u2, "title": "Beat A (final)"}, http.StatusOK, "B", "Beat A (final)", u2},
+		{"UUID without title keeps the title", "/ingest/projects", gin.H{"uuid": u2}, http.StatusOK, "B", "Beat A (final)", u2},
+		{"unknown UUID needs a title", "/ingest/projects", gin.H{"uuid": u3}, http.StatusBadRequest, "", "", ""},
+		{"malformed UUID", "/ingest/projects", gin.H{"uuid": "session-1", "title": "x"}, http.StatusBadRequest, "", "", ""},
+		{"neither UUID nor title", "/ingest/projects", gin.H{"daw": "Logic"}, http.StatusBadRequest, "", "", ""},
+		{"UUIDs are per user", "/other/projects", gin.H{"uuid": u1, "title": "Collab"}, http.StatusOK, "C", "Collab", u1},
+	}
+	for _, st := range steps {
+		buf, _ := json.Marshal(st.body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, st.path, bytes.NewReader(buf)))
+		require.Equal(t, st.status, w.Code, "%s: %s", st.name, w.Body.String())
+		if st.status != http.StatusOK {
+			continue
+		}
+		var p models.Project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		if id, ok := ids[st.project]; ok {
+			assert.Equal(t, id, p.ID, st.name)
+		} else {
+			for k, other := range ids {
+				assert.NotEqual(t, other, p.ID, "%s: reused project %s", st.name, k)
+			}
+			ids[st.project] = p.ID
+		}
+		assert.Equal(t, st.title, p.Title, st.name)
+		if st.uuid != "" {
+			assert.Equal(t, st.uuid, p.UUID, st.name)
+		} else {
+			assert.Len(t, p.UUID, 36, "%s: server assigns a UUID", st.name)
+		}
+	}
+
+	// Heartbeats for a trashed project are refused instead of forking a new one.
+	svc := services.NewProjectService(db)
+	require.NoError(t, svc.Delete(1, ids["A"]))
+	_, err := svc.Upsert(1, services.UpsertProjectInput{UUID: u1, Title: "Beat A (final)"})
+	assert.ErrorIs(t, err, services.ErrProjectDeleted)
+	buf, _ := json.Marshal(gin.H{"uuid": u1})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest/projects", bytes.NewReader(buf)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	_, err = svc.Restore(1, ids["A"])
+	require.NoError(t, err)
+	p, err := svc.Upsert(1, services.UpsertProjectInput{UUID: u1})
+	require.NoError(t, err)
+	assert.Equal(t, ids["A"], p.ID)
+
+	// Batch items can point at a project by UUID.
+	res, err := services.NewIngestBatchService(db).Apply(1, []services.BatchItem{
+		{Type: services.BatchPlugin, ProjectUUID: u2, Plugin: &services.UpsertPluginInput{Name: "Serum"}},
+		{Type: services.BatchComplete, ProjectUUID: u3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, services.BatchApplied, res.Results[0].Status)
+	assert.Equal(t, ids["B"], res.Results[0].ProjectID)
+	assert.Equal(t, "project not found", res.Results[1].Error)
+}
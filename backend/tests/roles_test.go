@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/models"
+)
+
+// asAuth0 stands in for the Auth0 middleware.
+func asAuth0(sub string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("auth0_id", sub)
+		c.Next()
+	}
+}
+
+// asAuth0Claims stands in for the Auth0 middleware with the token's email claims.
+func asAuth0Claims(sub, email string, verified bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("auth0_id", sub)
+		c.Set("user_claims", jwt.MapClaims{"sub": sub, "email": email, "email_verified": verified})
+		c.Next()
+	}
+}
+
+func TestRequireRole_AdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+
+	mk := func(handle string, role models.Role) models.User {
+		u := models.User{Auth0ID: "auth0|" + handle, Email: handle + "@example.com", Username: handle, Role: role}
+		require.NoError(t, db.Create(&u).Error)
+		return u
+	}
+	member := mk("member", models.RoleUser)
+	mod := mk("mod", models.RoleModerator)
+	boss := mk("boss", models.RoleUser) // promoted through ADMIN_EMAILS
+	// Claims the admin address at registration, which nobody verified.
+	squatter := models.User{Email: "root@example.com", Username: "squatter"}
+	require.NoError(t, db.Create(&squatter).Error)
+	require.NoError(t, db.Create(&models.Project{UserID: member.ID, Title: "loop", Public: true}).Error)
+	doomed := models.Project{UserID: member.ID, Title: "spam", Public: true}
+	require.NoError(t, db.Create(&doomed).Error)
+
+	roles := middlewares.NewRoles(db, []string{" Boss@Example.com ", "root@example.com"})
+	ctl := controllers.NewAdminController(db)
+	route := func(auth gin.HandlerFunc) *gin.Engine {
+		r := gin.New()
+		admin := r.Group("/admin", auth, roles.RequireRole(models.RoleModerator))
+		admin.GET("/users", ctl.ListUsers)
+		admin.PATCH("/users/:id", ctl.UpdateUser)
+		admin.DELETE("/users/:id", roles.RequireRole(models.RoleAdmin), ctl.DeleteUser)
+		admin.PATCH("/projects/:id", ctl.UpdateProject)
+		admin.DELETE("/projects/:id", roles.RequireRole(models.RoleAdmin), ctl.DeleteProject)
+		return r
+	}
+	do := func(r *gin.Engine, method, path, body string) int {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cases := []struct {
+		name   string
+		auth   gin.HandlerFunc
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"no principal", func(c *gin.Context) { c.Next() }, http.MethodGet, "/admin/users", "", http.StatusUnauthorized},
+		{"plain user", asUser(member.ID), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"unknown auth0 user", asAuth0("auth0|ghost"), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"moderator via jwt", asUser(mod.ID), http.MethodGet, "/admin/users?role=moderator", "", http.StatusOK},
+		{"moderator via auth0", asAuth0(mod.Auth0ID), http.MethodGet, "/admin/users", "", http.StatusOK},
+		{"moderator hides project", asUser(mod.ID), http.MethodPatch, "/admin/projects/1", `{"public":false}`, http.StatusOK},
+		{"moderator cannot grant roles", asUser(mod.ID), http.MethodPatch, "/admin/users/" + itoa(member.ID), `{"role":"admin"}`, http.StatusForbidden},
+		{"moderator cannot delete users", asUser(mod.ID), http.MethodDelete, "/admin/users/" + itoa(member.ID), "", http.StatusForbidden},
+		{"moderator cannot delete projects", asUser(mod.ID), http.MethodDelete, "/admin/projects/" + itoa(doomed.ID), "", http.StatusForbidden},
+		{"stored admin email is not enough", asAuth0(boss.Auth0ID), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"unverified token email", asAuth0Claims(boss.Auth0ID, "boss@example.com", false), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"legacy login with an admin email", asUser(squatter.ID), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+		{"bootstrap admin grants roles", asAuth0Claims(boss.Auth0ID, "Boss@example.com", true), http.MethodPatch, "/admin/users/" + itoa(member.ID), `{"role":"moderator"}`, http.StatusOK},
+		{"invalid role", asUser(boss.ID), http.MethodPatch, "/admin/users/" + itoa(member.ID), `{"role":"owner"}`, http.StatusBadRequest},
+		{"admin cannot demote self", asUser(boss.ID), http.MethodPatch, "/admin/users/" + itoa(boss.ID), `{"role":"user"}`, http.StatusConflict},
+		{"admin cannot delete self", asUser(boss.ID), http.MethodDelete, "/admin/users/" + itoa(boss.ID), "", http.StatusConflict},
+		{"admin deletes project", asUser(boss.ID), http.MethodDelete, "/admin/projects/" + itoa(doomed.ID), "", http.StatusNoContent},
+		{"admin deletes user", asUser(boss.ID), http.MethodDelete, "/admin/users/" + itoa(mod.ID), "", http.StatusNoContent},
+		{"deleted moderator loses access", asUser(mod.ID), http.MethodGet, "/admin/users", "", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, do(route(tc.auth), tc.method, tc.path, tc.body))
+		})
+	}
+
+	var promoted, granted, notPromoted models.User
+	require.NoError(t, db.First(&promoted, boss.ID).Error)
+	assert.Equal(t, models.RoleAdmin, promoted.Role)
+	require.NoError(t, db.First(&notPromoted, squatter.ID).Error)
+	assert.Equal(t, models.RoleUser, notPromoted.Role)
+	require.NoError(t, db.First(&granted, member.ID).Error)
+	assert.Equal(t, models.RoleModerator, granted.Role)
+	var p models.Project
+	require.NoError(t, db.First(&p).Error)
+	assert.False(t, p.Public)
+	assert.ErrorIs(t, db.Unscoped().First(&models.Project{}, doomed.ID).Error, gorm.ErrRecordNotFound)
+}
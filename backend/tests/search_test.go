@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestSearch_FallbackMatching(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+
+	nova := models.User{Auth0ID: "test|nova", Email: "nova@example.com", Username: "nova", DisplayName: "DJ Nova", Bio: "Lo-fi beats from Lisbon", Public: true}
+	hidden := models.User{Auth0ID: "test|hidden", Email: "hidden@example.com", Username: "hiddenlofi", DisplayName: "Lofi Ghost", Public: false}
+	require.NoError(t, db.Create(&nova).Error)
+	require.NoError(t, db.Create(&hidden).Error)
+	projects := []models.Project{
+		{UserID: nova.ID, Title: "Sunset Tape", Public: true, Metadata: datatypes.JSON(`{"tags":["lofi","chill"],"bpm":82}`)},
+		{UserID: nova.ID, Title: "Bassline Sketch", Public: true, Metadata: datatypes.JSON(`{"tags":["house"]}`)},
+		{UserID: nova.ID, Title: "Lofi Draft", Public: false},
+		{UserID: hidden.ID, Title: "Lofi Secrets", Public: true},
+		{UserID: nova.ID, Title: "Lofi Trashed", Public: true},
+	}
+	for i := range projects {
+		require.NoError(t, db.Create(&projects[i]).Error)
+	}
+	require.NoError(t, services.NewProjectService(db).Delete(nova.ID, projects[4].ID))
+	_, err := services.NewPluginService(db).UpsertByName(nova.ID, projects[1].ID, services.UpsertPluginInput{Name: "Serum", Vendor: "Xfer Records"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/search", controllers.NewSearchController(db).Search)
+	search := func(q url.Values) (int, services.SearchResults) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+q.Encode(), nil))
+		var res services.SearchResults
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		}
+		return w.Code, res
+	}
+	titles := func(hits []services.ProjectHit) []string {
+		out := []string{}
+		for _, h := range hits {
+			out = append(out, h.Title)
+		}
+		return out
+	}
+
+	cases := []struct {
+		name      string
+		query     url.Values
+		projects  []string
+		producers []string
+		plugins   []string
+	}{
+		{"tags and titles of public projects only", url.Values{"q": {"lofi"}}, []string{"Sunset Tape"}, []string{}, []string{}},
+		{"title words", url.Values{"q": {"bassline"}}, []string{"Bassline Sketch"}, []string{}, []string{}},
+		{"every word must match", url.Values{"q": {"sunset house"}}, []string{}, []string{}, []string{}},
+		{"display name", url.Values{"q": {"DJ nova"}}, []string{}, []string{"nova"}, []string{}},
+		{"bio", url.Values{"q": {"lisbon"}}, []string{}, []string{"nova"}, []string{}},
+		{"plugin name and vendor", url.Values{"q": {"xfer serum"}}, []string{}, []string{}, []string{"Serum"}},
+		{"prefixes while typing", url.Values{"q": {"ser"}}, []string{}, []string{}, []string{"Serum"}},
+		{"punctuation is ignored", url.Values{"q": {"(lisbon!)"}}, []string{}, []string{"nova"}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, res := search(tc.query)
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tc.projects, titles(res.Projects))
+			producers := []string{}
+			for _, h := range res.Producers {
+				producers = append(producers, h.Username)
+			}
+			assert.Equal(t, tc.producers, producers)
+			plugins := []string{}
+			for _, h := range res.Plugins {
+				plugins = append(plugins, h.Name)
+			}
+			assert.Equal(t, tc.plugins, plugins)
+		})
+	}
+
+	code, res := search(url.Values{"q": {"lofi"}, "type": {"projects"}})
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, res.Projects, 1)
+	assert.Equal(t, "nova", res.Projects[0].Username)
+	assert.Nil(t, res.Producers)
+	assert.Nil(t, res.Plugins)
+
+	for _, q := range []url.Values{{"q": {""}}, {"q": {"?!"}}, {"q": {"lofi"}, "type": {"songs"}}} {
+		code, _ := search(q)
+		assert.Equal(t, http.StatusBadRequest, code, q.Encode())
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/middlewares"
+	"github.com/uploadparty/app/internal/services"
+)
+
+type tokenResp struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func TestSessions_RefreshRotationAndLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	sessions := services.NewSessionService(db, "secret", time.Hour, 24*time.Hour)
+	jwtMw := middlewares.NewJWT("secret")
+	jwtMw.Revocations = sessions
+	authn := middlewares.NewAuthenticator(db, jwtMw, nil)
+	ctl := controllers.NewAuthController(db, "secret", sessions)
+
+	r := gin.New()
+	r.POST("/auth/register", ctl.Register)
+	r.POST("/auth/login", ctl.Login)
+	r.POST("/auth/refresh", ctl.Refresh)
+	r.POST("/auth/logout", authn.RequireUser(), ctl.Logout)
+	r.POST("/auth/logout-all", authn.RequireUser(), ctl.LogoutAll)
+	r.GET("/me", authn.RequireUser(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	post := func(path, token string, body interface{}) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	me := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	login := func() tokenResp {
+		w := post("/auth/login", "", gin.H{"identifier": "djay", "password": "hunter22"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var tr tokenResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tr))
+		require.NotEmpty(t, tr.RefreshToken)
+		return tr
+	}
+	refresh := func(rt string) (*httptest.ResponseRecorder, tokenResp) {
+		w := post("/auth/refresh", "", gin.H{"refreshToken": rt})
+		var tr tokenResp
+		json.Unmarshal(w.Body.Bytes(), &tr)
+		return w, tr
+	}
+
+	require.Equal(t, http.StatusCreated, post("/auth/register", "", gin.H{"email": "dj@example.com", "username": "djay", "password": "hunter22"}).Code)
+	assert.Equal(t, http.StatusConflict, post("/auth/register", "", gin.H{"email": "dj2@example.com", "username": "DJay", "password": "hunter22"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/auth/register", "", gin.H{"email": "dj3@example.com", "username": "admin", "password": "hunter22"}).Code)
+
+	t.Run("rotation and reuse detection", func(t *testing.T) {
+		first := login()
+		w, second := refresh(first.RefreshToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.Equal(t, http.StatusOK, me(second.Token))
+
+		// Replaying the rotated token revokes the whole chain, including the newest tokens.
+		w, _ = refresh(first.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "reuse")
+		w, _ = refresh(second.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, me(second.Token))
+		assert.Equal(t, http.StatusUnauthorized, me(first.Token))
+	})
+
+	t.Run("logout ends only the current session", func(t *testing.T) {
+		laptop, phone := login(), login()
+		assert.Equal(t, http.StatusNoContent, post("/auth/logout", laptop.Token, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, me(laptop.Token))
+		w, _ := refresh(laptop.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusOK, me(phone.Token))
+		w, _ = refresh(phone.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("logout everywhere", func(t *testing.T) {
+		laptop, phone := login(), login()
+		assert.Equal(t, http.StatusNoContent, post("/auth/logout-all", laptop.Token, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, me(laptop.Token))
+		assert.Equal(t, http.StatusUnauthorized, me(phone.Token))
+		w, _ := refresh(phone.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	w, _ := refresh("not-a-real-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/controllers"
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+	"github.com/uploadparty/app/internal/storage"
+)
+
+func TestUploadController_ResumableFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupMigratedDB(t)
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	user := models.User{Auth0ID: "test|u", Email: "u@example.com", Username: "u"}
+	require.NoError(t, db.Create(&user).Error)
+	proj := models.Project{UserID: user.ID, Title: "stems"}
+	require.NoError(t, db.Create(&proj).Error)
+
+	// newRouter builds a fresh controller each time to mimic a server restart between requests.
+	newRouter := func() *gin.Engine {
+		ctl := controllers.NewUploadController(db, store, 1<<20, 4096, nil)
+		r := gin.New()
+		r.Use(asUser(user.ID))
+		r.POST("/uploads", ctl.Create)
+		r.HEAD("/uploads/:id", ctl.Status)
+		r.PATCH("/uploads/:id", ctl.AppendChunk)
+		r.POST("/uploads/:id/finalize", ctl.Finalize)
+		return r
+	}
+	do := func(method, path string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	file := wavBytes(8000, 1, 3000) // 6044 bytes, needs two chunks at a 4096 byte limit
+	initBody, _ := json.Marshal(gin.H{"projectId": proj.ID, "filename": "kick.wav", "totalBytes": len(file)})
+	w := do("POST", "/uploads", initBody, map[string]string{"Content-Type": "application/json"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var sess models.UploadSession
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sess))
+	path := "/uploads/" + sess.ID
+
+	w = do("PATCH", path, file[:4096], map[string]string{"Upload-Offset": "0"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "4096", w.Header().Get("Upload-Offset"))
+
+	// Replaying the first chunk is rejected and reports the real offset.
+	w = do("PATCH", path, file[:4096], map[string]string{"Upload-Offset": "0"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "4096", w.Header().Get("Upload-Offset"))
+
+	w = do("POST", path+"/finalize", nil, nil)
+	assert.Equal(t, http.StatusConflict, w.Code, "finalize before all bytes arrive")
+
+	w = do("HEAD", path, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	offset, _ := strconv.Atoi(w.Header().Get("Upload-Offset"))
+	w = do("PATCH", path, file[offset:], map[string]string{"Upload-Offset": strconv.Itoa(offset)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do("POST", path+"/finalize", nil, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var af models.AudioFile
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &af))
+	assert.Equal(t, proj.ID, af.ProjectID)
+	assert.EqualValues(t, len(file), af.SizeBytes)
+
+	// Finalize is idempotent.
+	w = do("POST", path+"/finalize", nil, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var again models.AudioFile
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &again))
+	assert.Equal(t, af.ID, again.ID)
+
+	var count int64
+	db.Model(&models.AudioFile{}).Count(&count)
+	assert.EqualValues(t, 1, count)
+}
+
+// gatedStore holds back Puts of chunks that start with gate until release is closed.
+type gatedStore struct {
+	storage.BlobStore
+	gate    byte
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) > 0 && b[0] == s.gate {
+		close(s.entered)
+		<-s.release
+	}
+	return s.BlobStore.Put(ctx, key, bytes.NewReader(b), contentType)
+}
+
+func TestUploadService_ConcurrentAppendKeepsWinner(t *testing.T) {
+	db := setupMigratedDB(t)
+	dir := t.TempDir()
+	local, err := storage.NewLocalStore(dir)
+	require.NoError(t, err)
+	store := &gatedStore{BlobStore: local, gate: 'B', entered: make(chan struct{}), release: make(chan struct{})}
+	user, proj := seedProducer(t, db, "racer", "FL Studio", "")
+	svc := services.NewUploadService(db, store, nil, 1<<20, 4096)
+	ctx := context.Background()
+
+	sess, err := svc.Init(user.ID, services.InitUploadInput{ProjectID: proj.ID, Filename: "kick.wav", TotalBytes: 8})
+	require.NoError(t, err)
+
+	// The loser reads the session and starts writing before the winner, but finishes after it.
+	lost := make(chan error)
+	go func() {
+		_, err := svc.AppendChunk(ctx, user.ID, sess.ID, 0, bytes.NewReader([]byte("BBBB")))
+		lost <- err
+	}()
+	<-store.entered
+	_, err = svc.AppendChunk(ctx, user.ID, sess.ID, 0, bytes.NewReader([]byte("AAAA")))
+	require.NoError(t, err)
+	close(store.release)
+	assert.ErrorIs(t, <-lost, services.ErrUploadOffsetMismatch)
+
+	got, err := svc.Get(user.ID, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, got.ChunkKeys, 1)
+	rc := storage.ConcatReader(ctx, local, got.ChunkKeys)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "AAAA", string(data))
+
+	// The losing attempt cleans up after itself.
+	chunks, err := os.ReadDir(filepath.Join(dir, "uploads", sess.ID))
+	require.NoError(t, err)
+	assert.Len(t, chunks, 1)
+}
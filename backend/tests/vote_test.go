@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/uploadparty/app/internal/models"
+	"github.com/uploadparty/app/internal/services"
+)
+
+func TestVotingService_RankedBallots(t *testing.T) {
+	db := setupMigratedDB(t)
+	now := time.Now()
+	ch, err := services.NewChallengeService(db).Create(1, services.ChallengeInput{
+		Title:        strPtr("Vote Week"),
+		StartsAt:     timePtr(now.Add(-2 * time.Hour)),
+		EndsAt:       timePtr(now.Add(-time.Hour)),
+		VotingEndsAt: timePtr(now.Add(time.Hour)),
+		VotingMode:   strPtr("ranked"),
+		BallotSize:   intPtr(2),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, services.PhaseVoting, ch.Phase)
+
+	entries := map[string]models.ChallengeEntry{}
+	users := map[string]models.User{}
+	for _, h := range []string{"alice", "bob", "carol"} {
+		u, p := seedProducer(t, db, h, "", "")
+		e := models.ChallengeEntry{ChallengeID: ch.ID, UserID: u.ID, ProjectID: p.ID, Locked: true}
+		require.NoError(t, db.Create(&e).Error)
+		users[h], entries[h] = u, e
+	}
+	dave := models.User{Auth0ID: "test|dave", Email: "dave@example.com", Username: "dave"}
+	require.NoError(t, db.Create(&dave).Error)
+
+	svc := services.NewVotingService(db)
+	cases := []struct {
+		name  string
+		voter uint
+		ids   []uint
+		err   error
+		msg   string
+	}{
+		{"self vote", users["alice"].ID, []uint{entries["alice"].ID}, services.ErrSelfVote, ""},
+		{"too many", dave.ID, []uint{entries["alice"].ID, entries["bob"].ID, entries["carol"].ID}, nil, "between 1 and 2"},
+		{"duplicate", dave.ID, []uint{entries["bob"].ID, entries["bob"].ID}, nil, "more than once"},
+		{"unknown entry", dave.ID, []uint{9999}, nil, "not part of this challenge"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CastBallot(tc.voter, ch.ID, services.BallotInput{EntryIDs: tc.ids})
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.ErrorContains(t, err, tc.msg)
+			}
+		})
+	}
+
+	// Recasting replaces the previous ballot rather than adding to it.
+	_, err = svc.CastBallot(dave.ID, ch.ID, services.BallotInput{EntryIDs: []uint{entries["alice"].ID}})
+	require.NoError(t, err)
+	ballot, err := svc.CastBallot(dave.ID, ch.ID, services.BallotInput{EntryIDs: []uint{entries["bob"].ID, entries["carol"].ID}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, ballot[0].Points)
+	assert.Equal(t, 1, ballot[1].Points)
+	_, err = svc.CastBallot(users["alice"].ID, ch.ID, services.BallotInput{EntryIDs: []uint{entries["bob"].ID}})
+	require.NoError(t, err)
+	_, err = svc.CastBallot(users["carol"].ID, ch.ID, services.BallotInput{EntryIDs: []uint{entries["alice"].ID, entries["bob"].ID}})
+	require.NoError(t, err)
+	got, err := svc.Ballot(dave.ID, ch.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, entries["bob"].ID, got[0].EntryID)
+
+	_, err = svc.Results(ch.ID, services.ResultsCount, false)
+	assert.ErrorIs(t, err, services.ErrVotingNotClosed)
+
+	// bob: 2+2+1=5 over 3 votes, alice: 2 over 1, carol: 1 over 1.
+	res, err := svc.Results(ch.ID, services.ResultsCount, true)
+	require.NoError(t, err)
+	assert.False(t, res.Final)
+	assert.EqualValues(t, 3, res.Ballots)
+	require.Len(t, res.Entries, 3)
+	assert.Equal(t, "bob", res.Entries[0].Username)
+	assert.EqualValues(t, 5, res.Entries[0].Points)
+	assert.EqualValues(t, 3, res.Entries[0].Votes)
+	assert.Equal(t, []int{1, 2, 3}, []int{res.Entries[0].Rank, res.Entries[1].Rank, res.Entries[2].Rank})
+
+	// Close voting: ballots are frozen and results become public.
+	require.NoError(t, db.Model(&models.Challenge{}).Where("id = ?", ch.ID).Update("voting_ends_at", now.Add(-time.Minute)).Error)
+	_, err = svc.CastBallot(dave.ID, ch.ID, services.BallotInput{EntryIDs: []uint{entries["alice"].ID}})
+	assert.ErrorIs(t, err, services.ErrVotingClosed)
+	assert.ErrorIs(t, svc.Retract(dave.ID, ch.ID), services.ErrVotingClosed)
+
+	bayes, err := svc.Results(ch.ID, services.ResultsBayesian, false)
+	require.NoError(t, err)
+	assert.True(t, bayes.Final)
+	// Prior weight 5/3 votes at mean 8/5 points. Bayesian mode rewards points per vote,
+	// so alice's lone first-place vote edges out bob's mixed 2+2+1.
+	assert.Equal(t, []string{"alice", "bob", "carol"}, []string{bayes.Entries[0].Username, bayes.Entries[1].Username, bayes.Entries[2].Username})
+	assert.InDelta(t, (5.0/3*8.0/5+2)/(5.0/3+1), bayes.Entries[0].Score, 1e-9)
+	assert.InDelta(t, (5.0/3*8.0/5+5)/(5.0/3+3), bayes.Entries[1].Score, 1e-9)
+
+	_, err = svc.Results(ch.ID, "median", false)
+	assert.Error(t, err)
+}
+
+func TestVotingService_SingleVoteTies(t *testing.T) {
+	db := setupMigratedDB(t)
+	now := time.Now()
+	ch, err := services.NewChallengeService(db).Create(1, services.ChallengeInput{
+		Title:        strPtr("Quick Flip"),
+		StartsAt:     timePtr(now.Add(-2 * time.Hour)),
+		EndsAt:       timePtr(now.Add(-time.Hour)),
+		VotingEndsAt: timePtr(now.Add(time.Hour)),
+		BallotSize:   intPtr(5), // ignored for single-vote challenges
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.VotingSingle, ch.VotingMode)
+	assert.Equal(t, 1, ch.BallotSize)
+
+	var ids []uint
+	for _, h := range []string{"ann", "ben", "cat", "dan"} {
+		u, p := seedProducer(t, db, h, "", "")
+		e := models.ChallengeEntry{ChallengeID: ch.ID, UserID: u.ID, ProjectID: p.ID}
+		require.NoError(t, db.Create(&e).Error)
+		ids = append(ids, e.ID)
+	}
+	svc := services.NewVotingService(db)
+	// ann -> ben, ben -> ann, cat -> ann, dan -> ben: ann and ben tie on two votes.
+	for voter, entry := range map[uint]uint{1: ids[1], 2: ids[0], 3: ids[0], 4: ids[1]} {
+		_, err := svc.CastBallot(voter, ch.ID, services.BallotInput{EntryIDs: []uint{entry}})
+		require.NoError(t, err)
+	}
+	_, err = svc.CastBallot(1, ch.ID, services.BallotInput{EntryIDs: []uint{ids[0], ids[1]}})
+	assert.ErrorContains(t, err, "between 1 and 1")
+
+	res, err := svc.Results(ch.ID, services.ResultsCount, true)
+	require.NoError(t, err)
+	ranks := []int{}
+	for _, e := range res.Entries {
+		ranks = append(ranks, e.Rank)
+	}
+	assert.Equal(t, []int{1, 1, 3, 3}, ranks)
+	assert.Equal(t, ids[0], res.Entries[0].EntryID, "earlier entry listed first within a tie")
+
+	require.NoError(t, svc.Retract(1, ch.ID))
+	res, err = svc.Results(ch.ID, services.ResultsBayesian, true)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], res.Entries[0].EntryID)
+	assert.Equal(t, 1, res.Entries[0].Rank)
+	assert.Equal(t, 2, res.Entries[1].Rank)
+}
+
+func intPtr(n int) *int { return &n }